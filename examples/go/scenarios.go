@@ -19,9 +19,8 @@ package examples
 
 import (
 	"math"
-	"math/rand"
-	"time"
 
+	log "github.com/golang/glog"
 	"github.com/google/differential-privacy/go/dpagg"
 	"github.com/google/differential-privacy/go/noise"
 )
@@ -147,11 +146,10 @@ func (sc *CountVisitsPerDayScenario) getPrivateResults(weekVisits []Visit) map[i
 			// The data was pre-processed so that
 			// each user may visit the restaurant up to maxThreeVisitsPerWeek times per week.
 			// Hence, each user may contribute to up to maxThreeVisitsPerWeek daily visit counts.
-			// Note: while the library accepts this limit as a configurable parameter,
-			// it doesn't pre-process the data to ensure this limit is respected.
-			// It is responsibility of the caller to ensure the data passed to the library
-			// is capped for getting the correct privacy guarantee.
-			// TODO: Clarify the note above.
+			// Note: the aggregation itself doesn't ensure this limit is respected.
+			// The caller must cap the data passed to the library to get the correct
+			// privacy guarantee, which boundVisits does below using
+			// dpagg.BoundContributions.
 			MaxPartitionsContributed: maxThreeVisitsPerWeek,
 			Noise:                    noise.Laplace(),
 		})
@@ -205,11 +203,10 @@ func (sc *SumRevenuePerDayScenario) getPrivateResults(visits []Visit) map[int64]
 			// The data was pre-processed so that
 			// each user may visit the restaurant up to maxFourVisitsPerWeek times per week.
 			// Hence, each user may contribute to up to maxFourVisitsPerWeek daily counts.
-			// Note: while the library accepts this limit as a configurable parameter,
-			// it doesn't pre-process the data to ensure this limit is respected.
-			// It is responsibility of the caller to ensure the data passed to the library
-			// is capped for getting the correct privacy guarantee.
-			// TODO: Clarify the note above.
+			// Note: the aggregation itself doesn't ensure this limit is respected.
+			// The caller must cap the data passed to the library to get the correct
+			// privacy guarantee, which boundVisits does below using
+			// dpagg.BoundContributions.
 			MaxPartitionsContributed: maxFourVisitsPerWeek,
 			// No need to pre-process the data: BoundedSumInt64 will clamp the input values.
 			Lower: minEurosSpent,
@@ -352,30 +349,20 @@ func roundMinutes(minutes int64, divider int64) int64 {
 	return quotient * divider
 }
 
+// boundVisits pre-processes the data set so that each visitor contributes to at
+// most maxVisitsPerWeek days, with at most one visit per day. Days and visits
+// exceeding these limits are discarded uniformly at random.
 func boundVisits(initialVisits []Visit, maxVisitsPerWeek int64) []Visit {
-	boundedVisits := make([]Visit, 0)
-
-	// Shuffle all visits.
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(initialVisits), func(i, j int) { initialVisits[i], initialVisits[j] = initialVisits[j], initialVisits[i] })
-
-	// Go through the unordered collection of visits, but only add, at most,
-	// maxVisitsPerWeek visits per VisitorId to the final result, and
-	// discard all additional visits.
-	visitorIDToVisitCount := make(map[int64]int64)
-	for _, visit := range initialVisits {
-		val, ok := visitorIDToVisitCount[visit.VisitorID]
-		if !ok {
-			val = 1
-		} else {
-			val++
-		}
-
-		if val <= maxVisitsPerWeek {
-			visitorIDToVisitCount[visit.VisitorID] = val
-			boundedVisits = append(boundedVisits, visit)
-		}
+	kept, err := dpagg.BoundContributions(len(initialVisits), func(i int) (interface{}, interface{}) {
+		return initialVisits[i].VisitorID, initialVisits[i].Day
+	}, maxVisitsPerWeek, 1)
+	if err != nil {
+		log.Fatalf("Couldn't bound visits: %v", err)
 	}
 
+	boundedVisits := make([]Visit, 0, len(kept))
+	for _, i := range kept {
+		boundedVisits = append(boundedVisits, initialVisits[i])
+	}
 	return boundedVisits
 }
@@ -0,0 +1,333 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package bounding contains differentially private aggregations over raw,
+// per-user records that run in memory, without Apache Beam.
+//
+// The aggregations in dpagg assume that the contributions of each user are
+// already bounded: a user may contribute to at most MaxPartitionsContributed
+// partitions (cross-partition or L_0 bounding), and at most
+// MaxContributionsPerPartition times to each of them (per-partition or L_∞
+// bounding). The functions in this package take raw (privacy ID, partition
+// key, value) records, enforce these bounds by uniform random sampling, feed
+// the kept records into one dpagg aggregation per partition, and decide which
+// partitions to release with dpagg.PreAggSelectPartition, just like the
+// aggregations of Privacy on Beam do.
+package bounding
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// Record is a single raw contribution of a privacy unit (e.g., a user) to a
+// partition. PrivacyID and PartitionKey must be comparable, i.e., usable as
+// map keys.
+type Record struct {
+	PrivacyID    interface{}
+	PartitionKey interface{}
+	// Value is ignored by Count.
+	Value float64
+}
+
+// Iterator iterates over raw records.
+type Iterator interface {
+	// Next returns the next record, or io.EOF if there are no more records.
+	// Any other error aborts the aggregation and is returned to the caller.
+	Next() (Record, error)
+}
+
+type sliceIterator struct {
+	records []Record
+	next    int
+}
+
+// NewSliceIterator returns an Iterator over the given records.
+func NewSliceIterator(records []Record) Iterator {
+	return &sliceIterator{records: records}
+}
+
+func (it *sliceIterator) Next() (Record, error) {
+	if it.next >= len(it.records) {
+		return Record{}, io.EOF
+	}
+	r := it.records[it.next]
+	it.next++
+	return r, nil
+}
+
+// Options contains the options necessary to run an aggregation.
+type Options struct {
+	// Differential privacy budget consumed by the aggregation, including
+	// partition selection. Required. Delta must be nonzero unless
+	// PublicPartitions is set and Laplace noise is used.
+	Epsilon, Delta float64
+	// How many distinct partitions may a single user contribute to? If a user
+	// contributes to more partitions, partitions are dropped uniformly at
+	// random. Required.
+	MaxPartitionsContributed int64
+	// How many times may a single user contribute to a single partition? If a
+	// user contributes more records to a partition, records are dropped
+	// uniformly at random. Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds for clamping values. Required by SumFloat64 and
+	// MeanFloat64, ignored by Count.
+	Lower, Upper float64
+	// Type of noise used. Defaults to Laplace noise.
+	Noise noise.Noise
+	// PublicPartitions, if set, lists the partitions to release. Records for
+	// other partitions are dropped, and all listed partitions are released, even
+	// if no user contributed to them. Since the released partitions then do not
+	// depend on the data, no partition selection is done and the entire budget
+	// is used for noise. Optional.
+	PublicPartitions []interface{}
+}
+
+// partition holds the bounded contributions to a single partition.
+type partition struct {
+	values []float64
+	ids    map[interface{}]bool
+}
+
+// plan holds the bounded data and the budget of an aggregation.
+type plan struct {
+	opt                          Options
+	partitions                   map[interface{}]*partition
+	epsNoise, deltaNoise         float64
+	epsSelection, deltaSelection float64
+}
+
+// newPlan checks the options, reads the records from it and bounds the
+// contributions of each user. validate is called before reading any record,
+// and returns an error if the per-partition aggregations can't be built with
+// the options and budget of the plan.
+func newPlan(label string, it Iterator, opt *Options, validate func(*plan) error) (*plan, error) {
+	if opt == nil {
+		return nil, fmt.Errorf("%s: options are required", label)
+	}
+	p := &plan{opt: *opt}
+	if p.opt.MaxPartitionsContributed <= 0 {
+		return nil, fmt.Errorf("%s: MaxPartitionsContributed is %d, should be strictly positive", label, p.opt.MaxPartitionsContributed)
+	}
+	if p.opt.MaxContributionsPerPartition == 0 {
+		p.opt.MaxContributionsPerPartition = 1
+	}
+	if p.opt.Noise == nil {
+		p.opt.Noise = noise.Laplace()
+	}
+	if err := p.splitBudget(label); err != nil {
+		return nil, err
+	}
+	if p.opt.PublicPartitions == nil {
+		if err := p.selectionOptions().Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	if err := validate(p); err != nil {
+		return nil, fmt.Errorf("%s: %w", label, err)
+	}
+
+	p.partitions = make(map[interface{}]*partition)
+	for _, pk := range p.opt.PublicPartitions {
+		p.partitions[pk] = &partition{ids: make(map[interface{}]bool)}
+	}
+	var records []Record
+	for {
+		r, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: couldn't read records: %w", label, err)
+		}
+		// Records for non-public partitions are dropped before contribution
+		// bounding, so that they don't use up the partitions a user may
+		// contribute to.
+		if _, ok := p.partitions[r.PartitionKey]; !ok && p.opt.PublicPartitions != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	kept, err := dpagg.BoundContributions(len(records), func(i int) (interface{}, interface{}) {
+		return records[i].PrivacyID, records[i].PartitionKey
+	}, p.opt.MaxPartitionsContributed, p.opt.MaxContributionsPerPartition)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", label, err)
+	}
+
+	for _, i := range kept {
+		r := records[i]
+		part, ok := p.partitions[r.PartitionKey]
+		if !ok {
+			part = &partition{ids: make(map[interface{}]bool)}
+			p.partitions[r.PartitionKey] = part
+		}
+		part.values = append(part.values, r.Value)
+		part.ids[r.PrivacyID] = true
+	}
+	return p, nil
+}
+
+// splitBudget splits the budget between noise and partition selection the same
+// way Privacy on Beam does: with partition selection, ε is split in half, and δ
+// is entirely used for partition selection with Laplace noise, or split in
+// half with Gaussian noise.
+func (p *plan) splitBudget(label string) error {
+	eps, delta := p.opt.Epsilon, p.opt.Delta
+	if p.opt.PublicPartitions != nil {
+		p.epsNoise, p.deltaNoise = eps, delta
+		return nil
+	}
+	p.epsNoise, p.epsSelection = eps/2, eps/2
+	switch noise.ToKind(p.opt.Noise) {
+	case noise.GaussianNoise:
+		p.deltaNoise, p.deltaSelection = delta/2, delta/2
+	default:
+		p.deltaNoise, p.deltaSelection = 0, delta
+	}
+	if err := checks.CheckDelta(label+" (partition selection)", p.deltaSelection); err != nil {
+		return err
+	}
+	return checks.CheckEpsilonStrict(label+" (partition selection)", p.epsSelection)
+}
+
+// keep returns whether a partition should be released.
+func (p *plan) keep(part *partition) bool {
+	if p.opt.PublicPartitions != nil {
+		return true
+	}
+	sp := dpagg.NewPreAggSelectPartition(p.selectionOptions())
+	for range part.ids {
+		sp.Add()
+	}
+	return sp.Result()
+}
+
+func (p *plan) selectionOptions() *dpagg.PreAggSelectPartitionOptions {
+	return &dpagg.PreAggSelectPartitionOptions{
+		Epsilon:                  p.epsSelection,
+		Delta:                    p.deltaSelection,
+		MaxPartitionsContributed: p.opt.MaxPartitionsContributed,
+	}
+}
+
+func (p *plan) countOptions() *dpagg.CountOptions {
+	return &dpagg.CountOptions{
+		Epsilon:                      p.epsNoise,
+		Delta:                        p.deltaNoise,
+		MaxPartitionsContributed:     p.opt.MaxPartitionsContributed,
+		MaxContributionsPerPartition: p.opt.MaxContributionsPerPartition,
+		Noise:                        p.opt.Noise,
+	}
+}
+
+func (p *plan) sumOptions() *dpagg.BoundedSumFloat64Options {
+	return &dpagg.BoundedSumFloat64Options{
+		Epsilon:                      p.epsNoise,
+		Delta:                        p.deltaNoise,
+		MaxPartitionsContributed:     p.opt.MaxPartitionsContributed,
+		MaxContributionsPerPartition: p.opt.MaxContributionsPerPartition,
+		Lower:                        p.opt.Lower,
+		Upper:                        p.opt.Upper,
+		Noise:                        p.opt.Noise,
+	}
+}
+
+func (p *plan) meanOptions() *dpagg.BoundedMeanFloat64Options {
+	return &dpagg.BoundedMeanFloat64Options{
+		Epsilon:                      p.epsNoise,
+		Delta:                        p.deltaNoise,
+		MaxPartitionsContributed:     p.opt.MaxPartitionsContributed,
+		MaxContributionsPerPartition: p.opt.MaxContributionsPerPartition,
+		Lower:                        p.opt.Lower,
+		Upper:                        p.opt.Upper,
+		Noise:                        p.opt.Noise,
+	}
+}
+
+// Count counts the number of records per partition after bounding the
+// contributions of each user, and returns a differentially private count for
+// each released partition. Negative counts are clamped to zero.
+func Count(it Iterator, opt *Options) (map[interface{}]int64, error) {
+	p, err := newPlan("bounding.Count", it, opt, func(p *plan) error { return p.countOptions().Validate() })
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[interface{}]int64)
+	for pk, part := range p.partitions {
+		if !p.keep(part) {
+			continue
+		}
+		c := dpagg.NewCount(p.countOptions())
+		c.IncrementBy(int64(len(part.values)))
+		result[pk] = int64(math.Max(0, float64(c.Result())))
+	}
+	return result, nil
+}
+
+// SumFloat64 sums the values of the records per partition after bounding the
+// contributions of each user and clamping each value to [Lower, Upper], and
+// returns a differentially private sum for each released partition. If Lower
+// is nonnegative, negative sums are clamped to zero.
+func SumFloat64(it Iterator, opt *Options) (map[interface{}]float64, error) {
+	p, err := newPlan("bounding.SumFloat64", it, opt, func(p *plan) error { return p.sumOptions().Validate() })
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[interface{}]float64)
+	for pk, part := range p.partitions {
+		if !p.keep(part) {
+			continue
+		}
+		bs := dpagg.NewBoundedSumFloat64(p.sumOptions())
+		for _, v := range part.values {
+			bs.Add(v)
+		}
+		sum := bs.Result()
+		if p.opt.Lower >= 0 {
+			sum = math.Max(0, sum)
+		}
+		result[pk] = sum
+	}
+	return result, nil
+}
+
+// MeanFloat64 averages the values of the records per partition after bounding
+// the contributions of each user and clamping each value to [Lower, Upper],
+// and returns a differentially private mean for each released partition.
+func MeanFloat64(it Iterator, opt *Options) (map[interface{}]float64, error) {
+	p, err := newPlan("bounding.MeanFloat64", it, opt, func(p *plan) error { return p.meanOptions().Validate() })
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[interface{}]float64)
+	for pk, part := range p.partitions {
+		if !p.keep(part) {
+			continue
+		}
+		bm := dpagg.NewBoundedMeanFloat64(p.meanOptions())
+		for _, v := range part.values {
+			bm.Add(v)
+		}
+		result[pk] = bm.Result()
+	}
+	return result, nil
+}
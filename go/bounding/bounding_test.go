@@ -0,0 +1,377 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package bounding
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+var ln3 = math.Log(3)
+
+// noNoise is a Noise instance that doesn't add noise to the data.
+type noNoise struct {
+	noise.Noise
+}
+
+func (noNoise) AddNoiseInt64(x, _, _ int64, _, _ float64) int64 {
+	return x
+}
+
+func (noNoise) AddNoiseFloat64(x float64, _ int64, _, _, _ float64) float64 {
+	return x
+}
+
+// errIterator returns an error after returning its records.
+type errIterator struct {
+	Iterator
+}
+
+func (it errIterator) Next() (Record, error) {
+	r, err := it.Iterator.Next()
+	if err != nil {
+		return r, errors.New("read failure")
+	}
+	return r, nil
+}
+
+// recordsPerUser returns records where each of numUsers users contributes
+// numRecords records with value 1 to each of the given partitions.
+func recordsPerUser(numUsers, numRecords int, partitions ...string) []Record {
+	var records []Record
+	for u := 0; u < numUsers; u++ {
+		for _, pk := range partitions {
+			for i := 0; i < numRecords; i++ {
+				records = append(records, Record{PrivacyID: u, PartitionKey: pk, Value: 1})
+			}
+		}
+	}
+	return records
+}
+
+func TestCountBoundsContributions(t *testing.T) {
+	// 10 users contribute 3 records to each of 4 partitions. With bounding,
+	// each user contributes 2 records to 2 partitions, so 40 records are kept.
+	records := recordsPerUser(10, 3, "a", "b", "c", "d")
+	got, err := Count(NewSliceIterator(records), &Options{
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 2,
+		Noise:                        noNoise{},
+		PublicPartitions:             []interface{}{"a", "b", "c", "d", "e"},
+	})
+	if err != nil {
+		t.Fatalf("Count: got err %v", err)
+	}
+	var total int64
+	for pk, c := range got {
+		if c%2 != 0 || c > 20 {
+			t.Errorf("Count: partition %v got %d, want an even count at most 20", pk, c)
+		}
+		total += c
+	}
+	if total != 40 {
+		t.Errorf("Count: got a total count of %d, want 40", total)
+	}
+	if c, ok := got["e"]; !ok || c != 0 {
+		t.Errorf("Count: for empty public partition got %d (present: %t), want 0", c, ok)
+	}
+}
+
+func TestSumAndMeanFloat64(t *testing.T) {
+	records := []Record{
+		{PrivacyID: 0, PartitionKey: "a", Value: 1},
+		{PrivacyID: 0, PartitionKey: "a", Value: 1},
+		{PrivacyID: 1, PartitionKey: "a", Value: 100}, // clamped to 5
+		{PrivacyID: 1, PartitionKey: "b", Value: 2},
+		{PrivacyID: 2, PartitionKey: "c", Value: 2}, // not a public partition
+	}
+	opt := &Options{
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 2,
+		Lower:                        0,
+		Upper:                        5,
+		Noise:                        noNoise{},
+		PublicPartitions:             []interface{}{"a", "b"},
+	}
+	gotSum, err := SumFloat64(NewSliceIterator(records), opt)
+	if err != nil {
+		t.Fatalf("SumFloat64: got err %v", err)
+	}
+	wantSum := map[interface{}]float64{"a": 7, "b": 2}
+	if !cmp.Equal(gotSum, wantSum) {
+		t.Errorf("SumFloat64: got %v, want %v", gotSum, wantSum)
+	}
+	gotMean, err := MeanFloat64(NewSliceIterator(records), opt)
+	if err != nil {
+		t.Fatalf("MeanFloat64: got err %v", err)
+	}
+	wantMean := map[interface{}]float64{"a": 7.0 / 3.0, "b": 2}
+	if !cmp.Equal(gotMean, wantMean, cmp.Comparer(func(x, y float64) bool { return math.Abs(x-y) < 1e-10 })) {
+		t.Errorf("MeanFloat64: got %v, want %v", gotMean, wantMean)
+	}
+}
+
+func TestCountSelectsPartitions(t *testing.T) {
+	// Partition "many" has 1000 users and is kept with overwhelming
+	// probability. Partition "one" has a single user and is kept with
+	// probability 1e-10.
+	records := append(recordsPerUser(1000, 1, "many"), Record{PrivacyID: "other", PartitionKey: "one"})
+	got, err := Count(NewSliceIterator(records), &Options{
+		Epsilon:                  ln3,
+		Delta:                    1e-10,
+		MaxPartitionsContributed: 1,
+	})
+	if err != nil {
+		t.Fatalf("Count: got err %v", err)
+	}
+	if _, ok := got["many"]; !ok {
+		t.Errorf("Count: partition with 1000 users was dropped, want kept")
+	}
+	if _, ok := got["one"]; ok {
+		t.Errorf("Count: partition with a single user was kept, want dropped")
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	records := recordsPerUser(1, 1, "a")
+	for _, tc := range []struct {
+		desc string
+		it   Iterator
+		opt  *Options
+	}{
+		{"nil options", NewSliceIterator(records), nil},
+		{"MaxPartitionsContributed not set", NewSliceIterator(records), &Options{Epsilon: ln3, Delta: 1e-5}},
+		{"no delta for partition selection", NewSliceIterator(records), &Options{Epsilon: ln3, MaxPartitionsContributed: 1}},
+		{"negative MaxContributionsPerPartition", NewSliceIterator(records), &Options{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1, MaxContributionsPerPartition: -1}},
+		{"iterator error", errIterator{NewSliceIterator(records)}, &Options{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1}},
+		{"Laplace noise with Delta and public partitions", NewSliceIterator(records), &Options{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1, PublicPartitions: []interface{}{"a"}}},
+		{"Gaussian noise without Delta and public partitions", NewSliceIterator(records), &Options{Epsilon: ln3, MaxPartitionsContributed: 1, Noise: noise.Gaussian(), PublicPartitions: []interface{}{"a"}}},
+		{"zero Epsilon and public partitions", NewSliceIterator(records), &Options{MaxPartitionsContributed: 1, PublicPartitions: []interface{}{"a"}}},
+	} {
+		if _, err := Count(tc.it, tc.opt); err == nil {
+			t.Errorf("Count: with %s got no error", tc.desc)
+		}
+	}
+}
+
+func TestSumAndMeanFloat64InvalidBounds(t *testing.T) {
+	records := recordsPerUser(1, 1, "a")
+	opt := &Options{Epsilon: ln3, MaxPartitionsContributed: 1, Lower: 5, Upper: 1, PublicPartitions: []interface{}{"a"}}
+	if _, err := SumFloat64(NewSliceIterator(records), opt); err == nil {
+		t.Errorf("SumFloat64: with Lower > Upper got no error")
+	}
+	if _, err := MeanFloat64(NewSliceIterator(records), opt); err == nil {
+		t.Errorf("MeanFloat64: with Lower > Upper got no error")
+	}
+}
+
+func TestCountPublicPartitionsWithLaplaceNoise(t *testing.T) {
+	// Each user contributes to two non-public partitions and to "a". Records
+	// for non-public partitions are dropped before contribution bounding, so
+	// every user keeps their contribution to "a".
+	records := recordsPerUser(10, 1, "x", "y", "a")
+	got, err := Count(NewSliceIterator(records), &Options{
+		Epsilon:                  1e100, // the noise is negligible
+		MaxPartitionsContributed: 1,
+		PublicPartitions:         []interface{}{"a"},
+	})
+	if err != nil {
+		t.Fatalf("Count: got err %v", err)
+	}
+	want := map[interface{}]int64{"a": 10}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Count: got %v, want %v", got, want)
+	}
+}
+
+func TestHeavyHitters(t *testing.T) {
+	var records []Record
+	// 1000 users contribute "heavy", and 1000 other users contribute "heavier".
+	for u := 0; u < 1000; u++ {
+		records = append(records, Record{PrivacyID: u, PartitionKey: "heavy"})
+		records = append(records, Record{PrivacyID: 1000 + u, PartitionKey: "heavier"})
+	}
+	// 500 users contribute a distinct item each.
+	for u := 0; u < 500; u++ {
+		records = append(records, Record{PrivacyID: 2000 + u, PartitionKey: fmt.Sprintf("tail%d", u)})
+	}
+	got, err := HeavyHitters(NewSliceIterator(records), &HeavyHittersOptions{
+		Epsilon:                  ln3,
+		Delta:                    1e-10,
+		MaxPartitionsContributed: 1,
+		MaxLength:                7,
+		PrefixStep:               3,
+		Noise:                    noNoise{},
+	})
+	if err != nil {
+		t.Fatalf("HeavyHitters: got err %v", err)
+	}
+	want := map[string]int64{"heavy": 1000, "heavier": 1000}
+	if !cmp.Equal(got, want) {
+		t.Errorf("HeavyHitters: got %v, want %v", got, want)
+	}
+}
+
+func TestHeavyHittersTruncatesItems(t *testing.T) {
+	var records []Record
+	for u := 0; u < 1000; u++ {
+		records = append(records, Record{PrivacyID: u, PartitionKey: fmt.Sprintf("prefix%d", u)})
+	}
+	got, err := HeavyHitters(NewSliceIterator(records), &HeavyHittersOptions{
+		Epsilon:                  ln3,
+		Delta:                    1e-10,
+		MaxPartitionsContributed: 1,
+		MaxLength:                6,
+		Noise:                    noNoise{},
+	})
+	if err != nil {
+		t.Fatalf("HeavyHitters: got err %v", err)
+	}
+	want := map[string]int64{"prefix": 1000}
+	if !cmp.Equal(got, want) {
+		t.Errorf("HeavyHitters: got %v, want %v", got, want)
+	}
+}
+
+func TestHeavyHittersInvalidOptions(t *testing.T) {
+	records := []Record{{PrivacyID: 0, PartitionKey: "a"}}
+	for _, tc := range []struct {
+		desc    string
+		records []Record
+		opt     *HeavyHittersOptions
+	}{
+		{"nil options", records, nil},
+		{"MaxPartitionsContributed not set", records, &HeavyHittersOptions{Epsilon: ln3, Delta: 1e-5, MaxLength: 5}},
+		{"MaxLength not set", records, &HeavyHittersOptions{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1}},
+		{"negative PrefixStep", records, &HeavyHittersOptions{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1, MaxLength: 5, PrefixStep: -1}},
+		{"no delta", records, &HeavyHittersOptions{Epsilon: ln3, MaxPartitionsContributed: 1, MaxLength: 5}},
+		{"non-string item", []Record{{PrivacyID: 0, PartitionKey: 1}}, &HeavyHittersOptions{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: 1, MaxLength: 5}},
+	} {
+		if _, err := HeavyHitters(NewSliceIterator(tc.records), tc.opt); err == nil {
+			t.Errorf("HeavyHitters: with %s got no error", tc.desc)
+		}
+	}
+}
+
+// sameValue returns a statistic that checks that each privacy unit of a block
+// has recordsPerUser records, and returns the mean of the values of the block.
+func sameValue(t *testing.T, recordsPerUser int) func([]Record) float64 {
+	return func(block []Record) float64 {
+		perUser := make(map[interface{}]int)
+		var sum float64
+		for _, r := range block {
+			perUser[r.PrivacyID]++
+			sum += r.Value
+		}
+		for id, n := range perUser {
+			if n != recordsPerUser {
+				t.Errorf("SampleAndAggregate: block has %d records of user %v, want %d", n, id, recordsPerUser)
+			}
+		}
+		return sum / float64(len(block))
+	}
+}
+
+func TestSampleAndAggregateMean(t *testing.T) {
+	var records []Record
+	for _, r := range recordsPerUser(200, 3, "a") {
+		r.Value = 3
+		records = append(records, r)
+	}
+	for _, tc := range []struct {
+		desc         string
+		records      []Record
+		lower, upper float64
+		want         float64
+	}{
+		{"outputs within bounds", records, 0, 10, 3},
+		{"outputs are clamped", records, 0, 2, 2},
+		// Every block is empty, and the mean of an empty block is NaN.
+		{"NaN outputs", nil, 0, 10, 5},
+	} {
+		got, err := SampleAndAggregate(NewSliceIterator(tc.records), sameValue(t, 3), &SampleAndAggregateOptions{
+			Epsilon:   ln3,
+			NumBlocks: 5,
+			Lower:     tc.lower,
+			Upper:     tc.upper,
+			Noise:     noNoise{},
+		})
+		if err != nil {
+			t.Fatalf("SampleAndAggregate: for %s got error %v", tc.desc, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("SampleAndAggregate: for %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestSampleAndAggregateMedian(t *testing.T) {
+	var records []Record
+	for _, r := range recordsPerUser(1000, 2, "a") {
+		r.Value = 3
+		records = append(records, r)
+	}
+	// All the outputs are equal, so the smooth sensitivity of their median is
+	// about 10·e^(-30), and the noise is negligible.
+	got, err := SampleAndAggregate(NewSliceIterator(records), sameValue(t, 2), &SampleAndAggregateOptions{
+		Epsilon:     5,
+		NumBlocks:   100,
+		Lower:       0,
+		Upper:       10,
+		Aggregation: MedianOfBlocks,
+	})
+	if err != nil {
+		t.Fatalf("SampleAndAggregate: got error %v", err)
+	}
+	if math.Abs(got-3) > 0.01 {
+		t.Errorf("SampleAndAggregate: got %f, want 3", got)
+	}
+}
+
+func TestSampleAndAggregateInvalidOptions(t *testing.T) {
+	valid := SampleAndAggregateOptions{Epsilon: ln3, NumBlocks: 10, Lower: 0, Upper: 1}
+	for _, tc := range []struct {
+		desc   string
+		modify func(*SampleAndAggregateOptions)
+	}{
+		{"zero NumBlocks", func(o *SampleAndAggregateOptions) { o.NumBlocks = 0 }},
+		{"equal bounds", func(o *SampleAndAggregateOptions) { o.Upper = 0 }},
+		{"zero Epsilon", func(o *SampleAndAggregateOptions) { o.Epsilon = 0 }},
+		{"unknown Aggregation", func(o *SampleAndAggregateOptions) { o.Aggregation = 7 }},
+		{"Delta with MedianOfBlocks", func(o *SampleAndAggregateOptions) { o.Aggregation, o.Delta = MedianOfBlocks, 1e-5 }},
+		{"Delta with MeanOfBlocks and Laplace noise", func(o *SampleAndAggregateOptions) { o.Delta = 1e-5 }},
+		{"no Delta with MeanOfBlocks and Gaussian noise", func(o *SampleAndAggregateOptions) { o.Noise = noise.Gaussian() }},
+	} {
+		opt := valid
+		tc.modify(&opt)
+		if _, err := SampleAndAggregate(NewSliceIterator(nil), func([]Record) float64 { return 0 }, &opt); err == nil {
+			t.Errorf("SampleAndAggregate: with %s got no error", tc.desc)
+		}
+	}
+	if _, err := SampleAndAggregate(errIterator{NewSliceIterator(nil)}, func([]Record) float64 { return 0 }, &valid); err == nil {
+		t.Errorf("SampleAndAggregate: with a failing iterator got no error")
+	}
+}
@@ -0,0 +1,219 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package bounding
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// HeavyHittersOptions contains the options necessary to run HeavyHitters.
+type HeavyHittersOptions struct {
+	// Differential privacy budget consumed by HeavyHitters. Required.
+	Epsilon, Delta float64
+	// How many distinct items may a single user contribute? If a user
+	// contributes more items, items are dropped uniformly at random. Required.
+	MaxPartitionsContributed int64
+	// Items are truncated to their first MaxLength bytes. Required.
+	MaxLength int
+	// Number of bytes by which the prefixes grow at each level of the prefix
+	// tree. Larger steps mean fewer levels, hence more budget per level, but more
+	// candidate prefixes per level. Defaults to 1.
+	PrefixStep int
+	// Type of noise used for the counts of the released items. Defaults to
+	// Laplace noise.
+	Noise noise.Noise
+}
+
+// prefix is a node of the prefix tree explored by HeavyHitters. A complete
+// prefix is an entire (truncated) item.
+type prefix struct {
+	value    string
+	complete bool
+}
+
+func prefixOf(item string, length int) prefix {
+	if len(item) <= length {
+		return prefix{value: item, complete: true}
+	}
+	return prefix{value: item[:length]}
+}
+
+// HeavyHitters finds the items (e.g., search queries or URLs) contributed by
+// many users, in a domain that is too large to enumerate, and returns a
+// differentially private count of users for each of them. The PartitionKey of
+// each record is the item, and must be a string; Value is ignored.
+//
+// HeavyHitters bounds the number of distinct items per user, then explores a
+// prefix tree of the items level by level: at each level, the prefixes of the
+// items extending a prefix selected at the previous level are candidates, and
+// each candidate is kept with dpagg.PreAggSelectPartition, based on its number
+// of users. Only the items that are complete prefixes at the last level are
+// released, with a noisy count of users. Since only prefixes shared by many
+// users survive, no budget is spent on the long tail of the domain beyond the
+// first levels.
+//
+// Half of the privacy budget is used for the selection, split evenly between
+// the levels, and the other half for the counts; δ is entirely used for the
+// selection with Laplace noise, or split in half with Gaussian noise.
+func HeavyHitters(it Iterator, opt *HeavyHittersOptions) (map[string]int64, error) {
+	const label = "bounding.HeavyHitters"
+	if opt == nil {
+		return nil, fmt.Errorf("%s: options are required", label)
+	}
+	if opt.MaxPartitionsContributed <= 0 {
+		return nil, fmt.Errorf("%s: MaxPartitionsContributed is %d, should be strictly positive", label, opt.MaxPartitionsContributed)
+	}
+	if opt.MaxLength <= 0 {
+		return nil, fmt.Errorf("%s: MaxLength is %d, should be strictly positive", label, opt.MaxLength)
+	}
+	step := opt.PrefixStep
+	if step == 0 {
+		step = 1
+	}
+	if step < 0 {
+		return nil, fmt.Errorf("%s: PrefixStep is %d, should be strictly positive", label, step)
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	var lengths []int
+	for l := step; l < opt.MaxLength; l += step {
+		lengths = append(lengths, l)
+	}
+	lengths = append(lengths, opt.MaxLength)
+
+	epsNoise, epsSelection := opt.Epsilon/2, opt.Epsilon/2
+	deltaNoise, deltaSelection := 0.0, opt.Delta
+	if noise.ToKind(n) == noise.GaussianNoise {
+		deltaNoise, deltaSelection = opt.Delta/2, opt.Delta/2
+	}
+	epsLevel, deltaLevel := epsSelection/float64(len(lengths)), deltaSelection/float64(len(lengths))
+	if err := checks.CheckEpsilonStrict(label+" (selection per level)", epsLevel); err != nil {
+		return nil, err
+	}
+	if err := checks.CheckDelta(label+" (selection per level)", deltaLevel); err != nil {
+		return nil, err
+	}
+	if deltaLevel == 0 {
+		return nil, fmt.Errorf("%s: Delta must be strictly positive for the selection of items", label)
+	}
+
+	// Read the records and bound the number of distinct items per user.
+	type userItem struct {
+		id   interface{}
+		item string
+	}
+	var records []userItem
+	for {
+		r, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: couldn't read records: %w", label, err)
+		}
+		item, ok := r.PartitionKey.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: PartitionKey must be a string, got %T", label, r.PartitionKey)
+		}
+		if len(item) > opt.MaxLength {
+			item = item[:opt.MaxLength]
+		}
+		records = append(records, userItem{r.PrivacyID, item})
+	}
+	kept, err := dpagg.BoundContributions(len(records), func(i int) (interface{}, interface{}) {
+		return records[i].id, records[i].item
+	}, opt.MaxPartitionsContributed, 1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", label, err)
+	}
+
+	// Explore the prefix tree. selected holds the prefixes selected at the
+	// previous level; nil means that every prefix is a candidate.
+	var selected map[prefix]bool
+	previousLength := 0
+	for _, length := range lengths {
+		// Number of users per candidate prefix. Each user contributes to at most
+		// MaxPartitionsContributed prefixes, since its items are distinct.
+		users := make(map[prefix]map[interface{}]bool)
+		next := make(map[prefix]bool)
+		for _, i := range kept {
+			r := records[i]
+			if selected != nil {
+				parent := prefixOf(r.item, previousLength)
+				if !selected[parent] {
+					continue
+				}
+				if parent.complete {
+					// Selected items are kept without being selected again.
+					next[parent] = true
+					continue
+				}
+			}
+			p := prefixOf(r.item, length)
+			if users[p] == nil {
+				users[p] = make(map[interface{}]bool)
+			}
+			users[p][r.id] = true
+		}
+		for p, ids := range users {
+			s := dpagg.NewPreAggSelectPartition(&dpagg.PreAggSelectPartitionOptions{
+				Epsilon:                  epsLevel,
+				Delta:                    deltaLevel,
+				MaxPartitionsContributed: opt.MaxPartitionsContributed,
+			})
+			for range ids {
+				s.Add()
+			}
+			if s.Result() {
+				next[p] = true
+			}
+		}
+		selected, previousLength = next, length
+	}
+
+	// Count the users of each selected item.
+	users := make(map[string]map[interface{}]bool)
+	for _, i := range kept {
+		r := records[i]
+		if selected[prefix{value: r.item, complete: true}] {
+			if users[r.item] == nil {
+				users[r.item] = make(map[interface{}]bool)
+			}
+			users[r.item][r.id] = true
+		}
+	}
+	result := make(map[string]int64)
+	for item, ids := range users {
+		c := dpagg.NewCount(&dpagg.CountOptions{
+			Epsilon:                  epsNoise,
+			Delta:                    deltaNoise,
+			MaxPartitionsContributed: opt.MaxPartitionsContributed,
+			Noise:                    n,
+		})
+		c.IncrementBy(int64(len(ids)))
+		result[item] = int64(math.Max(0, float64(c.Result())))
+	}
+	return result, nil
+}
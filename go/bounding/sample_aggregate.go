@@ -0,0 +1,172 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package bounding
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// BlockAggregation is the differentially private aggregation used by
+// SampleAndAggregate to combine the outputs of the blocks.
+type BlockAggregation int
+
+const (
+	// MeanOfBlocks releases the mean of the block outputs, with noise from a
+	// dpagg.BoundedSumFloat64.
+	MeanOfBlocks BlockAggregation = iota
+	// MedianOfBlocks releases the median of the block outputs, with a
+	// dpagg.SmoothSensitivityQuantile. It is more robust to blocks with outlying
+	// outputs, and always uses Cauchy noise, with δ = 0.
+	MedianOfBlocks
+)
+
+// SampleAndAggregateOptions contains the options necessary to run
+// SampleAndAggregate.
+type SampleAndAggregateOptions struct {
+	// Differential privacy budget consumed by SampleAndAggregate. Required.
+	// Delta must be 0 unless MeanOfBlocks is used with Gaussian noise.
+	Epsilon, Delta float64
+	// Number of blocks the privacy units are split into. More blocks mean less
+	// noise in the aggregation, but fewer records per block. Required.
+	NumBlocks int
+	// Lower and Upper bounds for clamping the outputs of the blocks. Must be
+	// such that Lower < Upper.
+	Lower, Upper float64
+	// How the outputs of the blocks are combined. Defaults to MeanOfBlocks.
+	Aggregation BlockAggregation
+	// Type of noise used by MeanOfBlocks. Defaults to Laplace noise.
+	Noise noise.Noise
+}
+
+// SampleAndAggregate computes a differentially private estimate of an
+// arbitrary statistic with the subsample-and-aggregate framework of Nissim et
+// al., "Smooth Sensitivity and Sampling in Private Data Analysis".
+//
+// Each privacy unit is assigned to one of NumBlocks blocks uniformly at random,
+// independently of the other privacy units, and statistic is run on the
+// records of each block (e.g., to fit a model and return one of its
+// coefficients). The outputs of the blocks are clamped to [Lower, Upper] and
+// combined with the chosen BlockAggregation. Since adding or removing a
+// privacy unit changes the records of a single block, it changes a single
+// output, whatever statistic computes; no bound on the contributions of each
+// privacy unit is needed. statistic may be called with an empty block, and
+// NaN outputs are replaced by the middle of [Lower, Upper].
+//
+// The result is useful when statistic is accurate on a random NumBlocks-th of
+// the data, so that the outputs of the blocks are close to each other.
+func SampleAndAggregate(it Iterator, statistic func(block []Record) float64, opt *SampleAndAggregateOptions) (float64, error) {
+	const label = "bounding.SampleAndAggregate"
+	if opt == nil {
+		return 0, fmt.Errorf("%s: options are required", label)
+	}
+	if opt.NumBlocks <= 0 {
+		return 0, fmt.Errorf("%s: NumBlocks is %d, should be strictly positive", label, opt.NumBlocks)
+	}
+	if err := checks.CheckBoundsFloat64(label, opt.Lower, opt.Upper); err != nil {
+		return 0, err
+	}
+	if opt.Lower == opt.Upper {
+		return 0, fmt.Errorf("%s: Lower and Upper are both %f, Lower should be strictly smaller", label, opt.Lower)
+	}
+	if err := checks.CheckEpsilonStrict(label, opt.Epsilon); err != nil {
+		return 0, err
+	}
+	if opt.Aggregation != MeanOfBlocks && opt.Aggregation != MedianOfBlocks {
+		return 0, fmt.Errorf("%s: unknown Aggregation %d", label, opt.Aggregation)
+	}
+	if opt.Aggregation == MedianOfBlocks {
+		if err := checks.CheckNoDelta(label, opt.Delta); err != nil {
+			return 0, err
+		}
+	}
+	// A privacy unit changes a single output, i.e., removes an output and adds
+	// another one, which counts as two contributions. The outputs are centered
+	// around 0, so that changing one of them changes the sum of the outputs by
+	// at most Upper - Lower.
+	middle := opt.Lower + (opt.Upper-opt.Lower)/2
+	halfWidth := (opt.Upper - opt.Lower) / 2
+	sumOpt := &dpagg.BoundedSumFloat64Options{
+		Epsilon:                      opt.Epsilon,
+		Delta:                        opt.Delta,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 2,
+		Lower:                        -halfWidth,
+		Upper:                        halfWidth,
+		Noise:                        opt.Noise,
+	}
+	if opt.Aggregation == MeanOfBlocks {
+		if err := sumOpt.Validate(); err != nil {
+			return 0, fmt.Errorf("%s: %w", label, err)
+		}
+	}
+
+	// Assign each privacy unit to a block.
+	blockOf := make(map[interface{}]int)
+	blocks := make([][]Record, opt.NumBlocks)
+	for {
+		r, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: couldn't read records: %w", label, err)
+		}
+		b, ok := blockOf[r.PrivacyID]
+		if !ok {
+			b = int(rand.I63n(int64(opt.NumBlocks)))
+			blockOf[r.PrivacyID] = b
+		}
+		blocks[b] = append(blocks[b], r)
+	}
+
+	outputs := make([]float64, opt.NumBlocks)
+	for i, block := range blocks {
+		output := statistic(block)
+		if math.IsNaN(output) {
+			output = middle
+		}
+		outputs[i] = math.Min(math.Max(output, opt.Lower), opt.Upper)
+	}
+
+	if opt.Aggregation == MedianOfBlocks {
+		q := dpagg.NewSmoothSensitivityQuantile(&dpagg.SmoothSensitivityQuantileOptions{
+			Epsilon:                      opt.Epsilon,
+			MaxContributionsPerPartition: 2,
+			Lower:                        opt.Lower,
+			Upper:                        opt.Upper,
+			Quantile:                     0.5,
+		})
+		for _, o := range outputs {
+			q.Add(o)
+		}
+		return q.Result(), nil
+	}
+	// The number of blocks is public, so only the sum of the outputs is noised.
+	sum := dpagg.NewBoundedSumFloat64(sumOpt)
+	for _, o := range outputs {
+		sum.Add(o - middle)
+	}
+	mean := middle + sum.Result()/float64(opt.NumBlocks)
+	return math.Min(math.Max(mean, opt.Lower), opt.Upper), nil
+}
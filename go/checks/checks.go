@@ -24,37 +24,122 @@ import (
 	log "github.com/golang/glog"
 )
 
+// Constraint is a constraint that a parameter must satisfy.
+type Constraint int
+
+const (
+	// StrictlyPositive means that the parameter must be larger than 0.
+	StrictlyPositive Constraint = iota + 1
+	// Nonnegative means that the parameter must be larger than or equal to 0.
+	Nonnegative
+	// Zero means that the parameter must be 0.
+	Zero
+	// NotNaN means that the parameter must not be NaN.
+	NotNaN
+	// Finite means that the parameter must not be ±∞.
+	Finite
+	// InRange means that the parameter must be in the allowed range.
+	InRange
+)
+
+func (c Constraint) String() string {
+	switch c {
+	case StrictlyPositive:
+		return "StrictlyPositive"
+	case Nonnegative:
+		return "Nonnegative"
+	case Zero:
+		return "Zero"
+	case NotNaN:
+		return "NotNaN"
+	case Finite:
+		return "Finite"
+	case InRange:
+		return "InRange"
+	default:
+		return fmt.Sprintf("Constraint(%d)", int(c))
+	}
+}
+
+// ParameterError is the error returned by the checks of this package when a
+// parameter is invalid. Callers can retrieve it from wrapped errors with
+// errors.As, e.g., to tell which parameter is invalid.
+type ParameterError struct {
+	// Label identifies the function that checked the parameter.
+	Label string
+	// Name of the invalid parameter, e.g., "Epsilon".
+	Parameter string
+	// The invalid value.
+	Value interface{}
+	// The constraint violated by Value.
+	Constraint Constraint
+	// The range of valid values in interval notation, e.g., "(0, +∞)".
+	AllowedRange string
+}
+
+func (e *ParameterError) Error() string {
+	var should string
+	switch e.Constraint {
+	case StrictlyPositive:
+		should = "should be strictly positive"
+	case Nonnegative:
+		should = "should be nonnegative"
+	case Zero:
+		should = "should be 0"
+	case NotNaN:
+		should = "cannot be NaN"
+	case Finite:
+		should = "cannot be infinity"
+	default:
+		should = "should be in " + e.AllowedRange
+	}
+	return fmt.Sprintf("%s: %s is %v, %s", e.Label, e.Parameter, e.Value, should)
+}
+
 // CheckEpsilonVeryStrict returns an error if ε is +∞ or less than 2⁻⁵⁰.
 func CheckEpsilonVeryStrict(label string, epsilon float64) error {
-	if epsilon < math.Exp2(-50.0) || math.IsInf(epsilon, 0) {
-		return fmt.Errorf("%s: Epsilon is %f, should be at least 2^-50 (and cannot be infinity)", label, epsilon)
+	const allowed = "[2^-50, +∞)"
+	if math.IsInf(epsilon, 0) {
+		return &ParameterError{label, "Epsilon", epsilon, Finite, allowed}
+	}
+	if epsilon < math.Exp2(-50.0) {
+		return &ParameterError{label, "Epsilon", epsilon, InRange, allowed}
 	}
 	return nil
 }
 
 // CheckEpsilonStrict returns an error if ε is nonpositive or +∞.
 func CheckEpsilonStrict(label string, epsilon float64) error {
-	if epsilon <= 0 || math.IsInf(epsilon, 0) {
-		return fmt.Errorf("%s: Epsilon is %f, should be strictly positive (and cannot be infinity)", label, epsilon)
+	const allowed = "(0, +∞)"
+	if math.IsInf(epsilon, 0) {
+		return &ParameterError{label, "Epsilon", epsilon, Finite, allowed}
+	}
+	if epsilon <= 0 {
+		return &ParameterError{label, "Epsilon", epsilon, StrictlyPositive, allowed}
 	}
 	return nil
 }
 
 // CheckEpsilon returns an error if ε is strictly negative or +∞.
 func CheckEpsilon(label string, epsilon float64) error {
-	if epsilon < 0 || math.IsInf(epsilon, 0) {
-		return fmt.Errorf("%s: Epsilon is %f, should be nonnegative (and cannot be infinity)", label, epsilon)
+	const allowed = "[0, +∞)"
+	if math.IsInf(epsilon, 0) {
+		return &ParameterError{label, "Epsilon", epsilon, Finite, allowed}
+	}
+	if epsilon < 0 {
+		return &ParameterError{label, "Epsilon", epsilon, Nonnegative, allowed}
 	}
 	return nil
 }
 
 // CheckDelta returns an error if δ is nonpositive or larger than 1.
 func CheckDelta(label string, delta float64) error {
+	const allowed = "(0, 1)"
 	if delta <= 0 {
-		return fmt.Errorf("%s: Delta is %e, should be strictly positive", label, delta)
+		return &ParameterError{label, "Delta", delta, StrictlyPositive, allowed}
 	}
 	if delta >= 1 {
-		return fmt.Errorf("%s: Delta is %e, should be strictly less than 1", label, delta)
+		return &ParameterError{label, "Delta", delta, InRange, allowed}
 	}
 	return nil
 }
@@ -62,7 +147,7 @@ func CheckDelta(label string, delta float64) error {
 // CheckNoDelta returns an error if δ is non-zero.
 func CheckNoDelta(label string, delta float64) error {
 	if delta != 0 {
-		return fmt.Errorf("%s: Delta is %e, should be 0", label, delta)
+		return &ParameterError{label, "Delta", delta, Zero, "[0, 0]"}
 	}
 	return nil
 }
@@ -70,34 +155,47 @@ func CheckNoDelta(label string, delta float64) error {
 // CheckL0Sensitivity returns an error if l0Sensitivity is nonpositive.
 func CheckL0Sensitivity(label string, l0Sensitivity int64) error {
 	if l0Sensitivity <= 0 {
-		return fmt.Errorf("%s: L0Sensitivity is %d, should be strictly positive", label, l0Sensitivity)
+		return &ParameterError{label, "L0Sensitivity", l0Sensitivity, StrictlyPositive, "(0, MaxInt64]"}
 	}
 	return nil
 }
 
 // CheckLInfSensitivity returns an error if lInfSensitivity is nonpositive or +∞.
 func CheckLInfSensitivity(label string, lInfSensitivity float64) error {
-	if lInfSensitivity <= 0 || math.IsInf(lInfSensitivity, 0) {
-		return fmt.Errorf("%s: LInfSensitivity is %f, should be strictly positive (and cannot be infinity)", label, lInfSensitivity)
+	const allowed = "(0, +∞)"
+	if math.IsInf(lInfSensitivity, 0) {
+		return &ParameterError{label, "LInfSensitivity", lInfSensitivity, Finite, allowed}
+	}
+	if lInfSensitivity <= 0 {
+		return &ParameterError{label, "LInfSensitivity", lInfSensitivity, StrictlyPositive, allowed}
 	}
 	return nil
 }
 
 // CheckSigma returns an error if σ (the standard deviation of a normal distribution) is strictly negative or +∞.
 func CheckSigma(label string, sigma float64) error {
-	if sigma < 0 || math.IsInf(sigma, 0) {
-		return fmt.Errorf("%s: Sigma is %f, should be nonnegative (and cannot be infinity)", label, sigma)
+	const allowed = "[0, +∞)"
+	if math.IsInf(sigma, 0) {
+		return &ParameterError{label, "Sigma", sigma, Finite, allowed}
+	}
+	if sigma < 0 {
+		return &ParameterError{label, "Sigma", sigma, Nonnegative, allowed}
 	}
 	return nil
 }
 
 // CheckBoundsInt64 returns an error if lower is larger than upper, and ensures it won't lead to sensitivity overflow.
 func CheckBoundsInt64(label string, lower, upper int64) error {
-	if lower == math.MinInt64 || upper == math.MinInt64 {
-		return fmt.Errorf("%s: lower (%d) and upper (%d) must be strictly larger than math.MinInt64 to avoid sensitivity overflow", label, lower, upper)
+	// Bounds equal to math.MinInt64 would lead to sensitivity overflow.
+	const allowed = "(MinInt64, MaxInt64]"
+	if lower == math.MinInt64 {
+		return &ParameterError{label, "Lower", lower, InRange, allowed}
+	}
+	if upper == math.MinInt64 {
+		return &ParameterError{label, "Upper", upper, InRange, allowed}
 	}
 	if lower > upper {
-		return fmt.Errorf("%s: Upper (%d) should be larger than Lower (%d)", label, upper, lower)
+		return &ParameterError{label, "Upper", upper, InRange, fmt.Sprintf("[%d, MaxInt64]", lower)}
 	}
 	if lower == upper {
 		log.Warningf("Lower bound is equal to upper bound: all added elements will be clamped to %d", upper)
@@ -107,20 +205,21 @@ func CheckBoundsInt64(label string, lower, upper int64) error {
 
 // CheckBoundsFloat64 returns an error if lower is larger than upper, or if either parameter is ±∞.
 func CheckBoundsFloat64(label string, lower, upper float64) error {
+	const allowed = "(-∞, +∞)"
 	if math.IsNaN(lower) {
-		return fmt.Errorf("%s: lower can't be NaN", label)
+		return &ParameterError{label, "Lower", lower, NotNaN, allowed}
 	}
 	if math.IsNaN(upper) {
-		return fmt.Errorf("%s: upper can't be NaN", label)
+		return &ParameterError{label, "Upper", upper, NotNaN, allowed}
 	}
 	if math.IsInf(lower, 0) {
-		return fmt.Errorf("%s: lower can't be infinity", label)
+		return &ParameterError{label, "Lower", lower, Finite, allowed}
 	}
 	if math.IsInf(upper, 0) {
-		return fmt.Errorf("%s: upper can't be infinity", label)
+		return &ParameterError{label, "Upper", upper, Finite, allowed}
 	}
 	if lower > upper {
-		return fmt.Errorf("%s: Upper (%f) should be larger than Lower (%f)", label, upper, lower)
+		return &ParameterError{label, "Upper", upper, InRange, fmt.Sprintf("[%f, +∞)", lower)}
 	}
 	if lower == upper {
 		log.Warningf("Lower bound is equal to upper bound: all added elements will be clamped to %f", upper)
@@ -130,19 +229,20 @@ func CheckBoundsFloat64(label string, lower, upper float64) error {
 
 // CheckBoundsFloat64AsInt64 returns an error if lower is larger are NaN, or if either parameter overflow after conversion to int64.
 func CheckBoundsFloat64AsInt64(label string, lower, upper float64) error {
+	const allowed = "[MinInt64, MaxInt64]"
 	if math.IsNaN(lower) {
-		return fmt.Errorf("%s: Lower must not be NaN", label)
+		return &ParameterError{label, "Lower", lower, NotNaN, allowed}
 	}
 	if math.IsNaN(upper) {
-		return fmt.Errorf("%s: Upper must not be NaN", label)
+		return &ParameterError{label, "Upper", upper, NotNaN, allowed}
 	}
 	maxInt := float64(math.MaxInt64)
 	minInt := float64(math.MinInt64)
 	if lower < minInt || lower > maxInt {
-		return fmt.Errorf("%s: Lower should be within MinInt64 and MaxInt64 bounds, got %f", label, lower)
+		return &ParameterError{label, "Lower", lower, InRange, allowed}
 	}
 	if upper < minInt || upper > maxInt {
-		return fmt.Errorf("%s: Upper should be within MinInt64 and MaxInt64 bounds, got %f", label, upper)
+		return &ParameterError{label, "Upper", upper, InRange, allowed}
 	}
 	return nil
 }
@@ -150,7 +250,17 @@ func CheckBoundsFloat64AsInt64(label string, lower, upper float64) error {
 // CheckUserCount returns an error if userCount is strictly negative.
 func CheckUserCount(label string, userCount int64) error {
 	if userCount < 0 {
-		return fmt.Errorf("%s: userCount is %d, should be nonnegative", label, userCount)
+		return &ParameterError{label, "UserCount", userCount, Nonnegative, "[0, MaxInt64]"}
+	}
+	return nil
+}
+
+// CheckAlpha returns an error if the supplied alpha is not between 0 and 1
+// (exclusive). Alpha is the probability that a confidence interval does not
+// contain the true (unnoised) value.
+func CheckAlpha(label string, alpha float64) error {
+	if math.IsNaN(alpha) || alpha <= 0 || alpha >= 1 {
+		return &ParameterError{label, "Alpha", alpha, InRange, "(0, 1)"}
 	}
 	return nil
 }
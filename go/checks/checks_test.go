@@ -0,0 +1,98 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		err     error
+		want    *ParameterError
+		wantMsg string
+	}{
+		{"nonpositive epsilon",
+			CheckEpsilonStrict("label", 0),
+			&ParameterError{"label", "Epsilon", 0.0, StrictlyPositive, "(0, +∞)"},
+			"label: Epsilon is 0, should be strictly positive"},
+		{"infinite epsilon",
+			CheckEpsilon("label", math.Inf(1)),
+			&ParameterError{"label", "Epsilon", math.Inf(1), Finite, "[0, +∞)"},
+			"label: Epsilon is +Inf, cannot be infinity"},
+		{"delta too large",
+			CheckDelta("label", 1),
+			&ParameterError{"label", "Delta", 1.0, InRange, "(0, 1)"},
+			"label: Delta is 1, should be in (0, 1)"},
+		{"nonzero delta",
+			CheckNoDelta("label", 0.5),
+			&ParameterError{"label", "Delta", 0.5, Zero, "[0, 0]"},
+			"label: Delta is 0.5, should be 0"},
+		{"nonpositive l0Sensitivity",
+			CheckL0Sensitivity("label", -1),
+			&ParameterError{"label", "L0Sensitivity", int64(-1), StrictlyPositive, "(0, MaxInt64]"},
+			"label: L0Sensitivity is -1, should be strictly positive"},
+		{"NaN lower bound",
+			CheckBoundsFloat64("label", math.NaN(), 1),
+			&ParameterError{"label", "Lower", math.NaN(), NotNaN, "(-∞, +∞)"},
+			"label: Lower is NaN, cannot be NaN"},
+		{"upper bound smaller than lower bound",
+			CheckBoundsInt64("label", 5, 3),
+			&ParameterError{"label", "Upper", int64(3), InRange, "[5, MaxInt64]"},
+			"label: Upper is 3, should be in [5, MaxInt64]"},
+	} {
+		// Typed errors must be retrievable from wrapped errors.
+		wrapped := fmt.Errorf("wrapped: %w", tc.err)
+		var got *ParameterError
+		if !errors.As(wrapped, &got) {
+			t.Fatalf("errors.As: for %s got %v, want a *ParameterError", tc.desc, tc.err)
+		}
+		if !cmp.Equal(got, tc.want, cmp.Comparer(func(x, y float64) bool {
+			return x == y || math.IsNaN(x) && math.IsNaN(y)
+		})) {
+			t.Errorf("ParameterError: for %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+		if got.Error() != tc.wantMsg {
+			t.Errorf("Error(): for %s got %q, want %q", tc.desc, got.Error(), tc.wantMsg)
+		}
+	}
+}
+
+func TestValidParameters(t *testing.T) {
+	for _, err := range []error{
+		CheckEpsilonVeryStrict("label", 1),
+		CheckEpsilonStrict("label", 1),
+		CheckEpsilon("label", 0),
+		CheckDelta("label", 1e-5),
+		CheckNoDelta("label", 0),
+		CheckL0Sensitivity("label", 1),
+		CheckLInfSensitivity("label", 1),
+		CheckBoundsInt64("label", 1, 2),
+		CheckBoundsFloat64("label", 1, 2),
+		CheckAlpha("label", 0.05),
+	} {
+		if err != nil {
+			t.Errorf("got unexpected error %v", err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// CDF calculates a differentially private cumulative distribution function
+// (i.e., the empirical distribution) of a collection of values over the
+// ordered integer domain [Lower, Upper].
+//
+// The whole distribution is released at once, for the privacy budget of a
+// single aggregation: the values are counted in a HierarchicalHistogram, the
+// noisy cumulative counts are made monotone with isotonic regression, and then
+// normalized by the estimated total count. The result answers any number of
+// CDF(x) and Quantile(p) queries, which are consistent with each other, without
+// consuming additional privacy budget. This is much more accurate than
+// computing each quantile with a separate aggregation, which splits the budget
+// between all of them.
+//
+// CDF supports privacy units contributing multiple values
+// (MaxPartitionsContributed, defaults to 1) and multiple times the same value
+// (MaxContributionsPerPartition, defaults to 1). The aggregation itself
+// doesn't ensure these limits are respected: the caller must enforce them,
+// e.g., with BoundContributions.
+//
+// Not thread-safe.
+type CDF struct {
+	// State variables
+	histogram      HierarchicalHistogram
+	resultReturned bool // whether the result has already been returned
+}
+
+// CDFOptions contains the options necessary to initialize a CDF.
+type CDFOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct values may a single user contribute? Defaults to 1.
+	// How many times may a single user contribute the same value? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds of the domain, inclusive. Values outside of the
+	// domain are clamped to it. Required.
+	Lower, Upper int64
+	// Number of children of each inner node of the underlying hierarchical
+	// histogram. Defaults to 2. Values that would pad the tree with many empty
+	// leaves are lowered, as for HierarchicalHistogramOptions.
+	BranchingFactor int64
+	Noise           noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewCDF returns a new CDF.
+func NewCDF(opt *CDFOptions) *CDF {
+	if opt == nil {
+		opt = &CDFOptions{}
+	}
+	h := NewHierarchicalHistogram(&HierarchicalHistogramOptions{
+		Epsilon:                      opt.Epsilon,
+		Delta:                        opt.Delta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+		Lower:                        opt.Lower,
+		Upper:                        opt.Upper,
+		BranchingFactor:              opt.BranchingFactor,
+		Noise:                        opt.Noise,
+	})
+	return &CDF{
+		histogram:      *h,
+		resultReturned: false,
+	}
+}
+
+// Add adds a value to the CDF. Values outside of [Lower, Upper] are clamped to
+// the domain.
+func (c *CDF) Add(x int64) {
+	if c.resultReturned {
+		log.Fatalf("The CDF has already been calculated and returned. It cannot be amended.")
+	}
+	c.histogram.Add(x)
+}
+
+// Merge merges c2 into c (i.e., adds to c all entries that were added to c2).
+// c2 is consumed by this operation: it may not be used after it is merged
+// into c.
+func (c *CDF) Merge(c2 *CDF) {
+	if err := checkMergeCDF(c, c2); err != nil {
+		log.Exit(err)
+	}
+	c.histogram.Merge(&c2.histogram)
+	c2.resultReturned = true
+}
+
+func checkMergeCDF(c1, c2 *CDF) error {
+	if c1.resultReturned {
+		return fmt.Errorf("checkMergeCDF: c1 already returned the result, cannot be merged with another CDF instance")
+	}
+	if c2.resultReturned {
+		return fmt.Errorf("checkMergeCDF: c2 already returned the result, cannot be merged with another CDF instance")
+	}
+	if err := checkMergeHierarchicalHistogram(&c1.histogram, &c2.histogram); err != nil {
+		return fmt.Errorf("checkMergeCDF: c1 and c2 are not compatible: %v", err)
+	}
+	return nil
+}
+
+// Result computes the differentially private CDF and returns it as a
+// CDFResult. It can be called only once, after which no further operation can
+// be done on the CDF.
+//
+// If the estimated total count isn't positive, i.e., there is too little data
+// for the noise, the result is the CDF of the uniform distribution over the
+// domain.
+func (c *CDF) Result() *CDFResult {
+	if c.resultReturned {
+		log.Fatalf("The CDF has already been calculated and returned. It can only be returned once.")
+	}
+	c.resultReturned = true
+	hr := c.histogram.Result()
+
+	// The cumulative count of value lower+i is prefixSums[i+1]. The noise makes
+	// them non-monotone, which the isotonic regression fixes.
+	cumulative := isotonicRegression(hr.prefixSums[1:])
+	total := cumulative[len(cumulative)-1]
+	cdf := make([]float64, len(cumulative))
+	for i, cc := range cumulative {
+		if total <= 0 {
+			cdf[i] = float64(i+1) / float64(len(cdf))
+			continue
+		}
+		cdf[i] = math.Max(cc, 0) / total
+	}
+	// Avoids rounding errors so that Quantile always finds a value.
+	cdf[len(cdf)-1] = 1
+	return &CDFResult{lower: hr.lower, upper: hr.upper, cdf: cdf}
+}
+
+// isotonicRegression returns the non-decreasing sequence closest (in L2
+// distance) to y, using the pool adjacent violators algorithm.
+func isotonicRegression(y []float64) []float64 {
+	// Each block is a run of consecutive values that are replaced by their mean.
+	type block struct {
+		sum  float64
+		size int
+	}
+	blocks := make([]block, 0, len(y))
+	for _, v := range y {
+		blocks = append(blocks, block{sum: v, size: 1})
+		// Pools the last block with the previous ones as long as their means are
+		// decreasing.
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sum/float64(prev.size) <= last.sum/float64(last.size) {
+				break
+			}
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{sum: prev.sum + last.sum, size: prev.size + last.size}
+		}
+	}
+	result := make([]float64, 0, len(y))
+	for _, b := range blocks {
+		mean := b.sum / float64(b.size)
+		for i := 0; i < b.size; i++ {
+			result = append(result, mean)
+		}
+	}
+	return result
+}
+
+// CDFResult is the differentially private result of a CDF: a non-decreasing
+// cumulative distribution function over [lower, upper]. Since it is obtained
+// by post-processing, it can be queried any number of times without consuming
+// additional privacy budget.
+type CDFResult struct {
+	lower, upper int64
+	cdf          []float64 // cdf[i] is the estimated fraction of the values that are at most lower+i.
+}
+
+// CDF returns the estimated fraction of the values that are at most x. It is
+// 0 for x < Lower and 1 for x >= Upper.
+func (r *CDFResult) CDF(x int64) float64 {
+	if x < r.lower {
+		return 0
+	}
+	if x >= r.upper {
+		return 1
+	}
+	return r.cdf[x-r.lower]
+}
+
+// Quantile returns the estimated p-quantile of the values, i.e., the smallest
+// value x of the domain such that CDF(x) >= p. p is clamped to [0, 1], and
+// must not be NaN.
+func (r *CDFResult) Quantile(p float64) int64 {
+	if math.IsNaN(p) {
+		log.Fatalf("Quantile: p is NaN, should be in [0, 1]")
+	}
+	i := sort.Search(len(r.cdf), func(i int) bool { return r.cdf[i] >= p })
+	if i == len(r.cdf) {
+		// Only possible if p > 1.
+		return r.upper
+	}
+	return r.lower + int64(i)
+}
+
+// encodableCDF can be encoded by the gob package.
+type encodableCDF struct {
+	EncodableHistogram *HierarchicalHistogram
+	ResultReturned     bool
+}
+
+// GobEncode encodes CDF.
+func (c *CDF) GobEncode() ([]byte, error) {
+	enc := encodableCDF{
+		EncodableHistogram: &c.histogram,
+		ResultReturned:     c.resultReturned,
+	}
+	c.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes CDF.
+func (c *CDF) GobDecode(data []byte) error {
+	var enc encodableCDF
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode CDF from bytes")
+		return err
+	}
+	*c = CDF{
+		histogram:      *enc.EncodableHistogram,
+		resultReturned: enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func getNoiselessCDF() *CDF {
+	return NewCDF(&CDFOptions{
+		Epsilon: ln3,
+		Delta:   tenten,
+		Lower:   0,
+		Upper:   9,
+		Noise:   noNoise{},
+	})
+}
+
+func TestIsotonicRegression(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		y    []float64
+		want []float64
+	}{
+		{"already monotone", []float64{1, 2, 2, 5}, []float64{1, 2, 2, 5}},
+		{"single violation", []float64{1, 3, 2, 4}, []float64{1, 2.5, 2.5, 4}},
+		{"pooling cascades", []float64{1, 4, 3, 0}, []float64{1, 7.0 / 3.0, 7.0 / 3.0, 7.0 / 3.0}},
+		{"decreasing", []float64{3, 2, 1}, []float64{2, 2, 2}},
+		{"single value", []float64{-1}, []float64{-1}},
+	} {
+		got := isotonicRegression(tc.y)
+		if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 1e-10)) {
+			t.Errorf("isotonicRegression: when %s got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestCDFResult(t *testing.T) {
+	c := getNoiselessCDF()
+	// 10 values: 0, 0, 1, 2, 2, 2, 5, 9, 9 and 12, which is clamped to 9.
+	for _, x := range []int64{0, 0, 1, 2, 2, 2, 5, 9, 9, 12} {
+		c.Add(x)
+	}
+	r := c.Result()
+	for _, tc := range []struct {
+		x    int64
+		want float64
+	}{
+		{-1, 0},
+		{0, 0.2},
+		{1, 0.3},
+		{2, 0.6},
+		{4, 0.6},
+		{5, 0.7},
+		{8, 0.7},
+		{9, 1},
+		{100, 1},
+	} {
+		if got := r.CDF(tc.x); !ApproxEqual(got, tc.want) {
+			t.Errorf("CDF(%d): got %f, want %f", tc.x, got, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		p    float64
+		want int64
+	}{
+		{-1, 0},
+		{0, 0},
+		{0.2, 0},
+		{0.25, 1},
+		{0.5, 2},
+		{0.7, 5},
+		{0.71, 9},
+		{1, 9},
+		{2, 9},
+	} {
+		if got := r.Quantile(tc.p); got != tc.want {
+			t.Errorf("Quantile(%f): got %d, want %d", tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestCDFResultNoInput(t *testing.T) {
+	c := getNoiselessCDF()
+	r := c.Result()
+	// Without any data, the CDF is uniform over the domain.
+	if got := r.CDF(4); !ApproxEqual(got, 0.5) {
+		t.Errorf("CDF(4): got %f, want 0.5", got)
+	}
+	if got := r.Quantile(0.5); got != 4 {
+		t.Errorf("Quantile(0.5): got %d, want 4", got)
+	}
+}
+
+func TestCDFResultIsMonotone(t *testing.T) {
+	c := NewCDF(&CDFOptions{Epsilon: 0.1, Lower: -50, Upper: 50, BranchingFactor: 3})
+	for x := int64(-50); x <= 50; x += 7 {
+		c.Add(x)
+	}
+	r := c.Result()
+	prev := 0.0
+	for x := int64(-51); x <= 51; x++ {
+		got := r.CDF(x)
+		if got < prev || got > 1 {
+			t.Errorf("CDF(%d): got %f, want a value in [%f, 1]", x, got, prev)
+		}
+		prev = got
+	}
+	prevQ := int64(-50)
+	for p := 0.0; p <= 1; p += 0.01 {
+		got := r.Quantile(p)
+		if got < prevQ || got > 50 {
+			t.Errorf("Quantile(%f): got %d, want a value in [%d, 50]", p, got, prevQ)
+		}
+		prevQ = got
+	}
+}
+
+func TestCDFResultLargeBranchingFactor(t *testing.T) {
+	c := NewCDF(&CDFOptions{
+		Epsilon:         ln3,
+		Delta:           tenten,
+		Lower:           0,
+		Upper:           999,
+		BranchingFactor: 501,
+		Noise:           noNoise{},
+	})
+	for x := int64(0); x < 1000; x++ {
+		c.Add(x)
+	}
+	r := c.Result()
+	if got := r.CDF(499); !ApproxEqual(got, 0.5) {
+		t.Errorf("CDF(499): got %f, want 0.5", got)
+	}
+}
+
+func TestCDFMerge(t *testing.T) {
+	c1, c2 := getNoiselessCDF(), getNoiselessCDF()
+	c1.Add(1)
+	c2.Add(3)
+	c2.Add(5)
+	c2.Add(7)
+	c1.Merge(c2)
+	r := c1.Result()
+	if got := r.CDF(3); !ApproxEqual(got, 0.5) {
+		t.Errorf("Merge: got CDF(3) = %f, want 0.5", got)
+	}
+	if !c2.resultReturned {
+		t.Errorf("Merge: c2 should be marked as consumed")
+	}
+}
+
+func TestCDFCheckMerge(t *testing.T) {
+	opt := CDFOptions{Epsilon: ln3, Lower: 0, Upper: 10}
+	for _, tc := range []struct {
+		desc    string
+		modify  func(*CDFOptions)
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", func(*CDFOptions) {}, false, false, false},
+		{"c1 returned its result", func(*CDFOptions) {}, true, false, true},
+		{"c2 returned its result", func(*CDFOptions) {}, false, true, true},
+		{"different domain", func(o *CDFOptions) { o.Lower = 1 }, false, false, true},
+		{"different branching factor", func(o *CDFOptions) { o.BranchingFactor = 4 }, false, false, true},
+		{"different max partitions contributed", func(o *CDFOptions) { o.MaxPartitionsContributed = 2 }, false, false, true},
+	} {
+		opt2 := opt
+		tc.modify(&opt2)
+		c1, c2 := NewCDF(&opt), NewCDF(&opt2)
+		c1.resultReturned = tc.state1
+		c2.resultReturned = tc.state2
+		if err := checkMergeCDF(c1, c2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCDFSerialization(t *testing.T) {
+	opt := &CDFOptions{
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxContributionsPerPartition: 2,
+		Lower:                        -5,
+		Upper:                        5,
+		Noise:                        noise.Gaussian(),
+	}
+	c, cUnchanged := NewCDF(opt), NewCDF(opt)
+	c.Add(1)
+	cUnchanged.Add(1)
+	bytes, err := encode(c)
+	if err != nil {
+		t.Fatalf("encode(CDF) error: %v", err)
+	}
+	cUnmarshalled := new(CDF)
+	if err := decode(cUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(CDF) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(cUnchanged, cUnmarshalled, cmp.AllowUnexported(CDF{}, HierarchicalHistogram{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", cUnmarshalled, cUnchanged)
+	}
+	// Check that the original CDF has its resultReturned set to true after serialization.
+	if !c.resultReturned {
+		t.Errorf("CDF %v should have its resultReturned set to true after being serialized", c)
+	}
+}
@@ -0,0 +1,239 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// ContinualCount is a streaming counter that releases a differentially private
+// running total after each time step, for up to NumSteps time steps, using the
+// binary mechanism of Chan et al., "Private and Continual Release of
+// Statistics". The total privacy budget covers all the releases.
+//
+// Each running total is the sum of at most ⌊log₂(NumSteps)⌋+1 noisy partial
+// sums, so its error grows only polylogarithmically with the number of time
+// steps. In comparison, releasing a noisy Count per time step and adding them
+// up has an error that grows linearly with the number of time steps.
+//
+// ContinualCount supports privacy units contributing to multiple time steps
+// (MaxPartitionsContributed, defaults to 1) and contributing multiple times
+// to a single time step (MaxContributionsPerPartition, defaults to 1). The
+// aggregation itself doesn't ensure these limits are respected.
+//
+// Not thread-safe.
+type ContinualCount struct {
+	// Parameters
+	epsilon         float64
+	delta           float64
+	l0Sensitivity   int64
+	lInfSensitivity int64
+	numSteps        int64
+	noise           noise.Noise
+	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	step    int64   // number of time steps already ended
+	current int64   // count of the current time step
+	sums    []int64 // sums[i] is the exact partial sum of the last 2^i ended time steps, if bit i of step is set
+	noisy   []int64 // noisy[i] is the noisy version of sums[i]
+	// Whether the counter can no longer be used, because all time steps were
+	// ended or because it was serialized.
+	resultReturned bool
+}
+
+// ContinualCountOptions contains the options necessary to initialize a
+// ContinualCount.
+type ContinualCountOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct time steps may a single user contribute to? Defaults to 1.
+	// How many times may a single user contribute to a single time step?
+	// Equivalently, by how much may a single user increment a single time step
+	// in total? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Maximum number of time steps, i.e., of running totals released. Required.
+	NumSteps int64
+	Noise    noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewContinualCount returns a new ContinualCount, with a running total of 0
+// at the beginning of the first time step.
+func NewContinualCount(opt *ContinualCountOptions) *ContinualCount {
+	if opt == nil {
+		opt = &ContinualCountOptions{}
+	}
+	if opt.NumSteps <= 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewContinualCount: NumSteps is %d, should be strictly positive", opt.NumSteps)
+	}
+
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	levels := numLevels(opt.NumSteps)
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseInt64(0, l0*levels, lInf, eps, del)
+
+	return &ContinualCount{
+		epsilon:         eps,
+		delta:           del,
+		l0Sensitivity:   l0,
+		lInfSensitivity: lInf,
+		numSteps:        opt.NumSteps,
+		noise:           n,
+		noiseKind:       noise.ToKind(n),
+		sums:            make([]int64, levels),
+		noisy:           make([]int64, levels),
+		resultReturned:  false,
+	}
+}
+
+// numLevels returns the number of partial sums a single time step contributes
+// to, i.e., the number of bits needed to write numSteps.
+func numLevels(numSteps int64) int64 {
+	levels := int64(0)
+	for ; numSteps > 0; numSteps >>= 1 {
+		levels++
+	}
+	return levels
+}
+
+// Increment increments the count of the current time step by one.
+func (c *ContinualCount) Increment() {
+	c.IncrementBy(1)
+}
+
+// IncrementBy increments the count of the current time step by x. If
+// MaxContributionsPerPartition is set, x must be less than or equal to it.
+func (c *ContinualCount) IncrementBy(x int64) {
+	if c.resultReturned {
+		log.Fatalf("The continual count cannot be amended: all its time steps were already ended, or it was serialized.")
+	}
+	c.current += x
+}
+
+// EndStep ends the current time step and returns a differentially private
+// version of the running total, i.e., of the count of all the time steps
+// ended so far. It can be called at most NumSteps times, after which no
+// further operation can be done on the ContinualCount.
+func (c *ContinualCount) EndStep() int64 {
+	if c.resultReturned {
+		log.Fatalf("The continual count cannot be amended: all its time steps were already ended, or it was serialized.")
+	}
+	c.step++
+	// The partial sums of the levels below the lowest set bit of step are
+	// merged, together with the current time step, into the partial sum of that
+	// bit, which is then noised.
+	i := 0
+	for (c.step>>uint(i))&1 == 0 {
+		i++
+	}
+	sum := c.current
+	for j := 0; j < i; j++ {
+		sum += c.sums[j]
+		c.sums[j], c.noisy[j] = 0, 0
+	}
+	c.sums[i] = sum
+	c.noisy[i] = c.noise.AddNoiseInt64(sum, c.l0Sensitivity*int64(len(c.sums)), c.lInfSensitivity, c.epsilon, c.delta)
+	c.current = 0
+	if c.step == c.numSteps {
+		c.resultReturned = true
+	}
+
+	var total int64
+	for j := range c.noisy {
+		if (c.step>>uint(j))&1 == 1 {
+			total += c.noisy[j]
+		}
+	}
+	return total
+}
+
+// encodableContinualCount can be encoded by the gob package.
+type encodableContinualCount struct {
+	Epsilon         float64
+	Delta           float64
+	L0Sensitivity   int64
+	LInfSensitivity int64
+	NumSteps        int64
+	NoiseKind       noise.Kind
+	Step            int64
+	Current         int64
+	Sums            []int64
+	Noisy           []int64
+	ResultReturned  bool
+}
+
+// GobEncode encodes ContinualCount, e.g., to checkpoint it between two time
+// steps. The noisy partial sums are part of the encoded state, so that the
+// decoded ContinualCount keeps releasing consistent running totals.
+func (c *ContinualCount) GobEncode() ([]byte, error) {
+	enc := encodableContinualCount{
+		Epsilon:         c.epsilon,
+		Delta:           c.delta,
+		L0Sensitivity:   c.l0Sensitivity,
+		LInfSensitivity: c.lInfSensitivity,
+		NumSteps:        c.numSteps,
+		NoiseKind:       noise.ToKind(c.noise),
+		Step:            c.step,
+		Current:         c.current,
+		Sums:            c.sums,
+		Noisy:           c.noisy,
+		ResultReturned:  c.resultReturned,
+	}
+	c.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes ContinualCount.
+func (c *ContinualCount) GobDecode(data []byte) error {
+	var enc encodableContinualCount
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode ContinualCount from bytes")
+		return err
+	}
+	*c = ContinualCount{
+		epsilon:         enc.Epsilon,
+		delta:           enc.Delta,
+		l0Sensitivity:   enc.L0Sensitivity,
+		lInfSensitivity: enc.LInfSensitivity,
+		numSteps:        enc.NumSteps,
+		noiseKind:       enc.NoiseKind,
+		noise:           noise.ToNoise(enc.NoiseKind),
+		step:            enc.Step,
+		current:         enc.Current,
+		sums:            enc.Sums,
+		noisy:           enc.Noisy,
+		resultReturned:  enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+// addOneNoise adds 1 to every noised value, which makes it possible to count
+// how many noisy partial sums contribute to a running total.
+type addOneNoise struct {
+	noise.Noise
+	l0Sensitivity int64
+}
+
+func (n *addOneNoise) AddNoiseInt64(x, l0, _ int64, _, _ float64) int64 {
+	n.l0Sensitivity = l0
+	return x + 1
+}
+
+func TestNumLevels(t *testing.T) {
+	for _, tc := range []struct {
+		numSteps, want int64
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 2},
+		{4, 3},
+		{24, 5},
+		{1 << 20, 21},
+	} {
+		if got := numLevels(tc.numSteps); got != tc.want {
+			t.Errorf("numLevels(%d): got %d, want %d", tc.numSteps, got, tc.want)
+		}
+	}
+}
+
+func TestContinualCountRunningTotals(t *testing.T) {
+	c := NewContinualCount(&ContinualCountOptions{
+		Epsilon:  ln3,
+		Delta:    tenten,
+		NumSteps: 10,
+		Noise:    noNoise{},
+	})
+	var want int64
+	for step := int64(1); step <= 10; step++ {
+		c.IncrementBy(step)
+		c.Increment()
+		want += step + 1
+		if got := c.EndStep(); got != want {
+			t.Errorf("EndStep: at step %d got %d, want %d", step, got, want)
+		}
+	}
+	if !c.resultReturned {
+		t.Errorf("ContinualCount should have its resultReturned set to true after its last time step")
+	}
+}
+
+func TestContinualCountNoisyPartialSums(t *testing.T) {
+	n := &addOneNoise{}
+	c := NewContinualCount(&ContinualCountOptions{
+		Epsilon:                  ln3,
+		MaxPartitionsContributed: 2,
+		NumSteps:                 24,
+		Noise:                    n,
+	})
+	for step := int64(1); step <= 24; step++ {
+		c.Increment()
+		// The running total is the sum of one noisy partial sum per set bit of step.
+		want := step + int64(bits.OnesCount64(uint64(step)))
+		if got := c.EndStep(); got != want {
+			t.Errorf("EndStep: at step %d got %d, want %d", step, got, want)
+		}
+	}
+	// Each time step contributes to one partial sum per level, and 24 has 5 bits.
+	if n.l0Sensitivity != 10 {
+		t.Errorf("EndStep: noise called with l0Sensitivity %d, want 10", n.l0Sensitivity)
+	}
+}
+
+func TestContinualCountSerialization(t *testing.T) {
+	opt := &ContinualCountOptions{
+		Epsilon:  ln3,
+		Delta:    tenten,
+		NumSteps: 8,
+		Noise:    noNoise{},
+	}
+	c, cUnchanged := NewContinualCount(opt), NewContinualCount(opt)
+	for _, cc := range []*ContinualCount{c, cUnchanged} {
+		for i := 0; i < 3; i++ {
+			cc.IncrementBy(2)
+			cc.EndStep()
+		}
+		cc.Increment()
+	}
+	bytes, err := encode(c)
+	if err != nil {
+		t.Fatalf("encode(ContinualCount) error: %v", err)
+	}
+	cUnmarshalled := new(ContinualCount)
+	if err := decode(cUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(ContinualCount) error: %v", err)
+	}
+	// noNoise is not serialized, so it is compared separately.
+	cUnmarshalled.noise = noNoise{}
+	cUnmarshalled.noiseKind = cUnchanged.noiseKind
+	if !cmp.Equal(cUnchanged, cUnmarshalled, cmp.AllowUnexported(ContinualCount{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", cUnmarshalled, cUnchanged)
+	}
+	// Check that the original ContinualCount has its resultReturned set to true after serialization.
+	if !c.resultReturned {
+		t.Errorf("ContinualCount %v should have its resultReturned set to true after being serialized", c)
+	}
+	// The decoded ContinualCount continues where the original one stopped.
+	if got := cUnmarshalled.EndStep(); got != 7 {
+		t.Errorf("EndStep: after decoding got %d, want 7", got)
+	}
+}
@@ -0,0 +1,106 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"sort"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// BoundContributions selects which of n raw records to keep so that each
+// privacy ID (e.g., user) contributes to at most maxPartitionsContributed
+// distinct partitions, and contributes at most maxContributionsPerPartition
+// records to each of these partitions. These are the limits that the
+// MaxPartitionsContributed and MaxContributionsPerPartition options of the
+// aggregations in this package assume to hold.
+//
+// The i-th record is described by key(i), which returns its privacy ID and its
+// partition key. Both must be comparable, i.e., usable as map keys. For each
+// privacy ID, the kept partitions are sampled uniformly at random among the
+// partitions it contributes to, and for each kept partition, the kept records
+// are sampled uniformly at random among its records in that partition.
+//
+// BoundContributions returns the indices of the kept records in increasing
+// order. It returns an error if one of the limits is nonpositive.
+func BoundContributions(n int, key func(i int) (privacyID, partitionKey interface{}), maxPartitionsContributed, maxContributionsPerPartition int64) ([]int, error) {
+	if maxPartitionsContributed <= 0 {
+		return nil, &checks.ParameterError{Label: "BoundContributions", Parameter: "MaxPartitionsContributed", Value: maxPartitionsContributed, Constraint: checks.StrictlyPositive, AllowedRange: "(0, MaxInt64]"}
+	}
+	if maxContributionsPerPartition <= 0 {
+		return nil, &checks.ParameterError{Label: "BoundContributions", Parameter: "MaxContributionsPerPartition", Value: maxContributionsPerPartition, Constraint: checks.StrictlyPositive, AllowedRange: "(0, MaxInt64]"}
+	}
+
+	// Group the record indices per privacy ID, then per partition.
+	type partitionRecords struct {
+		indices []int
+	}
+	type idRecords struct {
+		partitions     []*partitionRecords
+		partitionIndex map[interface{}]*partitionRecords
+	}
+	ids := make(map[interface{}]*idRecords)
+	for i := 0; i < n; i++ {
+		id, pk := key(i)
+		r, ok := ids[id]
+		if !ok {
+			r = &idRecords{partitionIndex: make(map[interface{}]*partitionRecords)}
+			ids[id] = r
+		}
+		p, ok := r.partitionIndex[pk]
+		if !ok {
+			p = &partitionRecords{}
+			r.partitionIndex[pk] = p
+			r.partitions = append(r.partitions, p)
+		}
+		p.indices = append(p.indices, i)
+	}
+
+	var kept []int
+	for _, r := range ids {
+		// Cross-partition (L_0) bounding.
+		numPartitions := sampleUniformly(len(r.partitions), maxPartitionsContributed, func(i, j int) {
+			r.partitions[i], r.partitions[j] = r.partitions[j], r.partitions[i]
+		})
+		for _, p := range r.partitions[:numPartitions] {
+			// Per-partition (L_∞) bounding.
+			numRecords := sampleUniformly(len(p.indices), maxContributionsPerPartition, func(i, j int) {
+				p.indices[i], p.indices[j] = p.indices[j], p.indices[i]
+			})
+			kept = append(kept, p.indices[:numRecords]...)
+		}
+	}
+	sort.Ints(kept)
+	return kept, nil
+}
+
+// sampleUniformly moves a uniformly random subset of min(n, limit) elements
+// of an n-element collection to its first positions, using a partial
+// Fisher-Yates shuffle with a cryptographically secure source of randomness.
+// It returns min(n, limit).
+func sampleUniformly(n int, limit int64, swap func(i, j int)) int {
+	if int64(n) <= limit {
+		return n
+	}
+	k := int(limit)
+	for i := 0; i < k; i++ {
+		j := i + int(rand.I63n(int64(n-i)))
+		swap(i, j)
+	}
+	return k
+}
@@ -0,0 +1,129 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/go-cmp/cmp"
+)
+
+type testRecord struct {
+	id, partition int
+}
+
+func boundTestRecords(t *testing.T, records []testRecord, l0, lInf int64) []int {
+	t.Helper()
+	kept, err := BoundContributions(len(records), func(i int) (interface{}, interface{}) {
+		return records[i].id, records[i].partition
+	}, l0, lInf)
+	if err != nil {
+		t.Fatalf("BoundContributions: got err %v", err)
+	}
+	return kept
+}
+
+func TestBoundContributionsKeepsRecordsWithinLimits(t *testing.T) {
+	records := []testRecord{{0, 0}, {0, 1}, {1, 0}, {0, 0}, {1, 1}, {2, 2}}
+	got := boundTestRecords(t, records, 2, 2)
+	want := []int{0, 1, 2, 3, 4, 5}
+	if !cmp.Equal(got, want) {
+		t.Errorf("BoundContributions: got %v, want %v", got, want)
+	}
+}
+
+func TestBoundContributionsEnforcesLimits(t *testing.T) {
+	var records []testRecord
+	for id := 0; id < 10; id++ {
+		for partition := 0; partition < 5; partition++ {
+			for i := 0; i < 4; i++ {
+				records = append(records, testRecord{id, partition})
+			}
+		}
+	}
+	kept := boundTestRecords(t, records, 3, 2)
+	if !sort.IntsAreSorted(kept) {
+		t.Errorf("BoundContributions: got unsorted indices %v", kept)
+	}
+	partitionsPerID := make(map[int]map[int]int)
+	for _, i := range kept {
+		r := records[i]
+		if partitionsPerID[r.id] == nil {
+			partitionsPerID[r.id] = make(map[int]int)
+		}
+		partitionsPerID[r.id][r.partition]++
+	}
+	for id := 0; id < 10; id++ {
+		if got := len(partitionsPerID[id]); got != 3 {
+			t.Errorf("BoundContributions: privacy ID %d contributes to %d partitions, want 3", id, got)
+		}
+		for partition, got := range partitionsPerID[id] {
+			if got != 2 {
+				t.Errorf("BoundContributions: privacy ID %d contributes %d records to partition %d, want 2", id, got, partition)
+			}
+		}
+	}
+}
+
+func TestBoundContributionsSamplesUniformly(t *testing.T) {
+	// A single privacy ID contributes to 4 partitions, one of which is kept.
+	// Each partition should be kept with probability 1/4.
+	records := []testRecord{{0, 0}, {0, 1}, {0, 2}, {0, 3}}
+	const numTrials = 10000
+	counts := make([]int, 4)
+	for i := 0; i < numTrials; i++ {
+		kept := boundTestRecords(t, records, 1, 1)
+		if len(kept) != 1 {
+			t.Fatalf("BoundContributions: got %d records, want 1", len(kept))
+		}
+		counts[kept[0]]++
+	}
+	// The count of each partition has a standard deviation of about 43; a
+	// tolerance of 300 makes this test flaky with negligible probability.
+	for partition, c := range counts {
+		if c < numTrials/4-300 || c > numTrials/4+300 {
+			t.Errorf("BoundContributions: partition %d kept %d times out of %d, want approximately %d", partition, c, numTrials, numTrials/4)
+		}
+	}
+}
+
+func TestBoundContributionsInvalidLimits(t *testing.T) {
+	key := func(i int) (interface{}, interface{}) { return i, i }
+	for _, tc := range []struct {
+		desc          string
+		l0, lInf      int64
+		wantParameter string
+	}{
+		{"zero maxPartitionsContributed", 0, 1, "MaxPartitionsContributed"},
+		{"negative maxPartitionsContributed", -1, 1, "MaxPartitionsContributed"},
+		{"zero maxContributionsPerPartition", 1, 0, "MaxContributionsPerPartition"},
+		{"negative maxContributionsPerPartition", 1, -1, "MaxContributionsPerPartition"},
+	} {
+		_, err := BoundContributions(1, key, tc.l0, tc.lInf)
+		var perr *checks.ParameterError
+		if !errors.As(err, &perr) {
+			t.Errorf("BoundContributions: when %s got err %v, want a *checks.ParameterError", tc.desc, err)
+			continue
+		}
+		if perr.Parameter != tc.wantParameter {
+			t.Errorf("BoundContributions: when %s got an error on %s, want %s", tc.desc, perr.Parameter, tc.wantParameter)
+		}
+	}
+}
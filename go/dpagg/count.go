@@ -20,15 +20,18 @@ import (
 	"fmt"
 
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 )
 
 // Count calculates a differentially private count of a collection of values.
 //
 // It supports scaling the noise in the case where users can contribute to
-// multiple partitions (via the MaxPartitionsContributed parameter).
-// However, it does not support multiple contributions to a single partition from
-// the same user. For that use case, BoundedSumInt64 should be used instead.
+// multiple partitions (via the MaxPartitionsContributed parameter) and can
+// contribute to a single partition multiple times (via the
+// MaxContributionsPerPartition parameter). Count does not enforce these limits:
+// the caller is responsible for bounding the contributions of each user, e.g.
+// with BoundContributions.
 //
 // Note: Do not use when your results may cause overflows for int64 values.
 // This aggregation is not hardened for such applications yet.
@@ -42,6 +45,7 @@ type Count struct {
 	lInfSensitivity int64
 	noise           noise.Noise
 	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+	rounding        Rounding
 
 	// State variables
 	count          int64
@@ -53,7 +57,8 @@ func countEquallyInitialized(c1, c2 *Count) bool {
 		c1.delta == c2.delta &&
 		c1.l0Sensitivity == c2.l0Sensitivity &&
 		c1.lInfSensitivity == c2.lInfSensitivity &&
-		c1.noiseKind == c2.noiseKind
+		c1.noiseKind == c2.noiseKind &&
+		c1.rounding == c2.rounding
 }
 
 // CountOptions contains the options necessary to initialize a Count.
@@ -63,9 +68,37 @@ type CountOptions struct {
 	MaxPartitionsContributed int64       // How many distinct partitions may a single user contribute to? Defaults to 1.
 	Noise                    noise.Noise // Type of noise used. Defaults to Laplace noise.
 	// How many times may a single user contribute to a single partition?
-	// Defaults to 1. This is only needed for other aggregation functions using Count;
-	// which is why the option is not exported.
-	maxContributionsPerPartition int64
+	// Equivalently, by how much may a single user increment a single Count in
+	// total? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Rounding applied to the result. Defaults to no rounding.
+	Rounding Rounding
+}
+
+// Validate returns an error if NewCount would fail with these options. It
+// does not build a Count, and does not consume randomness. Only the parameters
+// of noise.Laplace() and noise.Gaussian() are checked: with another Noise,
+// NewCount may still fail.
+func (opt *CountOptions) Validate() error {
+	if opt == nil {
+		opt = &CountOptions{}
+	}
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	if err := checkRounding("dpagg.CountOptions", opt.Rounding); err != nil {
+		return err
+	}
+	return noise.CheckParameters("dpagg.CountOptions", n, l0, float64(lInf), opt.Epsilon, opt.Delta)
 }
 
 // NewCount returns a new Count, initialized at 0.
@@ -79,7 +112,7 @@ func NewCount(opt *CountOptions) *Count {
 		l0 = 1
 	}
 
-	lInf := opt.maxContributionsPerPartition
+	lInf := opt.MaxContributionsPerPartition
 	if lInf == 0 {
 		lInf = 1
 	}
@@ -88,6 +121,10 @@ func NewCount(opt *CountOptions) *Count {
 	if n == nil {
 		n = noise.Laplace()
 	}
+	if err := checkRounding("NewCount", opt.Rounding); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("checkRounding(%+v) failed with %v", opt.Rounding, err)
+	}
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
 	eps, del := opt.Epsilon, opt.Delta
@@ -100,6 +137,7 @@ func NewCount(opt *CountOptions) *Count {
 		lInfSensitivity: lInf,
 		noise:           n,
 		noiseKind:       noise.ToKind(n),
+		rounding:        opt.Rounding,
 		count:           0,
 		resultReturned:  false,
 	}
@@ -111,8 +149,9 @@ func (c *Count) Increment() {
 }
 
 // IncrementBy increments the count by the given value.
-// Note that this shouldn't be used to count multiple contributions to a
-// single partition from the same user.
+// Note that the total increment from a single user must not exceed
+// MaxContributionsPerPartition. To count records with non-integer weights, use
+// WeightedCount.
 func (c *Count) IncrementBy(count int64) {
 	if c.resultReturned {
 		log.Fatalf("The count has already been calculated and returned. It cannot be amended.")
@@ -120,6 +159,20 @@ func (c *Count) IncrementBy(count int64) {
 	c.count += count
 }
 
+// IncrementBatch increments the count by each of the given values. It is
+// equivalent to calling IncrementBy on each of them, but only checks once
+// that the result hasn't been returned.
+func (c *Count) IncrementBatch(counts []int64) {
+	if c.resultReturned {
+		log.Fatalf("The count has already been calculated and returned. It cannot be amended.")
+	}
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	c.count += total
+}
+
 // Merge merges c2 into c (i.e., adds to c all entries that were added to c2).
 // c2 is consumed by this operation: it may not be used after it is merged
 // into c.
@@ -150,6 +203,12 @@ func checkMergeCount(c1, c2 *Count) error {
 // be called only once, after which no further operation can be done on the
 // Count.
 func (c *Count) Result() int64 {
+	return c.rounding.RoundInt64(c.noisedResult())
+}
+
+// noisedResult returns the noised count, before rounding. Like Result, it can
+// be called only once.
+func (c *Count) noisedResult() int64 {
 	if c.resultReturned {
 		log.Fatalf("The count has already been calculated and returned. It can only be returned once.")
 	}
@@ -159,16 +218,46 @@ func (c *Count) Result() int64 {
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise
-// mechanism, it returns nil. Otherwise, it returns the result.
+// mechanism, it returns nil. Otherwise, it returns the result. Thresholding
+// is applied before rounding.
 func (c *Count) ThresholdedResult(deltaThreshold float64) *int64 {
 	threshold := c.noise.Threshold(c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, deltaThreshold)
-	result := c.Result()
+	result := c.noisedResult()
 	if result < int64(threshold) {
 		return nil
 	}
+	result = c.rounding.RoundInt64(result)
 	return &result
 }
 
+// ResultWithConfidenceInterval is similar to Result() but additionally returns
+// a confidence interval that contains the true (unnoised) count with a
+// probability of at least 1 - alpha. The interval is derived from the noise
+// parameters used to compute the result. Its bounds aren't clamped to 0, since
+// IncrementBy accepts negative increments. It is computed from the result
+// before rounding, and its bounds are not rounded.
+//
+// An error is returned, and the Count is left untouched, if alpha is not
+// strictly between 0 and 1, or if the noise doesn't implement
+// noise.ConfidenceIntervalComputer.
+func (c *Count) ResultWithConfidenceInterval(alpha float64) (int64, noise.ConfidenceInterval, error) {
+	const label = "dpagg.Count.ResultWithConfidenceInterval"
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	ciNoise, err := confidenceIntervalComputer(label, c.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	noisedResult := c.noisedResult()
+	result := c.rounding.RoundInt64(noisedResult)
+	ci, err := ciNoise.ComputeConfidenceIntervalInt64(noisedResult, c.l0Sensitivity, c.lInfSensitivity, c.epsilon, c.delta, alpha)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+	return result, ci, nil
+}
+
 // encodableCount can be encoded by the gob package.
 type encodableCount struct {
 	Epsilon         float64
@@ -176,6 +265,7 @@ type encodableCount struct {
 	L0Sensitivity   int64
 	LInfSensitivity int64
 	NoiseKind       noise.Kind
+	Rounding        Rounding
 	Count           int64
 	ResultReturned  bool
 }
@@ -188,6 +278,7 @@ func (c *Count) GobEncode() ([]byte, error) {
 		L0Sensitivity:   c.l0Sensitivity,
 		LInfSensitivity: c.lInfSensitivity,
 		NoiseKind:       noise.ToKind(c.noise),
+		Rounding:        c.rounding,
 		Count:           c.count,
 		ResultReturned:  c.resultReturned,
 	}
@@ -210,6 +301,7 @@ func (c *Count) GobDecode(data []byte) error {
 		lInfSensitivity: enc.LInfSensitivity,
 		noiseKind:       enc.NoiseKind,
 		noise:           noise.ToNoise(enc.NoiseKind),
+		rounding:        enc.Rounding,
 		count:           enc.Count,
 		resultReturned:  enc.ResultReturned,
 	}
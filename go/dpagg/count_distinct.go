@@ -0,0 +1,319 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+const (
+	// countDistinctLevels is the number of bitmaps of a CountDistinct sketch.
+	countDistinctLevels = 32
+	// countDistinctMaxFill is the largest fraction of set bits of the bitmap
+	// used as the base level of the estimation.
+	countDistinctMaxFill = 0.5
+)
+
+// CountDistinct calculates a differentially private estimate of the number of
+// distinct values in a collection (e.g., distinct products viewed), using a
+// fixed amount of memory regardless of the number of distinct values.
+//
+// Values are inserted into a mergeable multiresolution bitmap sketch
+// (Estan et al., "Bitmap Algorithms for Counting Active Flows on High Speed
+// Links"): each value is hashed to a level ℓ with probability 2^-(ℓ+1), and to
+// a bit of the NumBits-bit bitmap of that level. The sketch itself is not
+// differentially private; only the released estimate is. When computing the
+// result, the number of set bits of each level is noised, and the number of
+// distinct values is estimated with linear counting on the lowest level that
+// is not too full and on all the levels above it.
+//
+// Each user inserts their values with AddUserValues, which bounds the number of
+// distinct values of that user to MaxContributionsPerPartition. Since a user's
+// values set at most MaxContributionsPerPartition bits, this also bounds the
+// sensitivity of the number of set bits.
+//
+// CountDistinct supports users contributing to multiple partitions (via the
+// MaxPartitionsContributed parameter). The aggregation itself doesn't ensure
+// this limit is respected.
+//
+// Not thread-safe.
+type CountDistinct struct {
+	// Parameters
+	epsilon         float64
+	delta           float64
+	l0Sensitivity   int64
+	lInfSensitivity int64
+	numBits         int64
+	noise           noise.Noise
+	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	bitmaps        []uint64 // countDistinctLevels bitmaps of numBits bits each, level by level
+	resultReturned bool     // whether the result has already been returned
+}
+
+func countDistinctEquallyInitialized(cd1, cd2 *CountDistinct) bool {
+	return cd1.epsilon == cd2.epsilon &&
+		cd1.delta == cd2.delta &&
+		cd1.l0Sensitivity == cd2.l0Sensitivity &&
+		cd1.lInfSensitivity == cd2.lInfSensitivity &&
+		cd1.numBits == cd2.numBits &&
+		cd1.noiseKind == cd2.noiseKind
+}
+
+// CountDistinctOptions contains the options necessary to initialize a
+// CountDistinct.
+type CountDistinctOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	// How many distinct values may a single user insert into a single partition?
+	// Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Number of bits of the bitmap of each level, a multiple of 64. Larger
+	// bitmaps give more accurate estimates. The sketch uses 32 bitmaps.
+	// Defaults to 4096.
+	NumBits int64
+	Noise   noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewCountDistinct returns a new CountDistinct with an empty sketch.
+func NewCountDistinct(opt *CountDistinctOptions) *CountDistinct {
+	if opt == nil {
+		opt = &CountDistinctOptions{}
+	}
+
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	numBits := opt.NumBits
+	if numBits == 0 {
+		numBits = 4096
+	}
+	if numBits < 0 || numBits%64 != 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewCountDistinct: NumBits is %d, should be a strictly positive multiple of 64", numBits)
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseInt64(0, l0, lInf, eps, del)
+
+	return &CountDistinct{
+		epsilon:         eps,
+		delta:           del,
+		l0Sensitivity:   l0,
+		lInfSensitivity: lInf,
+		numBits:         numBits,
+		noise:           n,
+		noiseKind:       noise.ToKind(n),
+		bitmaps:         make([]uint64, countDistinctLevels*numBits/64),
+		resultReturned:  false,
+	}
+}
+
+// AddUserValues inserts all the values contributed by a single user into the
+// sketch. If the user has more than MaxContributionsPerPartition distinct
+// values, only MaxContributionsPerPartition of them, chosen uniformly at random,
+// are inserted. All the values of a user must be added in a single call.
+func (cd *CountDistinct) AddUserValues(values []string) {
+	if cd.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The count distinct has already been calculated and returned. It cannot be amended.")
+	}
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			distinct = append(distinct, v)
+		}
+	}
+	k := sampleUniformly(len(distinct), cd.lInfSensitivity, func(i, j int) {
+		distinct[i], distinct[j] = distinct[j], distinct[i]
+	})
+	for _, v := range distinct[:k] {
+		cd.insert(v)
+	}
+}
+
+// insert sets the bit of the sketch corresponding to v.
+func (cd *CountDistinct) insert(v string) {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	hash := mix64(h.Sum64())
+	// The lower 32 bits determine the level, and the upper 32 bits the bit.
+	level := int64(bits.TrailingZeros32(uint32(hash)))
+	if level >= countDistinctLevels {
+		level = countDistinctLevels - 1
+	}
+	bit := level*cd.numBits + int64(hash>>32)%cd.numBits
+	cd.bitmaps[bit/64] |= 1 << uint(bit%64)
+}
+
+// mix64 is the finalizer of the SplitMix64 generator, which spreads the
+// entropy of x over all of its bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Merge merges cd2 into cd (i.e., inserts into cd all values that were
+// inserted into cd2). cd2 is consumed by this operation: cd2 may not be used
+// after it is merged into cd.
+//
+// Users must not contribute to both cd and cd2 with different values, since
+// their total number of distinct values would then not be bounded.
+func (cd *CountDistinct) Merge(cd2 *CountDistinct) {
+	if err := checkMergeCountDistinct(cd, cd2); err != nil {
+		log.Exit(err)
+	}
+	for i, w := range cd2.bitmaps {
+		cd.bitmaps[i] |= w
+	}
+	cd2.resultReturned = true
+}
+
+func checkMergeCountDistinct(cd1, cd2 *CountDistinct) error {
+	if cd1.resultReturned {
+		return fmt.Errorf("checkMergeCountDistinct: cd1 already returned the result, cannot be merged with another CountDistinct instance")
+	}
+	if cd2.resultReturned {
+		return fmt.Errorf("checkMergeCountDistinct: cd2 already returned the result, cannot be merged with another CountDistinct instance")
+	}
+	if !countDistinctEquallyInitialized(cd1, cd2) {
+		return fmt.Errorf("checkMergeCountDistinct: cd1 and cd2 are not compatible")
+	}
+	return nil
+}
+
+// Result returns a differentially private estimate of the number of distinct
+// values inserted so far. It can be called only once, after which no further
+// operation can be done on the CountDistinct.
+func (cd *CountDistinct) Result() int64 {
+	if cd.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The count distinct has already been calculated and returned. It can only be returned once.")
+	}
+	cd.resultReturned = true
+	wordsPerLevel := cd.numBits / 64
+	noisyOnes := make([]float64, countDistinctLevels)
+	for l := range noisyOnes {
+		var ones int64
+		for _, w := range cd.bitmaps[int64(l)*wordsPerLevel : int64(l+1)*wordsPerLevel] {
+			ones += int64(bits.OnesCount64(w))
+		}
+		// A user sets at most lInfSensitivity bits over all levels, so the
+		// sensitivity of each level is also the sensitivity of all levels.
+		noisy := cd.noise.AddNoiseInt64(ones, cd.l0Sensitivity, cd.lInfSensitivity, cd.epsilon, cd.delta)
+		// A full bitmap is treated as having a single unset bit, to keep the
+		// estimate finite.
+		noisyOnes[l] = math.Min(math.Max(0, float64(noisy)), float64(cd.numBits-1))
+	}
+	return int64(math.Round(estimateDistinct(noisyOnes, float64(cd.numBits))))
+}
+
+// estimateDistinct estimates the number of distinct values from the number of
+// set bits of each level of a multiresolution bitmap with m bits per level.
+func estimateDistinct(ones []float64, m float64) float64 {
+	// The base level is the lowest level that is not too full for linear
+	// counting. It and the levels above it receive a 2^-base fraction of the
+	// distinct values.
+	base := len(ones) - 1
+	for l, o := range ones {
+		if o <= countDistinctMaxFill*m {
+			base = l
+			break
+		}
+	}
+	var estimate float64
+	for _, o := range ones[base:] {
+		// Linear counting estimate of the number of distinct values of the level.
+		estimate += -m * math.Log1p(-o/m)
+	}
+	return math.Ldexp(estimate, base)
+}
+
+// encodableCountDistinct can be encoded by the gob package.
+type encodableCountDistinct struct {
+	Epsilon         float64
+	Delta           float64
+	L0Sensitivity   int64
+	LInfSensitivity int64
+	NumBits         int64
+	NoiseKind       noise.Kind
+	Bitmaps         []uint64
+	ResultReturned  bool
+}
+
+// GobEncode encodes CountDistinct.
+func (cd *CountDistinct) GobEncode() ([]byte, error) {
+	enc := encodableCountDistinct{
+		Epsilon:         cd.epsilon,
+		Delta:           cd.delta,
+		L0Sensitivity:   cd.l0Sensitivity,
+		LInfSensitivity: cd.lInfSensitivity,
+		NumBits:         cd.numBits,
+		NoiseKind:       noise.ToKind(cd.noise),
+		Bitmaps:         cd.bitmaps,
+		ResultReturned:  cd.resultReturned,
+	}
+	cd.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes CountDistinct.
+func (cd *CountDistinct) GobDecode(data []byte) error {
+	var enc encodableCountDistinct
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode CountDistinct from bytes")
+		return err
+	}
+	*cd = CountDistinct{
+		epsilon:         enc.Epsilon,
+		delta:           enc.Delta,
+		l0Sensitivity:   enc.L0Sensitivity,
+		lInfSensitivity: enc.LInfSensitivity,
+		numBits:         enc.NumBits,
+		noiseKind:       enc.NoiseKind,
+		noise:           noise.ToNoise(enc.NoiseKind),
+		bitmaps:         enc.Bitmaps,
+		resultReturned:  enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessCountDistinct(maxContributionsPerPartition int64) *CountDistinct {
+	return NewCountDistinct(&CountDistinctOptions{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestNewCountDistinct(t *testing.T) {
+	cd := NewCountDistinct(&CountDistinctOptions{Epsilon: ln3})
+	want := &CountDistinct{
+		epsilon:         ln3,
+		l0Sensitivity:   1,
+		lInfSensitivity: 1,
+		numBits:         4096,
+		noise:           noise.Laplace(),
+		noiseKind:       noise.LaplaceNoise,
+		bitmaps:         make([]uint64, 32*4096/64),
+	}
+	if !cmp.Equal(cd, want, cmp.AllowUnexported(CountDistinct{})) {
+		t.Errorf("NewCountDistinct: got %+v, want %+v", cd, want)
+	}
+}
+
+func TestCountDistinctResult(t *testing.T) {
+	for _, numValues := range []int{0, 10, 1000, 100000} {
+		cd := getNoiselessCountDistinct(2)
+		for i := 0; i < numValues; i++ {
+			// Each value is inserted twice, by two different users.
+			cd.AddUserValues([]string{strconv.Itoa(i)})
+			cd.AddUserValues([]string{strconv.Itoa(i), strconv.Itoa(i)})
+		}
+		got := cd.Result()
+		// The relative standard error of linear counting with 4096 bits is at most
+		// a few percent.
+		if math.Abs(float64(got)-float64(numValues)) > 0.05*float64(numValues) {
+			t.Errorf("Result: with %d distinct values got %d", numValues, got)
+		}
+	}
+}
+
+func TestCountDistinctBoundsUserValues(t *testing.T) {
+	cd := getNoiselessCountDistinct(2)
+	cd.AddUserValues([]string{"a", "b", "c", "d", "e", "a"})
+	if got := cd.Result(); got != 2 {
+		t.Errorf("AddUserValues: got %d distinct values, want 2", got)
+	}
+}
+
+func TestEstimateDistinct(t *testing.T) {
+	m := 100.0
+	ones := make([]float64, countDistinctLevels)
+	// Levels 0 and 1 are too full, so the base level is 2.
+	ones[0], ones[1], ones[2], ones[3] = 90, 60, 50, 25
+	want := 4 * (-m*math.Log(0.5) - m*math.Log(0.75))
+	if got := estimateDistinct(ones, m); !ApproxEqual(got, want) {
+		t.Errorf("estimateDistinct: got %f, want %f", got, want)
+	}
+}
+
+func TestCountDistinctMerge(t *testing.T) {
+	cd1, cd2 := getNoiselessCountDistinct(1), getNoiselessCountDistinct(1)
+	for i := 0; i < 30; i++ {
+		cd1.AddUserValues([]string{strconv.Itoa(i)})
+	}
+	for i := 20; i < 50; i++ {
+		cd2.AddUserValues([]string{strconv.Itoa(i)})
+	}
+	cd1.Merge(cd2)
+	if got := cd1.Result(); got < 48 || got > 52 {
+		t.Errorf("Merge: got %d distinct values, want approximately 50", got)
+	}
+	if !cd2.resultReturned {
+		t.Errorf("Merge: cd2 should be marked as consumed")
+	}
+}
+
+func TestCountDistinctCheckMerge(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt1    *CountDistinctOptions
+		opt2    *CountDistinctOptions
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", &CountDistinctOptions{Epsilon: ln3}, &CountDistinctOptions{Epsilon: ln3}, false, false, false},
+		{"cd1 returned its result", &CountDistinctOptions{Epsilon: ln3}, &CountDistinctOptions{Epsilon: ln3}, true, false, true},
+		{"cd2 returned its result", &CountDistinctOptions{Epsilon: ln3}, &CountDistinctOptions{Epsilon: ln3}, false, true, true},
+		{"different sizes", &CountDistinctOptions{Epsilon: ln3}, &CountDistinctOptions{Epsilon: ln3, NumBits: 64}, false, false, true},
+		{"different contribution bounds", &CountDistinctOptions{Epsilon: ln3}, &CountDistinctOptions{Epsilon: ln3, MaxContributionsPerPartition: 2}, false, false, true},
+	} {
+		cd1, cd2 := NewCountDistinct(tc.opt1), NewCountDistinct(tc.opt2)
+		cd1.resultReturned = tc.state1
+		cd2.resultReturned = tc.state2
+		if err := checkMergeCountDistinct(cd1, cd2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCountDistinctSerialization(t *testing.T) {
+	opt := &CountDistinctOptions{
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 3,
+		NumBits:                      128,
+		Noise:                        noise.Gaussian(),
+	}
+	cd, cdUnchanged := NewCountDistinct(opt), NewCountDistinct(opt)
+	cd.AddUserValues([]string{"a", "b"})
+	cdUnchanged.AddUserValues([]string{"a", "b"})
+	bytes, err := encode(cd)
+	if err != nil {
+		t.Fatalf("encode(CountDistinct) error: %v", err)
+	}
+	cdUnmarshalled := new(CountDistinct)
+	if err := decode(cdUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(CountDistinct) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(cdUnchanged, cdUnmarshalled, cmp.AllowUnexported(CountDistinct{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", cdUnmarshalled, cdUnchanged)
+	}
+	// Check that the original CountDistinct has its resultReturned set to true after serialization.
+	if !cd.resultReturned {
+		t.Errorf("CountDistinct %v should have its resultReturned set to true after being serialized", cd)
+	}
+}
@@ -17,9 +17,11 @@
 package dpagg
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/go-cmp/cmp"
 )
@@ -35,7 +37,7 @@ func TestNewCount(t *testing.T) {
 				Epsilon:                      ln3,
 				Delta:                        tenten,
 				Noise:                        noNoise{},
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&Count{
 				epsilon:         ln3,
@@ -46,7 +48,7 @@ func TestNewCount(t *testing.T) {
 				count:           0,
 				resultReturned:  false,
 			}},
-		{"maxContributionsPerPartition is not set",
+		{"MaxContributionsPerPartition is not set",
 			&CountOptions{
 				Epsilon:                  ln3,
 				Delta:                    0,
@@ -68,7 +70,7 @@ func TestNewCount(t *testing.T) {
 				Epsilon:                      ln3,
 				Delta:                        0,
 				MaxPartitionsContributed:     1,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&Count{
 				epsilon:         ln3,
@@ -95,6 +97,7 @@ func compareCount(c1, c2 *Count) bool {
 		c1.lInfSensitivity == c2.lInfSensitivity &&
 		c1.noise == c2.noise &&
 		c1.noiseKind == c2.noiseKind &&
+		c1.rounding == c2.rounding &&
 		c1.count == c2.count &&
 		c1.resultReturned == c2.resultReturned
 }
@@ -114,6 +117,7 @@ func TestCountSerialization(t *testing.T) {
 			Delta:                    1e-5,
 			MaxPartitionsContributed: 5,
 			Noise:                    noise.Gaussian(),
+			Rounding:                 Rounding{Granularity: 10},
 		}},
 	} {
 		c, cUnchanged := NewCount(tc.opts), NewCount(tc.opts)
@@ -168,6 +172,18 @@ func TestCountIncrementBy(t *testing.T) {
 	}
 }
 
+func TestCountIncrementBatch(t *testing.T) {
+	count := getNoiselessCount()
+	count.Increment()
+	count.IncrementBatch([]int64{1, 2, 3})
+	count.IncrementBatch(nil)
+	got := count.Result()
+	const want = 7
+	if got != want {
+		t.Errorf("IncrementBatch: after adding 1 and [1, 2, 3] got %d, want %d", got, want)
+	}
+}
+
 func TestCountMerge(t *testing.T) {
 	c1 := getNoiselessCount()
 	c2 := getNoiselessCount()
@@ -202,14 +218,14 @@ func TestCountCheckMerge(t *testing.T) {
 				Delta:                        tenten,
 				MaxPartitionsContributed:     1,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&CountOptions{
 				Epsilon:                      ln3,
 				Delta:                        tenten,
 				MaxPartitionsContributed:     1,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			false,
 			false,
@@ -280,14 +296,14 @@ func TestCountCheckMerge(t *testing.T) {
 			false,
 			false,
 			true},
-		{"different maxContributionsPerPartition",
+		{"different MaxContributionsPerPartition",
 			&CountOptions{
 				Epsilon:                      ln3,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&CountOptions{
 				Epsilon:                      ln3,
-				maxContributionsPerPartition: 5,
+				MaxContributionsPerPartition: 5,
 			},
 			false,
 			false,
@@ -343,6 +359,105 @@ func TestCountThresholdedResult(t *testing.T) {
 	}
 }
 
+func TestCountRounding(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		increments  int64
+		rounding    Rounding
+		want        int64
+		wantNil     bool
+		thresholded bool
+	}{
+		{"Result with granularity", 16, Rounding{Granularity: 10}, 20, false, false},
+		{"Result with significant digits", 1234, Rounding{SignificantDigits: 2}, 1200, false, false},
+		// Thresholding is applied to the noisy count (6 > 5) before rounding.
+		{"ThresholdedResult above threshold", 6, Rounding{Granularity: 20}, 0, false, true},
+		{"ThresholdedResult below threshold", 2, Rounding{Granularity: 20}, 0, true, true},
+	} {
+		c := NewCount(&CountOptions{Epsilon: ln3, Delta: tenten, Noise: noNoise{}, Rounding: tc.rounding})
+		c.IncrementBy(tc.increments)
+		if !tc.thresholded {
+			if got := c.Result(); got != tc.want {
+				t.Errorf("Result: when %s got %d, want %d", tc.desc, got, tc.want)
+			}
+			continue
+		}
+		got := c.ThresholdedResult(tenten)
+		if (got == nil) != tc.wantNil {
+			t.Fatalf("ThresholdedResult: when %s got %v, wantNil %t", tc.desc, got, tc.wantNil)
+		}
+		if got != nil && *got != tc.want {
+			t.Errorf("ThresholdedResult: when %s got %d, want %d", tc.desc, *got, tc.want)
+		}
+	}
+}
+
+func TestCountResultWithConfidenceInterval(t *testing.T) {
+	for _, tc := range []struct {
+		desc                 string
+		increments           int64
+		wantLower, wantUpper float64
+	}{
+		{"positive count", 10, 9, 11},
+		{"empty count", 0, -1, 1},
+		// IncrementBy accepts negative increments, so the interval isn't clamped
+		// to 0.
+		{"negative count", -3, -4, -2},
+	} {
+		c := getNoiselessCount()
+		c.IncrementBy(tc.increments)
+		got, ci, err := c.ResultWithConfidenceInterval(0.05)
+		if err != nil {
+			t.Fatalf("ResultWithConfidenceInterval: when %s got err %v", tc.desc, err)
+		}
+		if got != tc.increments {
+			t.Errorf("ResultWithConfidenceInterval: when %s got result %d, want %d", tc.desc, got, tc.increments)
+		}
+		want := noise.ConfidenceInterval{LowerBound: tc.wantLower, UpperBound: tc.wantUpper, ConfidenceLevel: 0.95}
+		if !cmp.Equal(ci, want) {
+			t.Errorf("ResultWithConfidenceInterval: when %s got %+v, want %+v", tc.desc, ci, want)
+		}
+	}
+}
+
+func TestCountResultWithConfidenceIntervalInvalidAlpha(t *testing.T) {
+	c := getNoiselessCount()
+	c.Increment()
+	if _, _, err := c.ResultWithConfidenceInterval(1); err == nil {
+		t.Errorf("ResultWithConfidenceInterval(1): got no error")
+	}
+	// The Count must still be usable after an invalid call.
+	if got := c.Result(); got != 1 {
+		t.Errorf("Result: after an invalid ResultWithConfidenceInterval call got %d, want 1", got)
+	}
+}
+
+func TestCountResultWithConfidenceIntervalUnsupportedNoise(t *testing.T) {
+	c := NewCount(&CountOptions{Epsilon: ln3, Delta: tenten, Noise: noiseWithoutConfidenceIntervals{noNoise{}}})
+	c.Increment()
+	if _, _, err := c.ResultWithConfidenceInterval(0.05); err == nil {
+		t.Errorf("ResultWithConfidenceInterval: with a noise that doesn't compute confidence intervals got no error")
+	}
+	// The Count must still be usable after an unsupported call.
+	if got := c.Result(); got != 1 {
+		t.Errorf("Result: after an unsupported ResultWithConfidenceInterval call got %d, want 1", got)
+	}
+}
+
+func TestCountResultWithConfidenceIntervalLaplace(t *testing.T) {
+	c := NewCount(&CountOptions{Epsilon: ln3, MaxPartitionsContributed: 2})
+	c.IncrementBy(100)
+	got, ci, err := c.ResultWithConfidenceInterval(1.0 / 3.0)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	// The half-width of the interval is -log(alpha)*l0Sensitivity*lInfSensitivity/epsilon = 2.
+	if ci.LowerBound > float64(got)-2 || ci.LowerBound < float64(got)-3 ||
+		ci.UpperBound < float64(got)+2 || ci.UpperBound > float64(got)+3 {
+		t.Errorf("ResultWithConfidenceInterval: for result %d got %+v, want approximately [%d, %d]", got, ci, got-2, got+2)
+	}
+}
+
 type mockNoiseCount struct {
 	t *testing.T
 	noise.Noise
@@ -393,7 +508,7 @@ func getMockCount(t *testing.T) *Count {
 		Epsilon:                      ln3,
 		Delta:                        tenten,
 		MaxPartitionsContributed:     3,
-		maxContributionsPerPartition: 2,
+		MaxContributionsPerPartition: 2,
 		Noise:                        mockNoiseCount{t: t},
 	})
 }
@@ -534,3 +649,47 @@ func TestCountEquallyInitialized(t *testing.T) {
 		}
 	}
 }
+
+func TestCountOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *CountOptions
+		wantErr bool
+	}{
+		{"valid Laplace", &CountOptions{Epsilon: ln3}, false},
+		{"valid Gaussian", &CountOptions{Epsilon: ln3, Delta: 1e-5, Noise: noise.Gaussian()}, false},
+		{"nil options", nil, true},
+		{"zero epsilon", &CountOptions{}, true},
+		{"Laplace with delta", &CountOptions{Epsilon: ln3, Delta: 1e-5}, true},
+		{"Gaussian without delta", &CountOptions{Epsilon: ln3, Noise: noise.Gaussian()}, true},
+		{"negative MaxPartitionsContributed", &CountOptions{Epsilon: ln3, MaxPartitionsContributed: -1}, true},
+		{"negative MaxContributionsPerPartition", &CountOptions{Epsilon: ln3, MaxContributionsPerPartition: -1}, true},
+		{"valid Rounding", &CountOptions{Epsilon: ln3, Rounding: Rounding{SignificantDigits: 2}}, false},
+		{"invalid Rounding", &CountOptions{Epsilon: ln3, Rounding: Rounding{Granularity: -1}}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCountOptionsValidateReturnsParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		opt           interface{ Validate() error }
+		wantParameter string
+	}{
+		{"Laplace with delta", &CountOptions{Epsilon: ln3, Delta: tenfive}, "Delta"},
+		{"invalid Rounding", &CountOptions{Epsilon: ln3, Rounding: Rounding{Granularity: -1}}, "Rounding.Granularity"},
+	} {
+		err := tc.opt.Validate()
+		var perr *checks.ParameterError
+		if !errors.As(err, &perr) {
+			t.Errorf("Validate: for %s got err %v, want a *checks.ParameterError", tc.desc, err)
+			continue
+		}
+		if perr.Parameter != tc.wantParameter {
+			t.Errorf("Validate: for %s got an error on %s, want %s", tc.desc, perr.Parameter, tc.wantParameter)
+		}
+	}
+}
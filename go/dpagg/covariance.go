@@ -0,0 +1,381 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// BoundedCovarianceFloat64 calculates differentially private second-order
+// statistics of a collection of float64 pairs (x, y): the covariance of x and
+// y, their Pearson correlation, and the slope and intercept of the simple
+// linear regression of y on x.
+//
+// It releases a noisy count of the pairs and noisy sums of x, y, xy, x² and y²,
+// from which all statistics are derived in a post-processing step. As in
+// BoundedMeanFloat64, x and y are normalized by subtracting the middle of
+// their input range before summation, which makes the sensitivity of each sum
+// (and hence its noise) smaller; the noisy count is set to at least 1. Each of
+// the six noisy values uses a sixth of the privacy budget.
+//
+// BoundedCovarianceFloat64 supports scaling the noise in the case where users
+// can contribute to multiple partitions (via the MaxPartitionsContributed
+// parameter) and can contribute to a single partition multiple times (via the
+// MaxContributionsPerPartition parameter).
+//
+// Note: Do not use when your results may cause overflows for int64 or float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// Not thread-safe.
+type BoundedCovarianceFloat64 struct {
+	// Parameters
+	lowerX float64
+	upperX float64
+	lowerY float64
+	upperY float64
+
+	// State variables
+	count Count
+	// Sums of the normalized values x-midPointX and y-midPointY.
+	normalizedSumX  BoundedSumFloat64
+	normalizedSumY  BoundedSumFloat64
+	normalizedSumXY BoundedSumFloat64
+	// Sums of the squares of the normalized values, themselves normalized by
+	// subtracting the middle of their range.
+	normalizedSumXX BoundedSumFloat64
+	normalizedSumYY BoundedSumFloat64
+	// The midpoints between lower and upper bounds. They cannot be set by the
+	// user; they are calculated based on the lower and upper values.
+	midPointX      float64
+	midPointY      float64
+	resultReturned bool // whether the result has already been returned
+}
+
+func bcEquallyInitializedFloat64(bc1, bc2 *BoundedCovarianceFloat64) bool {
+	return bc1.lowerX == bc2.lowerX &&
+		bc1.upperX == bc2.upperX &&
+		bc1.lowerY == bc2.lowerY &&
+		bc1.upperY == bc2.upperY &&
+		countEquallyInitialized(&bc1.count, &bc2.count) &&
+		bsEquallyInitializedFloat64(&bc1.normalizedSumX, &bc2.normalizedSumX) &&
+		bsEquallyInitializedFloat64(&bc1.normalizedSumY, &bc2.normalizedSumY) &&
+		bsEquallyInitializedFloat64(&bc1.normalizedSumXY, &bc2.normalizedSumXY) &&
+		bsEquallyInitializedFloat64(&bc1.normalizedSumXX, &bc2.normalizedSumXX) &&
+		bsEquallyInitializedFloat64(&bc1.normalizedSumYY, &bc2.normalizedSumYY)
+}
+
+// BoundedCovarianceFloat64Options contains the options necessary to initialize
+// a BoundedCovarianceFloat64.
+type BoundedCovarianceFloat64Options struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	Delta                        float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Required.
+	// Lower and Upper bounds for clamping x and y. Must be such that LowerX < UpperX
+	// and LowerY < UpperY.
+	LowerX, UpperX float64
+	LowerY, UpperY float64
+	Noise          noise.Noise // Type of noise used in BoundedCovariance. Defaults to Laplace noise.
+}
+
+// NewBoundedCovarianceFloat64 returns a new BoundedCovarianceFloat64.
+func NewBoundedCovarianceFloat64(opt *BoundedCovarianceFloat64Options) *BoundedCovarianceFloat64 {
+	if opt == nil {
+		opt = &BoundedCovarianceFloat64Options{}
+	}
+
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedCovarianceFloat64 requires a value for MaxContributionsPerPartition")
+	}
+
+	// Set defaults.
+	maxPartitionsContributed := opt.MaxPartitionsContributed
+	if maxPartitionsContributed == 0 {
+		maxPartitionsContributed = 1
+	}
+
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check bounds & use them to compute L_∞ sensitivities.
+	for _, b := range []struct {
+		name         string
+		lower, upper float64
+	}{
+		{"x", opt.LowerX, opt.UpperX},
+		{"y", opt.LowerY, opt.UpperY},
+	} {
+		if err := checks.CheckBoundsFloat64("NewBoundedCovarianceFloat64 ("+b.name+")", b.lower, b.upper); err != nil {
+			// TODO: do not exit the program from within library code
+			log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", b.lower, b.upper, err)
+		}
+		if b.lower == b.upper {
+			// TODO: do not exit the program from within library code
+			log.Fatalf("NewBoundedCovarianceFloat64 requires Lower%s < Upper%s, got %f and %f", b.name, b.name, b.lower, b.upper)
+		}
+	}
+	// (lower + upper) / 2 may cause an overflow if lower and upper are large values.
+	midPointX := opt.LowerX + (opt.UpperX-opt.LowerX)/2.0
+	midPointY := opt.LowerY + (opt.UpperY-opt.LowerY)/2.0
+	maxDistX := math.Abs(opt.UpperX - midPointX)
+	maxDistY := math.Abs(opt.UpperY - midPointY)
+
+	// We split the budget evenly between the count and the five sums.
+	eps, del := opt.Epsilon/6, opt.Delta/6
+
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	n.AddNoiseFloat64(0, 1, 1, eps, del)
+
+	count := NewCount(&CountOptions{
+		Epsilon:                      eps,
+		Delta:                        del,
+		MaxPartitionsContributed:     maxPartitionsContributed,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		Noise:                        n,
+	})
+	newSum := func(bound float64) BoundedSumFloat64 {
+		return *NewBoundedSumFloat64(&BoundedSumFloat64Options{
+			Epsilon:                      eps,
+			Delta:                        del,
+			MaxPartitionsContributed:     maxPartitionsContributed,
+			MaxContributionsPerPartition: maxContributionsPerPartition,
+			Lower:                        -bound,
+			Upper:                        bound,
+			Noise:                        n,
+		})
+	}
+
+	return &BoundedCovarianceFloat64{
+		lowerX:          opt.LowerX,
+		upperX:          opt.UpperX,
+		lowerY:          opt.LowerY,
+		upperY:          opt.UpperY,
+		count:           *count,
+		normalizedSumX:  newSum(maxDistX),
+		normalizedSumY:  newSum(maxDistY),
+		normalizedSumXY: newSum(maxDistX * maxDistY),
+		// (x-midPointX)² is in [0, maxDistX²], so (x-midPointX)² - maxDistX²/2 is in
+		// [-maxDistX²/2, maxDistX²/2].
+		normalizedSumXX: newSum(maxDistX * maxDistX / 2),
+		normalizedSumYY: newSum(maxDistY * maxDistY / 2),
+		midPointX:       midPointX,
+		midPointY:       midPointY,
+		resultReturned:  false,
+	}
+}
+
+// Add adds a pair (x, y) to a BoundedCovarianceFloat64. It skips pairs where x
+// or y is NaN and doesn't count them in the final result, because introducing
+// even a single NaN entry would result in NaN statistics regardless of other
+// entries, which would break the indistinguishability property required for
+// differential privacy.
+func (bc *BoundedCovarianceFloat64) Add(x, y float64) {
+	if bc.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The covariance has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return
+	}
+	clampedX, err := ClampFloat64(x, bc.lowerX, bc.upperX)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("Couldn't clamp input value %v, err %v", x, err)
+	}
+	clampedY, err := ClampFloat64(y, bc.lowerY, bc.upperY)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("Couldn't clamp input value %v, err %v", y, err)
+	}
+	nx := clampedX - bc.midPointX
+	ny := clampedY - bc.midPointY
+	bc.count.Increment()
+	bc.normalizedSumX.Add(nx)
+	bc.normalizedSumY.Add(ny)
+	bc.normalizedSumXY.Add(nx * ny)
+	bc.normalizedSumXX.Add(nx*nx - bc.normalizedSumXX.upper)
+	bc.normalizedSumYY.Add(ny*ny - bc.normalizedSumYY.upper)
+}
+
+// BoundedCovarianceResult holds the differentially private statistics released
+// by a BoundedCovarianceFloat64. All of them are derived from the same noisy
+// count and sums.
+type BoundedCovarianceResult struct {
+	MeanX, MeanY         float64
+	VarianceX, VarianceY float64
+	Covariance           float64
+	// Pearson correlation coefficient of x and y, in [-1, 1]. It is 0 if one of
+	// the variances is 0.
+	Correlation float64
+	// Slope and intercept of the least-squares line y = Slope*x + Intercept. The
+	// slope is 0 if the variance of x is 0.
+	Slope, Intercept float64
+}
+
+// Result returns differentially private statistics of the pairs added so far.
+// It can be called only once, after which no further operation can be done on
+// the BoundedCovarianceFloat64.
+func (bc *BoundedCovarianceFloat64) Result() BoundedCovarianceResult {
+	if bc.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The covariance has already been calculated and returned. It can only be returned once.")
+	}
+	bc.resultReturned = true
+	n := math.Max(1.0, float64(bc.count.Result()))
+	maxDistX, maxDistY := bc.normalizedSumX.upper, bc.normalizedSumY.upper
+
+	// Means of the normalized values, clamped to their range.
+	meanX := boundFloat64(bc.normalizedSumX.Result()/n, -maxDistX, maxDistX)
+	meanY := boundFloat64(bc.normalizedSumY.Result()/n, -maxDistY, maxDistY)
+	// Means of the squares of the normalized values, clamped to their range.
+	meanXX := boundFloat64(bc.normalizedSumXX.Result()/n+bc.normalizedSumXX.upper, 0, maxDistX*maxDistX)
+	meanYY := boundFloat64(bc.normalizedSumYY.Result()/n+bc.normalizedSumYY.upper, 0, maxDistY*maxDistY)
+	meanXY := bc.normalizedSumXY.Result() / n
+
+	// The variances and the covariance are invariant to the normalization.
+	varX := math.Max(0, meanXX-meanX*meanX)
+	varY := math.Max(0, meanYY-meanY*meanY)
+	// By the Cauchy-Schwarz inequality, |cov| <= sqrt(varX * varY).
+	maxCov := math.Sqrt(varX * varY)
+	cov := boundFloat64(meanXY-meanX*meanY, -maxCov, maxCov)
+
+	r := BoundedCovarianceResult{
+		MeanX:      meanX + bc.midPointX,
+		MeanY:      meanY + bc.midPointY,
+		VarianceX:  varX,
+		VarianceY:  varY,
+		Covariance: cov,
+	}
+	if maxCov > 0 {
+		r.Correlation = cov / maxCov
+	}
+	if varX > 0 {
+		r.Slope = cov / varX
+	}
+	r.Intercept = r.MeanY - r.Slope*r.MeanX
+	return r
+}
+
+// boundFloat64 clamps e to [lower, upper], assuming that lower <= upper.
+func boundFloat64(e, lower, upper float64) float64 {
+	return math.Min(math.Max(e, lower), upper)
+}
+
+// Merge merges bc2 into bc (i.e., adds to bc all entries that were added to
+// bc2). bc2 is consumed by this operation: bc2 may not be used after it is
+// merged into bc.
+func (bc *BoundedCovarianceFloat64) Merge(bc2 *BoundedCovarianceFloat64) {
+	if err := checkMergeBoundedCovarianceFloat64(bc, bc2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	bc.count.count += bc2.count.count
+	bc.normalizedSumX.sum += bc2.normalizedSumX.sum
+	bc.normalizedSumY.sum += bc2.normalizedSumY.sum
+	bc.normalizedSumXY.sum += bc2.normalizedSumXY.sum
+	bc.normalizedSumXX.sum += bc2.normalizedSumXX.sum
+	bc.normalizedSumYY.sum += bc2.normalizedSumYY.sum
+	bc2.resultReturned = true
+}
+
+func checkMergeBoundedCovarianceFloat64(bc1, bc2 *BoundedCovarianceFloat64) error {
+	if bc1.resultReturned {
+		return fmt.Errorf("checkMergeBoundedCovarianceFloat64: bc1 already returned the result, cannot be merged with another BoundedCovariance instance")
+	}
+	if bc2.resultReturned {
+		return fmt.Errorf("checkMergeBoundedCovarianceFloat64: bc2 already returned the result, cannot be merged with another BoundedCovariance instance")
+	}
+
+	if !bcEquallyInitializedFloat64(bc1, bc2) {
+		return fmt.Errorf("checkMergeBoundedCovarianceFloat64: bc1 and bc2 are not compatible")
+	}
+
+	return nil
+}
+
+// GobEncode encodes BoundedCovarianceFloat64.
+func (bc *BoundedCovarianceFloat64) GobEncode() ([]byte, error) {
+	enc := encodableBoundedCovarianceFloat64{
+		LowerX:                   bc.lowerX,
+		UpperX:                   bc.upperX,
+		LowerY:                   bc.lowerY,
+		UpperY:                   bc.upperY,
+		EncodableCount:           &bc.count,
+		EncodableNormalizedSumX:  &bc.normalizedSumX,
+		EncodableNormalizedSumY:  &bc.normalizedSumY,
+		EncodableNormalizedSumXY: &bc.normalizedSumXY,
+		EncodableNormalizedSumXX: &bc.normalizedSumXX,
+		EncodableNormalizedSumYY: &bc.normalizedSumYY,
+		MidPointX:                bc.midPointX,
+		MidPointY:                bc.midPointY,
+		ResultReturned:           bc.resultReturned,
+	}
+	bc.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedCovarianceFloat64.
+func (bc *BoundedCovarianceFloat64) GobDecode(data []byte) error {
+	var enc encodableBoundedCovarianceFloat64
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode BoundedCovarianceFloat64 from bytes")
+		return err
+	}
+	*bc = BoundedCovarianceFloat64{
+		lowerX:          enc.LowerX,
+		upperX:          enc.UpperX,
+		lowerY:          enc.LowerY,
+		upperY:          enc.UpperY,
+		count:           *enc.EncodableCount,
+		normalizedSumX:  *enc.EncodableNormalizedSumX,
+		normalizedSumY:  *enc.EncodableNormalizedSumY,
+		normalizedSumXY: *enc.EncodableNormalizedSumXY,
+		normalizedSumXX: *enc.EncodableNormalizedSumXX,
+		normalizedSumYY: *enc.EncodableNormalizedSumYY,
+		midPointX:       enc.MidPointX,
+		midPointY:       enc.MidPointY,
+		resultReturned:  enc.ResultReturned,
+	}
+	return nil
+}
+
+// encodableBoundedCovarianceFloat64 can be encoded by the gob package.
+type encodableBoundedCovarianceFloat64 struct {
+	LowerX                   float64
+	UpperX                   float64
+	LowerY                   float64
+	UpperY                   float64
+	EncodableCount           *Count
+	EncodableNormalizedSumX  *BoundedSumFloat64
+	EncodableNormalizedSumY  *BoundedSumFloat64
+	EncodableNormalizedSumXY *BoundedSumFloat64
+	EncodableNormalizedSumXX *BoundedSumFloat64
+	EncodableNormalizedSumYY *BoundedSumFloat64
+	MidPointX                float64
+	MidPointY                float64
+	ResultReturned           bool
+}
@@ -0,0 +1,199 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func getNoiselessBCFloat64() *BoundedCovarianceFloat64 {
+	return NewBoundedCovarianceFloat64(&BoundedCovarianceFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		LowerX:                       0,
+		UpperX:                       4,
+		LowerY:                       0,
+		UpperY:                       8,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestBCResultFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		points [][2]float64
+		want   BoundedCovarianceResult
+	}{
+		{"perfectly correlated",
+			[][2]float64{{1, 2}, {2, 4}, {3, 6}},
+			BoundedCovarianceResult{
+				MeanX: 2, MeanY: 4,
+				VarianceX: 2.0 / 3.0, VarianceY: 8.0 / 3.0,
+				Covariance:  4.0 / 3.0,
+				Correlation: 1,
+				Slope:       2, Intercept: 0,
+			}},
+		{"perfectly anticorrelated, with clamping and NaN",
+			[][2]float64{{0, 8}, {4, 0}, {-1, 10}, {math.NaN(), 1}, {1, math.NaN()}},
+			BoundedCovarianceResult{
+				MeanX: 4.0 / 3.0, MeanY: 16.0 / 3.0,
+				VarianceX: 32.0 / 9.0, VarianceY: 128.0 / 9.0,
+				Covariance:  -64.0 / 9.0,
+				Correlation: -1,
+				Slope:       -2, Intercept: 8,
+			}},
+		{"constant x",
+			[][2]float64{{1, 1}, {1, 3}},
+			BoundedCovarianceResult{
+				MeanX: 1, MeanY: 2,
+				VarianceX: 0, VarianceY: 1,
+				Covariance:  0,
+				Correlation: 0,
+				Slope:       0, Intercept: 2,
+			}},
+	} {
+		bc := getNoiselessBCFloat64()
+		for _, p := range tc.points {
+			bc.Add(p[0], p[1])
+		}
+		got := bc.Result()
+		if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 1e-10)) {
+			t.Errorf("Result: when %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestBCResultIsWithinBoundsFloat64(t *testing.T) {
+	// With noise and very few pairs, the derived statistics must still be
+	// consistent with each other.
+	for i := 0; i < 1000; i++ {
+		bc := NewBoundedCovarianceFloat64(&BoundedCovarianceFloat64Options{
+			Epsilon:                      ln3,
+			MaxContributionsPerPartition: 1,
+			LowerX:                       -1,
+			UpperX:                       1,
+			LowerY:                       0,
+			UpperY:                       10,
+		})
+		bc.Add(0.5, 3)
+		bc.Add(-0.5, 7)
+		got := bc.Result()
+		if got.MeanX < -1 || got.MeanX > 1 || got.MeanY < 0 || got.MeanY > 10 {
+			t.Fatalf("Result: got means (%f, %f), want them within the bounds", got.MeanX, got.MeanY)
+		}
+		if got.VarianceX < 0 || got.VarianceY < 0 {
+			t.Fatalf("Result: got variances (%f, %f), want them nonnegative", got.VarianceX, got.VarianceY)
+		}
+		if got.Correlation < -1 || got.Correlation > 1 {
+			t.Fatalf("Result: got correlation %f, want it within [-1, 1]", got.Correlation)
+		}
+	}
+}
+
+func TestBCMergeFloat64(t *testing.T) {
+	bc1 := getNoiselessBCFloat64()
+	bc2 := getNoiselessBCFloat64()
+	bc1.Add(1, 2)
+	bc2.Add(2, 4)
+	bc2.Add(3, 6)
+	bc1.Merge(bc2)
+	got := bc1.Result()
+	if !ApproxEqual(got.Slope, 2) || !ApproxEqual(got.Covariance, 4.0/3.0) {
+		t.Errorf("Merge: got %+v, want Slope 2 and Covariance 4/3", got)
+	}
+	if !bc2.resultReturned {
+		t.Errorf("Merge: bc2 should be marked as consumed")
+	}
+}
+
+func TestCheckMergeBCFloat64(t *testing.T) {
+	opt := BoundedCovarianceFloat64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 1,
+		LowerX:                       0,
+		UpperX:                       1,
+		LowerY:                       0,
+		UpperY:                       1,
+	}
+	for _, tc := range []struct {
+		desc    string
+		modify  func(*BoundedCovarianceFloat64Options)
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", func(*BoundedCovarianceFloat64Options) {}, false, false, false},
+		{"bc1 returned its result", func(*BoundedCovarianceFloat64Options) {}, true, false, true},
+		{"bc2 returned its result", func(*BoundedCovarianceFloat64Options) {}, false, true, true},
+		{"different x bounds", func(o *BoundedCovarianceFloat64Options) { o.UpperX = 2 }, false, false, true},
+		{"different y bounds", func(o *BoundedCovarianceFloat64Options) { o.LowerY = -1 }, false, false, true},
+		{"different epsilon", func(o *BoundedCovarianceFloat64Options) { o.Epsilon = 1 }, false, false, true},
+		{"different noise", func(o *BoundedCovarianceFloat64Options) {
+			o.Delta = tenten
+			o.Noise = noise.Gaussian()
+		}, false, false, true},
+	} {
+		opt2 := opt
+		tc.modify(&opt2)
+		bc1, bc2 := NewBoundedCovarianceFloat64(&opt), NewBoundedCovarianceFloat64(&opt2)
+		bc1.resultReturned = tc.state1
+		bc2.resultReturned = tc.state2
+		if err := checkMergeBoundedCovarianceFloat64(bc1, bc2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBCSerializationFloat64(t *testing.T) {
+	opt := &BoundedCovarianceFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        1e-5,
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 3,
+		LowerX:                       -1,
+		UpperX:                       1,
+		LowerY:                       0,
+		UpperY:                       100,
+		Noise:                        noise.Gaussian(),
+	}
+	bc, bcUnchanged := NewBoundedCovarianceFloat64(opt), NewBoundedCovarianceFloat64(opt)
+	bc.Add(0.5, 10)
+	bcUnchanged.Add(0.5, 10)
+	bytes, err := encode(bc)
+	if err != nil {
+		t.Fatalf("encode(BoundedCovarianceFloat64) error: %v", err)
+	}
+	bcUnmarshalled := new(BoundedCovarianceFloat64)
+	if err := decode(bcUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(BoundedCovarianceFloat64) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(bcUnchanged, bcUnmarshalled, cmp.AllowUnexported(BoundedCovarianceFloat64{}, Count{}, BoundedSumFloat64{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", bcUnmarshalled, bcUnchanged)
+	}
+	// Check that the original BoundedCovarianceFloat64 has its resultReturned set to true after serialization.
+	if !bc.resultReturned {
+		t.Errorf("BoundedCovarianceFloat64 %v should have its resultReturned set to true after being serialized", bc)
+	}
+}
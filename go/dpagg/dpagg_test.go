@@ -32,8 +32,8 @@ var (
 	tenfive = math.Pow10(-5)
 )
 
-// noNoise is a Noise instance that doesn't add noise to the data, and has a
-// threshold of 5.
+// noNoise is a Noise instance that doesn't add noise to the data, has a
+// threshold of 5, and returns confidence intervals of half-width 1.
 type noNoise struct {
 	noise.Noise
 }
@@ -53,3 +53,17 @@ func ApproxEqual(x, y float64) bool {
 func (noNoise) Threshold(_ int64, _, _, _, _ float64) float64 {
 	return 5
 }
+
+func (noNoise) ComputeConfidenceIntervalInt64(noisedX, _, _ int64, _, _, alpha float64) (noise.ConfidenceInterval, error) {
+	return noise.ConfidenceInterval{LowerBound: float64(noisedX) - 1, UpperBound: float64(noisedX) + 1, ConfidenceLevel: 1 - alpha}, nil
+}
+
+func (noNoise) ComputeConfidenceIntervalFloat64(noisedX float64, _ int64, _, _, _, alpha float64) (noise.ConfidenceInterval, error) {
+	return noise.ConfidenceInterval{LowerBound: noisedX - 1, UpperBound: noisedX + 1, ConfidenceLevel: 1 - alpha}, nil
+}
+
+// noiseWithoutConfidenceIntervals is a Noise instance that behaves like the
+// Noise it wraps, but doesn't implement noise.ConfidenceIntervalComputer.
+type noiseWithoutConfidenceIntervals struct {
+	noise.Noise
+}
@@ -16,7 +16,11 @@
 
 package dpagg
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/google/differential-privacy/go/noise"
+)
 
 // ClampFloat64 clamps e within lower and upper, such that lower is returned
 // if e < lower, and upper is returned if e > upper. Otherwise, e is returned.
@@ -49,3 +53,42 @@ func ClampInt64(e, lower, upper int64) (int64, error) {
 	}
 	return e, nil
 }
+
+// NeighbouringRelation is the definition of neighbouring datasets used to
+// compute the sensitivities of an aggregation.
+type NeighbouringRelation int
+
+const (
+	// AddOrRemoveOne is unbounded differential privacy: neighbouring datasets
+	// differ by all the contributions of a single privacy unit, which are
+	// present in one dataset and absent from the other. This is the default.
+	AddOrRemoveOne NeighbouringRelation = iota
+	// ReplaceOne is bounded differential privacy: neighbouring datasets have
+	// the same size, and differ by the values of the contributions of a single
+	// privacy unit, which contributes to the same partitions, as many times,
+	// in both. The L_∞ sensitivity of a sum is then computed from the width
+	// Upper - Lower of its bounds, instead of max(|Lower|, |Upper|).
+	ReplaceOne
+)
+
+func (r NeighbouringRelation) String() string {
+	switch r {
+	case AddOrRemoveOne:
+		return "AddOrRemoveOne"
+	case ReplaceOne:
+		return "ReplaceOne"
+	default:
+		return fmt.Sprintf("NeighbouringRelation(%d)", int(r))
+	}
+}
+
+// confidenceIntervalComputer returns n as a noise.ConfidenceIntervalComputer,
+// or an error if n can't compute confidence intervals, e.g., if it is a custom
+// Noise that doesn't implement that interface.
+func confidenceIntervalComputer(label string, n noise.Noise) (noise.ConfidenceIntervalComputer, error) {
+	ciNoise, ok := n.(noise.ConfidenceIntervalComputer)
+	if !ok {
+		return nil, fmt.Errorf("%s: noise %T doesn't implement noise.ConfidenceIntervalComputer, cannot compute confidence intervals", label, n)
+	}
+	return ciNoise, nil
+}
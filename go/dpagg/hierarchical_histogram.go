@@ -0,0 +1,422 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// maxHierarchicalHistogramDomainSize is the largest number of values in the
+// domain of a HierarchicalHistogram. The memory used by a HierarchicalHistogram
+// is proportional to the size of its domain.
+const maxHierarchicalHistogramDomainSize = 1 << 24
+
+// maxHierarchicalHistogramPadding is the largest ratio between the number of
+// leaves of the tree of a HierarchicalHistogram and the size of its domain.
+// The tree is padded with empty leaves to make it complete, so a branching
+// factor slightly larger than a root of the domain size (e.g., 501 for 1000
+// values, which needs 501² leaves) is lowered to keep the padding bounded.
+const maxHierarchicalHistogramPadding = 4
+
+// HierarchicalHistogram calculates a differentially private histogram over the
+// ordered integer domain [Lower, Upper] (e.g., days or price ranges) that can
+// answer any range query [a, b] with low error.
+//
+// It counts the values in a tree: the leaves are the values of the domain, and
+// each inner node counts the values of its BranchingFactor children. Every node
+// of the tree is noised, and the noisy counts are then made consistent (i.e.,
+// each inner node equals the sum of its children) with least-squares
+// post-processing, as described in Hay et al., "Boosting the Accuracy of
+// Differentially Private Histograms Through Consistency". The error of a range
+// query then grows polylogarithmically with the length of the range, instead
+// of linearly as when adding up the noisy counts of each value.
+//
+// HierarchicalHistogram supports privacy units contributing to multiple values
+// (MaxPartitionsContributed, defaults to 1) and multiple times to the same
+// value (MaxContributionsPerPartition, defaults to 1). The aggregation itself
+// doesn't ensure these limits are respected: the caller must enforce them,
+// e.g., with BoundContributions.
+//
+// Not thread-safe.
+type HierarchicalHistogram struct {
+	// Parameters
+	epsilon         float64
+	delta           float64
+	l0Sensitivity   int64
+	lInfSensitivity int64
+	lower           int64
+	upper           int64
+	branchingFactor int64
+	noise           noise.Noise
+	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	counts         []int64 // counts of the values of the domain, i.e., the leaves of the tree
+	resultReturned bool    // whether the result has already been returned
+}
+
+func hierarchicalHistogramEquallyInitialized(h1, h2 *HierarchicalHistogram) bool {
+	return h1.epsilon == h2.epsilon &&
+		h1.delta == h2.delta &&
+		h1.l0Sensitivity == h2.l0Sensitivity &&
+		h1.lInfSensitivity == h2.lInfSensitivity &&
+		h1.lower == h2.lower &&
+		h1.upper == h2.upper &&
+		h1.branchingFactor == h2.branchingFactor &&
+		h1.noiseKind == h2.noiseKind
+}
+
+// HierarchicalHistogramOptions contains the options necessary to initialize a
+// HierarchicalHistogram.
+type HierarchicalHistogramOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct values may a single user contribute to? Defaults to 1.
+	// How many times may a single user contribute the same value? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds of the domain, inclusive. Values outside of the
+	// domain are clamped to it. Required.
+	Lower, Upper int64
+	// Number of children of each inner node of the tree. Defaults to 2. Values
+	// for which the complete tree would have more than 4 times as many leaves as
+	// there are values in the domain are lowered to the smallest branching factor
+	// giving a tree of the same height.
+	BranchingFactor int64
+	Noise           noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewHierarchicalHistogram returns a new HierarchicalHistogram with all counts
+// initialized at 0.
+func NewHierarchicalHistogram(opt *HierarchicalHistogramOptions) *HierarchicalHistogram {
+	if opt == nil {
+		opt = &HierarchicalHistogramOptions{}
+	}
+	if err := checks.CheckBoundsInt64("NewHierarchicalHistogram", opt.Lower, opt.Upper); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("CheckBoundsInt64(lower %d, upper %d) failed with %v", opt.Lower, opt.Upper, err)
+	}
+	// Computed as a float64 to avoid overflows.
+	if float64(opt.Upper)-float64(opt.Lower)+1 > maxHierarchicalHistogramDomainSize {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewHierarchicalHistogram: domain [%d, %d] has more than %d values", opt.Lower, opt.Upper, maxHierarchicalHistogramDomainSize)
+	}
+
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	k := opt.BranchingFactor
+	if k == 0 {
+		k = 2
+	}
+	if k < 2 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewHierarchicalHistogram: BranchingFactor is %d, should be at least 2", k)
+	}
+	domainSize := opt.Upper - opt.Lower + 1
+	k = boundedBranchingFactor(domainSize, k)
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseInt64(0, l0*treeHeight(domainSize, k), lInf, eps, del)
+
+	return &HierarchicalHistogram{
+		epsilon:         eps,
+		delta:           del,
+		l0Sensitivity:   l0,
+		lInfSensitivity: lInf,
+		lower:           opt.Lower,
+		upper:           opt.Upper,
+		branchingFactor: k,
+		noise:           n,
+		noiseKind:       noise.ToKind(n),
+		counts:          make([]int64, domainSize),
+		resultReturned:  false,
+	}
+}
+
+// boundedBranchingFactor returns the branching factor to use instead of k for
+// a domain of domainSize values, so that the complete tree has at most
+// maxHierarchicalHistogramPadding times as many leaves as the domain. Lowering
+// the branching factor without changing the height of the tree doesn't change
+// the sensitivity, and only removes padding.
+func boundedBranchingFactor(domainSize, k int64) int64 {
+	height := treeHeight(domainSize, k)
+	if treeLeaves(height, k) <= maxHierarchicalHistogramPadding*domainSize {
+		return k
+	}
+	// Binary search for the smallest branching factor in [2, k] giving a tree
+	// of the same height, i.e., with at least domainSize leaves.
+	lo, hi := int64(2), k
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if treeLeaves(height, mid) >= domainSize {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+// treeHeight returns the number of levels of the smallest complete tree with
+// branching factor k that has at least numLeaves leaves.
+func treeHeight(numLeaves, k int64) int64 {
+	height := int64(1)
+	for width := int64(1); width < numLeaves; {
+		height++
+		if width > numLeaves/k {
+			// width*k > numLeaves: this is the last level, and multiplying could
+			// overflow.
+			break
+		}
+		width *= k
+	}
+	return height
+}
+
+// treeLeaves returns the number of leaves k^(height-1) of the complete tree of
+// the given height with branching factor k, or math.MaxInt64 if it overflows.
+func treeLeaves(height, k int64) int64 {
+	leaves := int64(1)
+	for l := int64(1); l < height; l++ {
+		if leaves > math.MaxInt64/k {
+			return math.MaxInt64
+		}
+		leaves *= k
+	}
+	return leaves
+}
+
+// Add adds a value to the HierarchicalHistogram. Values outside of [Lower,
+// Upper] are clamped to the domain.
+func (h *HierarchicalHistogram) Add(x int64) {
+	if h.resultReturned {
+		log.Fatalf("The hierarchical histogram has already been calculated and returned. It cannot be amended.")
+	}
+	if x < h.lower {
+		x = h.lower
+	}
+	if x > h.upper {
+		x = h.upper
+	}
+	h.counts[x-h.lower]++
+}
+
+// Merge merges h2 into h (i.e., adds to h all entries that were added to h2).
+// h2 is consumed by this operation: it may not be used after it is merged
+// into h.
+func (h *HierarchicalHistogram) Merge(h2 *HierarchicalHistogram) {
+	if err := checkMergeHierarchicalHistogram(h, h2); err != nil {
+		log.Exit(err)
+	}
+	for i, c := range h2.counts {
+		h.counts[i] += c
+	}
+	h2.resultReturned = true
+}
+
+func checkMergeHierarchicalHistogram(h1, h2 *HierarchicalHistogram) error {
+	if h1.resultReturned {
+		return fmt.Errorf("checkMergeHierarchicalHistogram: h1 already returned the result, cannot be merged with another HierarchicalHistogram instance")
+	}
+	if h2.resultReturned {
+		return fmt.Errorf("checkMergeHierarchicalHistogram: h2 already returned the result, cannot be merged with another HierarchicalHistogram instance")
+	}
+	if !hierarchicalHistogramEquallyInitialized(h1, h2) {
+		return fmt.Errorf("checkMergeHierarchicalHistogram: h1 and h2 are not compatible")
+	}
+	return nil
+}
+
+// Result noises every node of the tree, makes the noisy tree consistent and
+// returns it as a HierarchicalHistogramResult, which answers range queries. It
+// can be called only once, after which no further operation can be done on
+// the HierarchicalHistogram.
+func (h *HierarchicalHistogram) Result() *HierarchicalHistogramResult {
+	if h.resultReturned {
+		log.Fatalf("The hierarchical histogram has already been calculated and returned. It can only be returned once.")
+	}
+	h.resultReturned = true
+	k := int(h.branchingFactor)
+	height := treeHeight(int64(len(h.counts)), h.branchingFactor)
+
+	// tree[0] holds the leaves, padded with zeros to make the tree complete, and
+	// tree[len(tree)-1] holds the root.
+	tree := make([][]int64, height)
+	tree[0] = make([]int64, treeLeaves(height, h.branchingFactor))
+	copy(tree[0], h.counts)
+	for l := 1; l < len(tree); l++ {
+		tree[l] = make([]int64, len(tree[l-1])/k)
+		for i, c := range tree[l-1] {
+			tree[l][i/k] += c
+		}
+	}
+
+	// Each value contributes to one node per level.
+	l0 := h.l0Sensitivity * height
+	noisy := make([][]float64, height)
+	for l, level := range tree {
+		noisy[l] = make([]float64, len(level))
+		for i, c := range level {
+			noisy[l][i] = float64(h.noise.AddNoiseInt64(c, l0, h.lInfSensitivity, h.epsilon, h.delta))
+		}
+	}
+	leaves := consistentLeaves(noisy, k)
+
+	prefixSums := make([]float64, len(h.counts)+1)
+	for i := range h.counts {
+		prefixSums[i+1] = prefixSums[i] + leaves[i]
+	}
+	return &HierarchicalHistogramResult{lower: h.lower, upper: h.upper, prefixSums: prefixSums}
+}
+
+// consistentLeaves returns the leaves of the consistent tree closest (in L2
+// distance) to the given noisy complete tree with branching factor k, where
+// noisy[0] holds the leaves and noisy[len(noisy)-1] holds the root. It uses
+// the two-pass algorithm of Hay et al., "Boosting the Accuracy of
+// Differentially Private Histograms Through Consistency".
+func consistentLeaves(noisy [][]float64, k int) []float64 {
+	fk := float64(k)
+	// Bottom-up pass: z[l][i] is the weighted average of the noisy count of the
+	// node and of the sum of its children's z.
+	z := make([][]float64, len(noisy))
+	z[0] = append([]float64(nil), noisy[0]...)
+	kPow := fk // k^(l+1), where l+1 is the height of the nodes at level l.
+	for l := 1; l < len(noisy); l++ {
+		kPow *= fk
+		z[l] = make([]float64, len(noisy[l]))
+		for i := range z[l] {
+			var childrenSum float64
+			for _, c := range z[l-1][i*k : (i+1)*k] {
+				childrenSum += c
+			}
+			z[l][i] = ((kPow-kPow/fk)*noisy[l][i] + (kPow/fk-1)*childrenSum) / (kPow - 1)
+		}
+	}
+	// Top-down pass: the difference between a node and the sum of its
+	// children's z is split evenly among the children.
+	consistent := z[len(z)-1]
+	for l := len(z) - 2; l >= 0; l-- {
+		next := make([]float64, len(z[l]))
+		for i, parent := range consistent {
+			var childrenSum float64
+			for _, c := range z[l][i*k : (i+1)*k] {
+				childrenSum += c
+			}
+			for j := i * k; j < (i+1)*k; j++ {
+				next[j] = z[l][j] + (parent-childrenSum)/fk
+			}
+		}
+		consistent = next
+	}
+	return consistent
+}
+
+// HierarchicalHistogramResult is the differentially private result of a
+// HierarchicalHistogram. Since it is obtained by post-processing, it can be
+// queried any number of times without consuming additional privacy budget.
+type HierarchicalHistogramResult struct {
+	lower, upper int64
+	prefixSums   []float64 // prefixSums[i] is the estimated count of the values in [lower, lower+i).
+}
+
+// RangeCount returns the estimated count of the values in [a, b], both
+// inclusive. The range is intersected with the domain of the histogram, and
+// RangeCount returns 0 if the intersection is empty.
+func (r *HierarchicalHistogramResult) RangeCount(a, b int64) float64 {
+	if a < r.lower {
+		a = r.lower
+	}
+	if b > r.upper {
+		b = r.upper
+	}
+	if a > b {
+		return 0
+	}
+	return r.prefixSums[b-r.lower+1] - r.prefixSums[a-r.lower]
+}
+
+// encodableHierarchicalHistogram can be encoded by the gob package.
+type encodableHierarchicalHistogram struct {
+	Epsilon         float64
+	Delta           float64
+	L0Sensitivity   int64
+	LInfSensitivity int64
+	Lower           int64
+	Upper           int64
+	BranchingFactor int64
+	NoiseKind       noise.Kind
+	Counts          []int64
+	ResultReturned  bool
+}
+
+// GobEncode encodes HierarchicalHistogram.
+func (h *HierarchicalHistogram) GobEncode() ([]byte, error) {
+	enc := encodableHierarchicalHistogram{
+		Epsilon:         h.epsilon,
+		Delta:           h.delta,
+		L0Sensitivity:   h.l0Sensitivity,
+		LInfSensitivity: h.lInfSensitivity,
+		Lower:           h.lower,
+		Upper:           h.upper,
+		BranchingFactor: h.branchingFactor,
+		NoiseKind:       noise.ToKind(h.noise),
+		Counts:          h.counts,
+		ResultReturned:  h.resultReturned,
+	}
+	h.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes HierarchicalHistogram.
+func (h *HierarchicalHistogram) GobDecode(data []byte) error {
+	var enc encodableHierarchicalHistogram
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode HierarchicalHistogram from bytes")
+		return err
+	}
+	*h = HierarchicalHistogram{
+		epsilon:         enc.Epsilon,
+		delta:           enc.Delta,
+		l0Sensitivity:   enc.L0Sensitivity,
+		lInfSensitivity: enc.LInfSensitivity,
+		lower:           enc.Lower,
+		upper:           enc.Upper,
+		branchingFactor: enc.BranchingFactor,
+		noiseKind:       enc.NoiseKind,
+		noise:           noise.ToNoise(enc.NoiseKind),
+		counts:          enc.Counts,
+		resultReturned:  enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewHierarchicalHistogram(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *HierarchicalHistogramOptions
+		want *HierarchicalHistogram
+	}{
+		{"default options",
+			&HierarchicalHistogramOptions{
+				Epsilon: ln3,
+				Lower:   -1,
+				Upper:   2,
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				lower:           -1,
+				upper:           2,
+				branchingFactor: 2,
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          []int64{0, 0, 0, 0},
+			}},
+		{"non-default options",
+			&HierarchicalHistogramOptions{
+				Epsilon:                      ln3,
+				Delta:                        tenten,
+				MaxPartitionsContributed:     2,
+				MaxContributionsPerPartition: 3,
+				Lower:                        0,
+				Upper:                        4,
+				BranchingFactor:              4,
+				Noise:                        noise.Gaussian(),
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				delta:           tenten,
+				l0Sensitivity:   2,
+				lInfSensitivity: 3,
+				lower:           0,
+				upper:           4,
+				branchingFactor: 4,
+				noise:           noise.Gaussian(),
+				noiseKind:       noise.GaussianNoise,
+				counts:          []int64{0, 0, 0, 0, 0},
+			}},
+		{"branching factor larger than the domain",
+			&HierarchicalHistogramOptions{
+				Epsilon:         ln3,
+				Lower:           0,
+				Upper:           9,
+				BranchingFactor: math.MaxInt64,
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				lower:           0,
+				upper:           9,
+				branchingFactor: 10,
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          make([]int64, 10),
+			}},
+		{"branching factor larger than a single-value domain",
+			&HierarchicalHistogramOptions{
+				Epsilon:         ln3,
+				Lower:           3,
+				Upper:           3,
+				BranchingFactor: 5,
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				lower:           3,
+				upper:           3,
+				branchingFactor: 5,
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          []int64{0},
+			}},
+		// 501² leaves would be needed with a branching factor of 501, while 32² is
+		// enough for a tree of the same height.
+		{"branching factor just over half the domain",
+			&HierarchicalHistogramOptions{
+				Epsilon:         ln3,
+				Lower:           0,
+				Upper:           999,
+				BranchingFactor: 501,
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				lower:           0,
+				upper:           999,
+				branchingFactor: 32,
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          make([]int64, 1000),
+			}},
+		{"branching factor not padding much",
+			&HierarchicalHistogramOptions{
+				Epsilon:         ln3,
+				Lower:           0,
+				Upper:           9,
+				BranchingFactor: 15,
+			},
+			&HierarchicalHistogram{
+				epsilon:         ln3,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				lower:           0,
+				upper:           9,
+				branchingFactor: 15,
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          make([]int64, 10),
+			}},
+	} {
+		got := NewHierarchicalHistogram(tc.opt)
+		if !cmp.Equal(got, tc.want, cmp.AllowUnexported(HierarchicalHistogram{})) {
+			t.Errorf("NewHierarchicalHistogram: when %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestTreeHeight(t *testing.T) {
+	for _, tc := range []struct {
+		numLeaves, k, want int64
+	}{
+		{1, 2, 1},
+		{2, 2, 2},
+		{3, 2, 3},
+		{4, 2, 3},
+		{5, 2, 4},
+		{16, 4, 3},
+		{17, 4, 4},
+		{math.MaxInt64, 3, 41},
+		{math.MaxInt64, math.MaxInt64 / 2, 3},
+	} {
+		if got := treeHeight(tc.numLeaves, tc.k); got != tc.want {
+			t.Errorf("treeHeight(%d, %d): got %d, want %d", tc.numLeaves, tc.k, got, tc.want)
+		}
+	}
+}
+
+func TestTreeLeaves(t *testing.T) {
+	for _, tc := range []struct {
+		height, k, want int64
+	}{
+		{1, 2, 1},
+		{3, 2, 4},
+		{3, 32, 1024},
+		{41, 3, math.MaxInt64},
+		{3, math.MaxInt64 / 2, math.MaxInt64},
+	} {
+		if got := treeLeaves(tc.height, tc.k); got != tc.want {
+			t.Errorf("treeLeaves(%d, %d): got %d, want %d", tc.height, tc.k, got, tc.want)
+		}
+	}
+}
+
+func TestHierarchicalHistogramRangeCountLargeBranchingFactor(t *testing.T) {
+	h := NewHierarchicalHistogram(&HierarchicalHistogramOptions{
+		Epsilon:         ln3,
+		Delta:           tenten,
+		Lower:           0,
+		Upper:           999,
+		BranchingFactor: 501,
+		Noise:           noNoise{},
+	})
+	for x := int64(0); x < 1000; x += 2 {
+		h.Add(x)
+	}
+	r := h.Result()
+	if got := r.RangeCount(0, 999); !ApproxEqual(got, 500) {
+		t.Errorf("RangeCount(0, 999): got %f, want 500", got)
+	}
+	if got := r.RangeCount(100, 199); !ApproxEqual(got, 50) {
+		t.Errorf("RangeCount(100, 199): got %f, want 50", got)
+	}
+}
+
+func TestHierarchicalHistogramRangeCount(t *testing.T) {
+	h := NewHierarchicalHistogram(&HierarchicalHistogramOptions{
+		Epsilon:         ln3,
+		Delta:           tenten,
+		Lower:           1,
+		Upper:           10,
+		BranchingFactor: 3,
+		Noise:           noNoise{},
+	})
+	for x := int64(-5); x <= 15; x++ {
+		h.Add(x) // values outside of [1, 10] are clamped
+	}
+	r := h.Result()
+	for _, tc := range []struct {
+		a, b int64
+		want float64
+	}{
+		{1, 1, 7},
+		{2, 9, 8},
+		{1, 10, 21},
+		{10, 10, 6},
+		{-100, 100, 21},
+		{5, 4, 0},
+		{11, 20, 0},
+	} {
+		if got := r.RangeCount(tc.a, tc.b); !ApproxEqual(got, tc.want) {
+			t.Errorf("RangeCount(%d, %d): got %f, want %f", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestConsistentLeaves(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		noisy [][]float64
+		k     int
+		want  []float64
+	}{
+		{"already consistent",
+			[][]float64{{1, 2, 3, 4}, {3, 7}, {10}},
+			2,
+			[]float64{1, 2, 3, 4}},
+		// Minimizes (a-1)² + (b-2)² + (a+b-5)².
+		{"single inner node",
+			[][]float64{{1, 2}, {5}},
+			2,
+			[]float64{5.0 / 3.0, 8.0 / 3.0}},
+		{"single leaf",
+			[][]float64{{4}},
+			2,
+			[]float64{4}},
+	} {
+		got := consistentLeaves(tc.noisy, tc.k)
+		if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 1e-10)) {
+			t.Errorf("consistentLeaves: when %s got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestHierarchicalHistogramMerge(t *testing.T) {
+	opt := &HierarchicalHistogramOptions{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 3, Noise: noNoise{}}
+	h1, h2 := NewHierarchicalHistogram(opt), NewHierarchicalHistogram(opt)
+	h1.Add(0)
+	h2.Add(0)
+	h2.Add(3)
+	h1.Merge(h2)
+	r := h1.Result()
+	if got := r.RangeCount(0, 0); !ApproxEqual(got, 2) {
+		t.Errorf("Merge: got count %f for value 0, want 2", got)
+	}
+	if got := r.RangeCount(0, 3); !ApproxEqual(got, 3) {
+		t.Errorf("Merge: got total count %f, want 3", got)
+	}
+	if !h2.resultReturned {
+		t.Errorf("Merge: h2 should be marked as consumed")
+	}
+}
+
+func TestHierarchicalHistogramCheckMerge(t *testing.T) {
+	opt := HierarchicalHistogramOptions{Epsilon: ln3, Lower: 0, Upper: 10}
+	for _, tc := range []struct {
+		desc    string
+		modify  func(*HierarchicalHistogramOptions)
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", func(*HierarchicalHistogramOptions) {}, false, false, false},
+		{"h1 returned its result", func(*HierarchicalHistogramOptions) {}, true, false, true},
+		{"h2 returned its result", func(*HierarchicalHistogramOptions) {}, false, true, true},
+		{"different domain", func(o *HierarchicalHistogramOptions) { o.Upper = 11 }, false, false, true},
+		{"different branching factor", func(o *HierarchicalHistogramOptions) { o.BranchingFactor = 3 }, false, false, true},
+		{"different epsilon", func(o *HierarchicalHistogramOptions) { o.Epsilon = 1 }, false, false, true},
+	} {
+		opt2 := opt
+		tc.modify(&opt2)
+		h1, h2 := NewHierarchicalHistogram(&opt), NewHierarchicalHistogram(&opt2)
+		h1.resultReturned = tc.state1
+		h2.resultReturned = tc.state2
+		if err := checkMergeHierarchicalHistogram(h1, h2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestHierarchicalHistogramSerialization(t *testing.T) {
+	opt := &HierarchicalHistogramOptions{
+		Epsilon:                  ln3,
+		Delta:                    1e-5,
+		MaxPartitionsContributed: 2,
+		Lower:                    -5,
+		Upper:                    5,
+		BranchingFactor:          3,
+		Noise:                    noise.Gaussian(),
+	}
+	h, hUnchanged := NewHierarchicalHistogram(opt), NewHierarchicalHistogram(opt)
+	h.Add(1)
+	hUnchanged.Add(1)
+	bytes, err := encode(h)
+	if err != nil {
+		t.Fatalf("encode(HierarchicalHistogram) error: %v", err)
+	}
+	hUnmarshalled := new(HierarchicalHistogram)
+	if err := decode(hUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(HierarchicalHistogram) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(hUnchanged, hUnmarshalled, cmp.AllowUnexported(HierarchicalHistogram{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", hUnmarshalled, hUnchanged)
+	}
+	// Check that the original HierarchicalHistogram has its resultReturned set to true after serialization.
+	if !h.resultReturned {
+		t.Errorf("HierarchicalHistogram %v should have its resultReturned set to true after being serialized", h)
+	}
+}
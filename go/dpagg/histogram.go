@@ -0,0 +1,413 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// Histogram calculates a differentially private histogram over a fixed set of
+// public buckets, given either as numerical bucket edges or as categorical
+// labels.
+//
+// Since the buckets do not depend on the data, every bucket is released (with
+// noise), even if it is empty, and no partition selection is needed.
+//
+// Contributions are added per user with AddUserValues or AddUserLabels, which
+// bound the contributions of that user: a user contributes to at most
+// MaxPartitionsContributed buckets, and at most MaxContributionsPerPartition
+// times to each bucket. Excess buckets and contributions are dropped uniformly
+// at random.
+//
+// Not thread-safe.
+type Histogram struct {
+	// Parameters
+	epsilon         float64
+	delta           float64
+	l0Sensitivity   int64
+	lInfSensitivity int64
+	edges           []float64
+	labels          []string
+	nonNegative     bool
+	noise           noise.Noise
+	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	counts         []int64
+	resultReturned bool // whether the result has already been returned
+}
+
+func histogramEquallyInitialized(h1, h2 *Histogram) bool {
+	return h1.epsilon == h2.epsilon &&
+		h1.delta == h2.delta &&
+		h1.l0Sensitivity == h2.l0Sensitivity &&
+		h1.lInfSensitivity == h2.lInfSensitivity &&
+		reflect.DeepEqual(h1.edges, h2.edges) &&
+		reflect.DeepEqual(h1.labels, h2.labels) &&
+		h1.nonNegative == h2.nonNegative &&
+		h1.noiseKind == h2.noiseKind
+}
+
+// HistogramOptions contains the options necessary to initialize a Histogram.
+// Exactly one of BucketEdges and Labels must be set.
+type HistogramOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct buckets may a single user contribute to? Defaults to 1.
+	// How many times may a single user contribute to a single bucket? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// BucketEdges are the strictly increasing edges e₀ < e₁ < … < eₙ of the n
+	// numerical buckets [e₀,e₁), [e₁,e₂), …, [eₙ₋₁,eₙ]. Values below e₀ (resp.
+	// above eₙ) are clamped to the first (resp. last) bucket.
+	BucketEdges []float64
+	// Labels are the distinct labels of the categorical buckets. Contributions
+	// with other labels are dropped.
+	Labels []string
+	// If NonNegative is set, Result post-processes the noisy counts into
+	// nonnegative counts that sum to the noisy total, i.e., the sum of the noisy
+	// counts (clamped to 0). This does not consume additional privacy budget.
+	NonNegative bool
+	Noise       noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewHistogram returns a new Histogram with all bucket counts initialized at 0.
+func NewHistogram(opt *HistogramOptions) *Histogram {
+	if opt == nil {
+		opt = &HistogramOptions{}
+	}
+	if (len(opt.BucketEdges) == 0) == (len(opt.Labels) == 0) {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewHistogram requires exactly one of BucketEdges and Labels to be set")
+	}
+	if err := checkBucketEdges(opt.BucketEdges); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewHistogram: %v", err)
+	}
+	if err := checkLabels(opt.Labels); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewHistogram: %v", err)
+	}
+	numBuckets := len(opt.Labels)
+	if len(opt.BucketEdges) > 0 {
+		numBuckets = len(opt.BucketEdges) - 1
+	}
+
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	// A user can't contribute to more buckets than there are.
+	if l0 > int64(numBuckets) {
+		l0 = int64(numBuckets)
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseInt64(0, l0, lInf, eps, del)
+
+	return &Histogram{
+		epsilon:         eps,
+		delta:           del,
+		l0Sensitivity:   l0,
+		lInfSensitivity: lInf,
+		edges:           append([]float64(nil), opt.BucketEdges...),
+		labels:          append([]string(nil), opt.Labels...),
+		nonNegative:     opt.NonNegative,
+		noise:           n,
+		noiseKind:       noise.ToKind(n),
+		counts:          make([]int64, numBuckets),
+		resultReturned:  false,
+	}
+}
+
+func checkBucketEdges(edges []float64) error {
+	if len(edges) == 1 {
+		return fmt.Errorf("BucketEdges must contain at least 2 edges, got %v", edges)
+	}
+	for i, e := range edges {
+		if math.IsNaN(e) || math.IsInf(e, 0) {
+			return fmt.Errorf("BucketEdges must be finite, got %v", edges)
+		}
+		if i > 0 && edges[i-1] >= e {
+			return fmt.Errorf("BucketEdges must be strictly increasing, got %v", edges)
+		}
+	}
+	return nil
+}
+
+func checkLabels(labels []string) error {
+	seen := make(map[string]bool)
+	for _, l := range labels {
+		if seen[l] {
+			return fmt.Errorf("Labels must be distinct, got %q twice", l)
+		}
+		seen[l] = true
+	}
+	return nil
+}
+
+// NumBuckets returns the number of buckets of the Histogram.
+func (h *Histogram) NumBuckets() int {
+	return len(h.counts)
+}
+
+// bucketForValue returns the index of the numerical bucket containing v, or -1
+// if v is NaN.
+func (h *Histogram) bucketForValue(v float64) int {
+	if math.IsNaN(v) {
+		return -1
+	}
+	// Index of the first edge strictly larger than v.
+	i := sort.SearchFloat64s(h.edges, math.Nextafter(v, math.Inf(1)))
+	if i == 0 {
+		return 0
+	}
+	if i > len(h.counts) {
+		return len(h.counts) - 1
+	}
+	return i - 1
+}
+
+// bucketForLabel returns the index of the categorical bucket with label l, or
+// -1 if there is no such bucket.
+func (h *Histogram) bucketForLabel(l string) int {
+	for i, label := range h.labels {
+		if label == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddUserValues adds all the values contributed by a single user to a
+// numerical Histogram, bounding the contributions of that user. It ignores NaN
+// values. All the values of a user must be added in a single call.
+func (h *Histogram) AddUserValues(values []float64) {
+	if h.edges == nil {
+		log.Fatalf("AddUserValues can only be used on a Histogram with BucketEdges")
+	}
+	buckets := make([]int, 0, len(values))
+	for _, v := range values {
+		if b := h.bucketForValue(v); b >= 0 {
+			buckets = append(buckets, b)
+		}
+	}
+	h.addUserBuckets(buckets)
+}
+
+// AddUserLabels adds all the labels contributed by a single user to a
+// categorical Histogram, bounding the contributions of that user. It ignores
+// labels that are not among the Labels of the Histogram. All the labels of a
+// user must be added in a single call.
+func (h *Histogram) AddUserLabels(labels []string) {
+	if h.labels == nil {
+		log.Fatalf("AddUserLabels can only be used on a Histogram with Labels")
+	}
+	buckets := make([]int, 0, len(labels))
+	for _, l := range labels {
+		if b := h.bucketForLabel(l); b >= 0 {
+			buckets = append(buckets, b)
+		}
+	}
+	h.addUserBuckets(buckets)
+}
+
+func (h *Histogram) addUserBuckets(buckets []int) {
+	if h.resultReturned {
+		log.Fatalf("The histogram has already been calculated and returned. It cannot be amended.")
+	}
+	kept, err := BoundContributions(len(buckets), func(i int) (interface{}, interface{}) {
+		return 0, buckets[i]
+	}, h.l0Sensitivity, h.lInfSensitivity)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("Couldn't bound contributions, err %v", err)
+	}
+	for _, i := range kept {
+		h.counts[buckets[i]]++
+	}
+}
+
+// Merge merges h2 into h (i.e., adds to h all entries that were added to h2).
+// h2 is consumed by this operation: it may not be used after it is merged
+// into h.
+func (h *Histogram) Merge(h2 *Histogram) {
+	if err := checkMergeHistogram(h, h2); err != nil {
+		log.Exit(err)
+	}
+	for i, c := range h2.counts {
+		h.counts[i] += c
+	}
+	h2.resultReturned = true
+}
+
+func checkMergeHistogram(h1, h2 *Histogram) error {
+	if h1.resultReturned {
+		return fmt.Errorf("checkMergeHistogram: h1 already returned the result, cannot be merged with another Histogram instance")
+	}
+	if h2.resultReturned {
+		return fmt.Errorf("checkMergeHistogram: h2 already returned the result, cannot be merged with another Histogram instance")
+	}
+	if !histogramEquallyInitialized(h1, h2) {
+		return fmt.Errorf("checkMergeHistogram: h1 and h2 are not compatible")
+	}
+	return nil
+}
+
+// Result returns a differentially private version of the bucket counts, in
+// the order of the BucketEdges or Labels. It can be called only once, after
+// which no further operation can be done on the Histogram.
+func (h *Histogram) Result() []int64 {
+	if h.resultReturned {
+		log.Fatalf("The histogram has already been calculated and returned. It can only be returned once.")
+	}
+	h.resultReturned = true
+	noisy := make([]int64, len(h.counts))
+	for i, c := range h.counts {
+		noisy[i] = h.noise.AddNoiseInt64(c, h.l0Sensitivity, h.lInfSensitivity, h.epsilon, h.delta)
+	}
+	if h.nonNegative {
+		return nonNegativeWithSameTotal(noisy)
+	}
+	return noisy
+}
+
+// nonNegativeWithSameTotal returns the nonnegative integer counts that are the
+// closest to noisy (in L2 distance) among those summing to the sum of noisy,
+// clamped to 0.
+//
+// It first computes the Euclidean projection of noisy onto the simplex
+// {x ≥ 0, Σx = total} (see Duchi et al., "Efficient Projections onto the
+// l1-Ball for Learning in High Dimensions"), and then rounds the projection to
+// integers so that the total is preserved.
+func nonNegativeWithSameTotal(noisy []int64) []int64 {
+	var total int64
+	for _, c := range noisy {
+		total += c
+	}
+	result := make([]int64, len(noisy))
+	if total <= 0 {
+		return result
+	}
+
+	sorted := make([]float64, len(noisy))
+	for i, c := range noisy {
+		sorted[i] = float64(c)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	// theta is the value subtracted from every count before clamping at 0.
+	var cumSum, theta float64
+	for j, u := range sorted {
+		cumSum += u
+		if t := (cumSum - float64(total)) / float64(j+1); u-t > 0 {
+			theta = t
+		}
+	}
+
+	projected := make([]float64, len(noisy))
+	var floorSum int64
+	for i, c := range noisy {
+		projected[i] = math.Max(float64(c)-theta, 0)
+		result[i] = int64(math.Floor(projected[i]))
+		floorSum += result[i]
+	}
+	// Since the counts are integers, all the positive projected counts have the
+	// same fractional part, so the remainder goes to the largest buckets.
+	order := make([]int, len(noisy))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return projected[order[a]] > projected[order[b]]
+	})
+	for k := 0; floorSum < total && k < len(order); k++ {
+		result[order[k]]++
+		floorSum++
+	}
+	return result
+}
+
+// encodableHistogram can be encoded by the gob package.
+type encodableHistogram struct {
+	Epsilon         float64
+	Delta           float64
+	L0Sensitivity   int64
+	LInfSensitivity int64
+	Edges           []float64
+	Labels          []string
+	NonNegative     bool
+	NoiseKind       noise.Kind
+	Counts          []int64
+	ResultReturned  bool
+}
+
+// GobEncode encodes Histogram.
+func (h *Histogram) GobEncode() ([]byte, error) {
+	enc := encodableHistogram{
+		Epsilon:         h.epsilon,
+		Delta:           h.delta,
+		L0Sensitivity:   h.l0Sensitivity,
+		LInfSensitivity: h.lInfSensitivity,
+		Edges:           h.edges,
+		Labels:          h.labels,
+		NonNegative:     h.nonNegative,
+		NoiseKind:       noise.ToKind(h.noise),
+		Counts:          h.counts,
+		ResultReturned:  h.resultReturned,
+	}
+	h.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes Histogram.
+func (h *Histogram) GobDecode(data []byte) error {
+	var enc encodableHistogram
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode Histogram from bytes")
+		return err
+	}
+	*h = Histogram{
+		epsilon:         enc.Epsilon,
+		delta:           enc.Delta,
+		l0Sensitivity:   enc.L0Sensitivity,
+		lInfSensitivity: enc.LInfSensitivity,
+		edges:           enc.Edges,
+		labels:          enc.Labels,
+		nonNegative:     enc.NonNegative,
+		noiseKind:       enc.NoiseKind,
+		noise:           noise.ToNoise(enc.NoiseKind),
+		counts:          enc.Counts,
+		resultReturned:  enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessHistogram(opt *HistogramOptions) *Histogram {
+	opt.Epsilon = ln3
+	opt.Delta = tenten
+	opt.Noise = noNoise{}
+	return NewHistogram(opt)
+}
+
+func TestNewHistogram(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *HistogramOptions
+		want *Histogram
+	}{
+		{"numerical buckets with default contribution bounds",
+			&HistogramOptions{
+				Epsilon:     ln3,
+				Delta:       0,
+				BucketEdges: []float64{0, 1, 2},
+			},
+			&Histogram{
+				epsilon:         ln3,
+				delta:           0,
+				l0Sensitivity:   1,
+				lInfSensitivity: 1,
+				edges:           []float64{0, 1, 2},
+				noise:           noise.Laplace(),
+				noiseKind:       noise.LaplaceNoise,
+				counts:          []int64{0, 0},
+			}},
+		{"MaxPartitionsContributed larger than the number of buckets",
+			&HistogramOptions{
+				Epsilon:                      ln3,
+				Delta:                        tenten,
+				MaxPartitionsContributed:     5,
+				MaxContributionsPerPartition: 2,
+				Labels:                       []string{"a", "b", "c"},
+				NonNegative:                  true,
+				Noise:                        noise.Gaussian(),
+			},
+			&Histogram{
+				epsilon:         ln3,
+				delta:           tenten,
+				l0Sensitivity:   3,
+				lInfSensitivity: 2,
+				labels:          []string{"a", "b", "c"},
+				nonNegative:     true,
+				noise:           noise.Gaussian(),
+				noiseKind:       noise.GaussianNoise,
+				counts:          []int64{0, 0, 0},
+			}},
+	} {
+		got := NewHistogram(tc.opt)
+		if !cmp.Equal(got, tc.want, cmp.AllowUnexported(Histogram{})) {
+			t.Errorf("NewHistogram: when %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestHistogramAddUserValues(t *testing.T) {
+	h := getNoiselessHistogram(&HistogramOptions{
+		MaxPartitionsContributed:     3,
+		MaxContributionsPerPartition: 2,
+		BucketEdges:                  []float64{0, 1, 2, 3},
+	})
+	h.AddUserValues([]float64{0, 0.5, 1})
+	h.AddUserValues([]float64{-10, 2.5, 3, 10}) // out-of-range values are clamped
+	h.AddUserValues([]float64{math.NaN()})      // NaN values are ignored
+	got := h.Result()
+	want := []int64{3, 1, 2}
+	if !cmp.Equal(got, want) {
+		t.Errorf("AddUserValues: got %v, want %v", got, want)
+	}
+}
+
+func TestHistogramAddUserLabelsBoundsContributions(t *testing.T) {
+	h := getNoiselessHistogram(&HistogramOptions{
+		MaxPartitionsContributed:     2,
+		MaxContributionsPerPartition: 1,
+		Labels:                       []string{"a", "b", "c"},
+	})
+	for i := 0; i < 10; i++ {
+		h.AddUserLabels([]string{"a", "a", "b", "c", "unknown"})
+	}
+	got := h.Result()
+	var total int64
+	for _, c := range got {
+		if c > 10 {
+			t.Errorf("AddUserLabels: got bucket count %d, want at most 10", c)
+		}
+		total += c
+	}
+	if total != 20 {
+		t.Errorf("AddUserLabels: got total count %d, want 20", total)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	opt := &HistogramOptions{Labels: []string{"a", "b"}}
+	h1 := getNoiselessHistogram(opt)
+	h2 := getNoiselessHistogram(opt)
+	h1.AddUserLabels([]string{"a"})
+	h2.AddUserLabels([]string{"a"})
+	h2.AddUserLabels([]string{"b"})
+	h1.Merge(h2)
+	got := h1.Result()
+	want := []int64{2, 1}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Merge: got %v, want %v", got, want)
+	}
+	if !h2.resultReturned {
+		t.Errorf("Merge: h2 should be marked as consumed")
+	}
+}
+
+func TestHistogramCheckMerge(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt1    *HistogramOptions
+		opt2    *HistogramOptions
+		wantErr bool
+	}{
+		{"same options",
+			&HistogramOptions{Epsilon: ln3, BucketEdges: []float64{0, 1, 2}},
+			&HistogramOptions{Epsilon: ln3, BucketEdges: []float64{0, 1, 2}},
+			false},
+		{"different bucket edges",
+			&HistogramOptions{Epsilon: ln3, BucketEdges: []float64{0, 1, 2}},
+			&HistogramOptions{Epsilon: ln3, BucketEdges: []float64{0, 1, 3}},
+			true},
+		{"different labels",
+			&HistogramOptions{Epsilon: ln3, Labels: []string{"a", "b"}},
+			&HistogramOptions{Epsilon: ln3, Labels: []string{"b", "a"}},
+			true},
+		{"different epsilon",
+			&HistogramOptions{Epsilon: ln3, Labels: []string{"a"}},
+			&HistogramOptions{Epsilon: 1, Labels: []string{"a"}},
+			true},
+		{"different NonNegative",
+			&HistogramOptions{Epsilon: ln3, Labels: []string{"a"}},
+			&HistogramOptions{Epsilon: ln3, Labels: []string{"a"}, NonNegative: true},
+			true},
+	} {
+		h1, h2 := NewHistogram(tc.opt1), NewHistogram(tc.opt2)
+		if err := checkMergeHistogram(h1, h2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestHistogramCheckMergeStateChecks(t *testing.T) {
+	opt := &HistogramOptions{Epsilon: ln3, Labels: []string{"a"}}
+	for _, tc := range []struct {
+		state1, state2 bool
+		wantErr        bool
+	}{
+		{false, false, false},
+		{true, false, true},
+		{false, true, true},
+	} {
+		h1, h2 := NewHistogram(opt), NewHistogram(opt)
+		h1.resultReturned = tc.state1
+		h2.resultReturned = tc.state2
+		if err := checkMergeHistogram(h1, h2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when states [%t, %t] for err got %v, wantErr %t", tc.state1, tc.state2, err, tc.wantErr)
+		}
+	}
+}
+
+func TestHistogramSerialization(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *HistogramOptions
+	}{
+		{"numerical buckets", &HistogramOptions{
+			Epsilon:     ln3,
+			BucketEdges: []float64{-1, 0, 1},
+		}},
+		{"categorical buckets", &HistogramOptions{
+			Epsilon:                  ln3,
+			Delta:                    1e-5,
+			MaxPartitionsContributed: 2,
+			Labels:                   []string{"a", "b", "c"},
+			NonNegative:              true,
+			Noise:                    noise.Gaussian(),
+		}},
+	} {
+		h, hUnchanged := NewHistogram(tc.opt), NewHistogram(tc.opt)
+		bytes, err := encode(h)
+		if err != nil {
+			t.Fatalf("encode(Histogram) error: %v", err)
+		}
+		hUnmarshalled := new(Histogram)
+		if err := decode(hUnmarshalled, bytes); err != nil {
+			t.Fatalf("decode(Histogram) error: %v", err)
+		}
+		// Check that encoding -> decoding is the identity function.
+		if !cmp.Equal(hUnchanged, hUnmarshalled, cmp.AllowUnexported(Histogram{})) {
+			t.Errorf("decode(encode(_)): when %s got %+v, want %+v", tc.desc, hUnmarshalled, hUnchanged)
+		}
+		// Check that the original Histogram has its resultReturned set to true after serialization.
+		if !h.resultReturned {
+			t.Errorf("Histogram %v should have its resultReturned set to true after being serialized", h)
+		}
+	}
+}
+
+func TestNonNegativeWithSameTotal(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		noisy []int64
+		want  []int64
+	}{
+		{"already nonnegative", []int64{3, 0, 5}, []int64{3, 0, 5}},
+		{"negative total", []int64{-3, 1, -1}, []int64{0, 0, 0}},
+		{"negative count absorbed by others", []int64{10, -2, 4}, []int64{9, 0, 3}},
+		{"remainder goes to the largest bucket", []int64{5, -1, 4, -2}, []int64{4, 0, 2, 0}},
+	} {
+		got := nonNegativeWithSameTotal(tc.noisy)
+		if !cmp.Equal(got, tc.want) {
+			t.Errorf("nonNegativeWithSameTotal: when %s got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -51,8 +51,9 @@ import (
 // Not thread-safe.
 type BoundedMeanFloat64 struct {
 	// Parameters
-	lower float64
-	upper float64
+	lower    float64
+	upper    float64
+	rounding Rounding
 
 	// State variables
 	normalizedSum BoundedSumFloat64
@@ -66,6 +67,7 @@ type BoundedMeanFloat64 struct {
 func bmEquallyInitializedFloat64(bm1, bm2 *BoundedMeanFloat64) bool {
 	return bm1.lower == bm2.lower &&
 		bm1.upper == bm2.upper &&
+		bm1.rounding == bm2.rounding &&
 		countEquallyInitialized(&bm1.count, &bm2.count) &&
 		bsEquallyInitializedFloat64(&bm1.normalizedSum, &bm2.normalizedSum)
 }
@@ -79,6 +81,72 @@ type BoundedMeanFloat64Options struct {
 	// Lower and Upper bounds for clamping. Default to 0; must be such that Lower < Upper.
 	Lower, Upper                 float64
 	Noise                        noise.Noise // Type of noise used in BoundedMean. Defaults to Laplace noise.
+	// Definition of neighbouring datasets used to compute the sensitivity of
+	// the normalized sum. The count keeps its AddOrRemoveOne sensitivity.
+	// Defaults to AddOrRemoveOne.
+	NeighbouringRelation NeighbouringRelation
+	// Rounding applied to the result, after clamping. Defaults to no rounding.
+	Rounding Rounding
+}
+
+// Validate returns an error if NewBoundedMeanFloat64 would fail with these
+// options. It does not build a BoundedMeanFloat64, and does not consume
+// randomness. Only the parameters of noise.Laplace() and noise.Gaussian() are
+// checked: with another Noise, NewBoundedMeanFloat64 may still fail.
+func (opt *BoundedMeanFloat64Options) Validate() error {
+	const label = "dpagg.BoundedMeanFloat64Options"
+	if opt == nil {
+		opt = &BoundedMeanFloat64Options{}
+	}
+	if opt.MaxContributionsPerPartition == 0 {
+		return &checks.ParameterError{Label: label, Parameter: "MaxContributionsPerPartition", Value: opt.MaxContributionsPerPartition, Constraint: checks.StrictlyPositive, AllowedRange: "(0, MaxInt64]"}
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	if opt.Lower == 0 && opt.Upper == 0 {
+		return errNoBounds(label)
+	}
+	if err := checks.CheckBoundsFloat64(label, opt.Lower, opt.Upper); err != nil {
+		return err
+	}
+	if err := checkRounding(label, opt.Rounding); err != nil {
+		return err
+	}
+	halfEpsilon, halfDelta := opt.Epsilon/2, opt.Delta/2
+	if err := noise.CheckParameters(label, n, 1, 1, halfEpsilon, halfDelta); err != nil {
+		return err
+	}
+	// The budget is split between a Count and a BoundedSumFloat64, as in
+	// NewBoundedMeanFloat64.
+	count := &CountOptions{
+		Epsilon:                      halfEpsilon,
+		Delta:                        halfDelta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		Noise:                        n,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+	}
+	// The options of the count and of the normalized sum are derived from opt,
+	// so their errors are wrapped with the label of opt.
+	if err := count.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid count: %w", label, err)
+	}
+	maxDistFromMidpoint := math.Abs(opt.Upper - (opt.Lower + (opt.Upper-opt.Lower)/2.0))
+	normalizedSum := &BoundedSumFloat64Options{
+		Epsilon:                      halfEpsilon,
+		Delta:                        halfDelta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		Lower:                        -maxDistFromMidpoint,
+		Upper:                        maxDistFromMidpoint,
+		Noise:                        n,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+		NeighbouringRelation:         opt.NeighbouringRelation,
+	}
+	if err := normalizedSum.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid normalized sum: %w", label, err)
+	}
+	return nil
 }
 
 // NewBoundedMeanFloat64 returns a new BoundedMeanFloat64.
@@ -113,6 +181,10 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", lower, upper, err)
 	}
+	if err := checkRounding("NewBoundedMeanFloat64", opt.Rounding); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("checkRounding(%+v) failed with %v", opt.Rounding, err)
+	}
 	// (lower + upper) / 2 may cause an overflow if lower and upper are large values.
 	midPoint := lower + (upper-lower)/2.0
 	maxDistFromMidpoint := math.Abs(upper - midPoint)
@@ -133,7 +205,7 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 		Delta:                        halfDelta,
 		MaxPartitionsContributed:     maxPartitionsContributed,
 		Noise:                        n,
-		maxContributionsPerPartition: maxContributionsPerPartition,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
 	})
 
 	// normalizedSum stores a noised sum of distances of the input entities from the middle of the
@@ -157,6 +229,9 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 	// delta = halfDelta. It will sum up (e - midpoint) for each entry e.
 	//
 	// 2. Count with epsilon = halfEpsilon, delta = halfDelta. It will count entities.
+	//
+	// With the ReplaceOne relation, the BoundedSum uses an LInfSensitivity of
+	// 2*maxDistFromMidpoint = upper-lower instead, and the Count is unchanged.
 	normalizedSum := NewBoundedSumFloat64(&BoundedSumFloat64Options{
 		Epsilon:                      halfEpsilon,
 		Delta:                        halfDelta,
@@ -164,12 +239,14 @@ func NewBoundedMeanFloat64(opt *BoundedMeanFloat64Options) *BoundedMeanFloat64 {
 		Lower:                        -maxDistFromMidpoint,
 		Upper:                        maxDistFromMidpoint,
 		Noise:                        n,
-		maxContributionsPerPartition: maxContributionsPerPartition,
+		MaxContributionsPerPartition: maxContributionsPerPartition,
+		NeighbouringRelation:         opt.NeighbouringRelation,
 	})
 
 	return &BoundedMeanFloat64{
 		lower:          lower,
 		upper:          upper,
+		rounding:       opt.Rounding,
 		midPoint:       midPoint,
 		count:          *count,
 		normalizedSum:  *normalizedSum,
@@ -199,6 +276,45 @@ func (bm *BoundedMeanFloat64) Add(e float64) {
 	}
 }
 
+// AddBatch adds entries to a BoundedMeanFloat64, skipping NaN entries like
+// Add. It is equivalent to calling Add on each of them, but only checks once
+// that the result hasn't been returned, and clamps the entries without an
+// error path: the bounds were checked when the BoundedMeanFloat64 was created.
+func (bm *BoundedMeanFloat64) AddBatch(es []float64) {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It cannot be amended.")
+	}
+	lower, upper, midPoint := bm.lower, bm.upper, bm.midPoint
+	// The midpoint is rounded, so e - midPoint can exceed the bounds of the
+	// normalized sum by a rounding error: it is clamped to them, like in Add.
+	sumLower, sumUpper := bm.normalizedSum.lower, bm.normalizedSum.upper
+	normalizedSum := bm.normalizedSum.sum
+	var count int64
+	for _, e := range es {
+		if math.IsNaN(e) {
+			continue
+		}
+		if e < lower {
+			e = lower
+		}
+		if e > upper {
+			e = upper
+		}
+		d := e - midPoint
+		if d < sumLower {
+			d = sumLower
+		}
+		if d > sumUpper {
+			d = sumUpper
+		}
+		normalizedSum += d
+		count++
+	}
+	bm.normalizedSum.sum = normalizedSum
+	bm.count.count += count
+}
+
 // Result returns a differentially private average of elements added so far.
 // It can be called only once, after which no further operation can be done on the BoundedMeanFloat64.
 func (bm *BoundedMeanFloat64) Result() float64 {
@@ -209,7 +325,38 @@ func (bm *BoundedMeanFloat64) Result() float64 {
 	bm.resultReturned = true
 	noisedCount := math.Max(1.0, float64(bm.count.Result()))
 	noisedSum := bm.normalizedSum.Result()
-	clamped, err := ClampFloat64(noisedSum/noisedCount+bm.midPoint, bm.lower, bm.upper)
+	return bm.roundedMean(bm.clampedMean(noisedSum, noisedCount))
+}
+
+// ThresholdedResult is similar to Result() but applies thresholding to the
+// noisy count of entries. So, if the noisy count is less than or equal to the
+// threshold specified by the noise mechanism, it returns nil. Otherwise, it
+// returns the result. Thresholding incurs an additional privacy loss of
+// deltaThreshold, which allows to only materialize partitions containing
+// data.
+func (bm *BoundedMeanFloat64) ThresholdedResult(deltaThreshold float64) *float64 {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It can only be returned once.")
+	}
+	bm.resultReturned = true
+	c := &bm.count
+	threshold := c.noise.Threshold(c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, deltaThreshold)
+	noisedCount := c.Result()
+	noisedSum := bm.normalizedSum.Result()
+	// As in BoundedSumInt64, the result is dropped if the noisy count is exactly
+	// equal to the threshold.
+	if float64(noisedCount) <= threshold {
+		return nil
+	}
+	result := bm.roundedMean(bm.clampedMean(noisedSum, math.Max(1.0, float64(noisedCount))))
+	return &result
+}
+
+// clampedMean returns normalizedSum/count shifted back by the midpoint and
+// clamped to the bounds of the BoundedMeanFloat64.
+func (bm *BoundedMeanFloat64) clampedMean(normalizedSum, count float64) float64 {
+	clamped, err := ClampFloat64(normalizedSum/count+bm.midPoint, bm.lower, bm.upper)
 	if err != nil {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("Couldn't clamp the result, err %v", err)
@@ -217,6 +364,73 @@ func (bm *BoundedMeanFloat64) Result() float64 {
 	return clamped
 }
 
+// roundedMean returns mean rounded as specified by the Rounding of the
+// BoundedMeanFloat64. Rounding can move a mean out of [Lower, Upper] when the
+// bounds aren't multiples of the granularity, so the rounded mean is clamped
+// again.
+func (bm *BoundedMeanFloat64) roundedMean(mean float64) float64 {
+	return math.Min(math.Max(bm.rounding.RoundFloat64(mean), bm.lower), bm.upper)
+}
+
+// ResultWithConfidenceInterval is similar to Result() but additionally returns
+// a confidence interval that contains the true (unnoised) clamped mean with a
+// probability of at least 1 - alpha.
+//
+// The interval is computed from a confidence interval for the noisy normalized
+// sum and one for the noisy count, each holding with probability sqrt(1 - alpha),
+// so that (the noise being independent) both hold simultaneously with
+// probability 1 - alpha. The bounds of the mean interval are then the extreme
+// values of the ratio sum/count over these two intervals, shifted back by the
+// midpoint and clamped to [Lower, Upper]. They are not rounded.
+//
+// An error is returned, and the BoundedMeanFloat64 is left untouched, if alpha
+// is not strictly between 0 and 1, or if the noise doesn't implement
+// noise.ConfidenceIntervalComputer.
+func (bm *BoundedMeanFloat64) ResultWithConfidenceInterval(alpha float64) (float64, noise.ConfidenceInterval, error) {
+	const label = "dpagg.BoundedMeanFloat64.ResultWithConfidenceInterval"
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	countCINoise, err := confidenceIntervalComputer(label, bm.count.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	sumCINoise, err := confidenceIntervalComputer(label, bm.normalizedSum.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It can only be returned once.")
+	}
+	bm.resultReturned = true
+	noisedCount := bm.count.Result()
+	noisedSum := bm.normalizedSum.Result()
+	result := bm.roundedMean(bm.clampedMean(noisedSum, math.Max(1.0, float64(noisedCount))))
+
+	alphaPart := 1 - math.Sqrt(1-alpha)
+	c := &bm.count
+	countCI, err := countCINoise.ComputeConfidenceIntervalInt64(noisedCount, c.l0Sensitivity, c.lInfSensitivity, c.epsilon, c.delta, alphaPart)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+	s := &bm.normalizedSum
+	sumCI, err := sumCINoise.ComputeConfidenceIntervalFloat64(noisedSum, s.l0Sensitivity, s.lInfSensitivity, s.epsilon, s.delta, alphaPart)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+
+	// As in Result(), the count is set to at least 1.
+	countLower := math.Max(1.0, countCI.LowerBound)
+	countUpper := math.Max(1.0, countCI.UpperBound)
+	lower, upper := ratioBounds(sumCI.LowerBound, sumCI.UpperBound, countLower, countUpper)
+	return result, noise.ConfidenceInterval{
+		LowerBound:      bm.clampedMean(lower, 1),
+		UpperBound:      bm.clampedMean(upper, 1),
+		ConfidenceLevel: 1 - alpha,
+	}, nil
+}
+
 // Merge merges bm2 into bm (i.e., adds to bm all entries that were added to
 // bm2). bm2 is consumed by this operation: bm2 may not be used after it is
 // merged into bm.
@@ -250,6 +464,7 @@ func (bm *BoundedMeanFloat64) GobEncode() ([]byte, error) {
 	enc := encodableBoundedMeanFloat64{
 		Lower:                  bm.lower,
 		Upper:                  bm.upper,
+		Rounding:               bm.rounding,
 		EncodableCount:         &bm.count,
 		EncodableNormalizedSum: &bm.normalizedSum,
 		MidPoint:               bm.midPoint,
@@ -270,6 +485,7 @@ func (bm *BoundedMeanFloat64) GobDecode(data []byte) error {
 	*bm = BoundedMeanFloat64{
 		lower:          enc.Lower,
 		upper:          enc.Upper,
+		rounding:       enc.Rounding,
 		count:          *enc.EncodableCount,
 		normalizedSum:  *enc.EncodableNormalizedSum,
 		midPoint:       enc.MidPoint,
@@ -282,8 +498,315 @@ func (bm *BoundedMeanFloat64) GobDecode(data []byte) error {
 type encodableBoundedMeanFloat64 struct {
 	Lower                  float64
 	Upper                  float64
+	Rounding               Rounding
 	EncodableCount         *Count
 	EncodableNormalizedSum *BoundedSumFloat64
 	MidPoint               float64
 	ResultReturned         bool
 }
+
+// BoundedMeanInt64 calculates a differentially private mean of a collection of
+// int64 values.
+//
+// It works like BoundedMeanFloat64, but clamps the entries with integer
+// arithmetic, and sums their distances to the midpoint exactly: the midpoint
+// may not be an integer, so the normalized sum stores twice these distances,
+// i.e., 2x - (Lower+Upper) for each entry x. Only the final division by the
+// noisy count is done in floating point.
+//
+// Note: Do not use when your results may cause overflows for int64 values.
+// This aggregation is not hardened for such applications yet.
+//
+// Not thread-safe.
+type BoundedMeanInt64 struct {
+	// Parameters
+	lower    int64
+	upper    int64
+	rounding Rounding
+
+	// State variables
+	// Noisy sum of twice the distances of the entries to the midpoint.
+	normalizedSum BoundedSumInt64
+	count         Count
+	// The midpoint between lower and upper bounds. It cannot be set by the user;
+	// it will be calculated based on the lower and upper values.
+	midPoint       float64
+	resultReturned bool // whether the result has already been returned
+}
+
+func bmEquallyInitializedInt64(bm1, bm2 *BoundedMeanInt64) bool {
+	return bm1.lower == bm2.lower &&
+		bm1.upper == bm2.upper &&
+		bm1.rounding == bm2.rounding &&
+		countEquallyInitialized(&bm1.count, &bm2.count) &&
+		bsEquallyInitializedint64(&bm1.normalizedSum, &bm2.normalizedSum)
+}
+
+// BoundedMeanInt64Options contains the options necessary to initialize a BoundedMeanInt64.
+type BoundedMeanInt64Options struct {
+	Epsilon                      float64 // Privacy parameter ε. Required.
+	Delta                        float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed     int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	MaxContributionsPerPartition int64   // How many times may a single user contribute to a single partition? Required.
+	// Lower and Upper bounds for clamping. Default to 0; must be such that
+	// Lower < Upper, and Upper - Lower must not overflow.
+	Lower, Upper int64
+	Noise        noise.Noise // Type of noise used in BoundedMean. Defaults to Laplace noise.
+	// Definition of neighbouring datasets used to compute the sensitivity of
+	// the normalized sum. The count keeps its AddOrRemoveOne sensitivity.
+	// Defaults to AddOrRemoveOne.
+	NeighbouringRelation NeighbouringRelation
+	// Rounding applied to the result, after clamping. Defaults to no rounding.
+	Rounding Rounding
+}
+
+// Validate returns an error if NewBoundedMeanInt64 would fail with these
+// options. It does not build a BoundedMeanInt64, and does not consume
+// randomness. Only the parameters of noise.Laplace() and noise.Gaussian() are
+// checked: with another Noise, NewBoundedMeanInt64 may still fail.
+func (opt *BoundedMeanInt64Options) Validate() error {
+	const label = "dpagg.BoundedMeanInt64Options"
+	if opt == nil {
+		opt = &BoundedMeanInt64Options{}
+	}
+	count, normalizedSum, err := boundedMeanInt64Parts(label, opt)
+	if err != nil {
+		return err
+	}
+	// The options of the count and of the normalized sum are derived from opt,
+	// so their errors are wrapped with the label of opt.
+	if err := count.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid count: %w", label, err)
+	}
+	if err := normalizedSum.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid normalized sum: %w", label, err)
+	}
+	return nil
+}
+
+// boundedMeanInt64Parts checks the options of a BoundedMeanInt64 that are not
+// checked by its Count and BoundedSumInt64, and returns the options of these.
+func boundedMeanInt64Parts(label string, opt *BoundedMeanInt64Options) (*CountOptions, *BoundedSumInt64Options, error) {
+	if opt.MaxContributionsPerPartition == 0 {
+		return nil, nil, &checks.ParameterError{Label: label, Parameter: "MaxContributionsPerPartition", Value: opt.MaxContributionsPerPartition, Constraint: checks.StrictlyPositive, AllowedRange: "(0, MaxInt64]"}
+	}
+	if opt.Lower == 0 && opt.Upper == 0 {
+		return nil, nil, errNoBounds(label)
+	}
+	if err := checks.CheckBoundsInt64(label, opt.Lower, opt.Upper); err != nil {
+		return nil, nil, err
+	}
+	width := opt.Upper - opt.Lower
+	if width < 0 {
+		return nil, nil, widthOverflowError(label, opt.Lower, opt.Upper)
+	}
+	if err := checkRounding(label, opt.Rounding); err != nil {
+		return nil, nil, err
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// We split the budget in half to calculate the count and the noised normalized sum.
+	halfEpsilon, halfDelta := opt.Epsilon/2, opt.Delta/2
+	count := &CountOptions{
+		Epsilon:                      halfEpsilon,
+		Delta:                        halfDelta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		Noise:                        n,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+	}
+	// Each entry x adds 2x - (Lower+Upper), which is in [-width, width].
+	normalizedSum := &BoundedSumInt64Options{
+		Epsilon:                      halfEpsilon,
+		Delta:                        halfDelta,
+		MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+		Lower:                        -width,
+		Upper:                        width,
+		Noise:                        n,
+		MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+		NeighbouringRelation:         opt.NeighbouringRelation,
+	}
+	return count, normalizedSum, nil
+}
+
+// NewBoundedMeanInt64 returns a new BoundedMeanInt64.
+func NewBoundedMeanInt64(opt *BoundedMeanInt64Options) *BoundedMeanInt64 {
+	if opt == nil {
+		opt = &BoundedMeanInt64Options{}
+	}
+	countOpt, normalizedSumOpt, err := boundedMeanInt64Parts("NewBoundedMeanInt64", opt)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedMeanInt64(%+v) failed with %v", opt, err)
+	}
+	return &BoundedMeanInt64{
+		lower:          opt.Lower,
+		upper:          opt.Upper,
+		rounding:       opt.Rounding,
+		midPoint:       float64(opt.Lower) + float64(opt.Upper-opt.Lower)/2,
+		count:          *NewCount(countOpt),
+		normalizedSum:  *NewBoundedSumInt64(normalizedSumOpt),
+		resultReturned: false,
+	}
+}
+
+// Add an entry to a BoundedMeanInt64.
+func (bm *BoundedMeanInt64) Add(e int64) {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It cannot be amended.")
+	}
+	clamped, err := ClampInt64(e, bm.lower, bm.upper)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("Couldn't clamp input value %v, err %v", e, err)
+	}
+	// (clamped - lower) + (clamped - upper) doesn't overflow, since both terms
+	// are at most Upper - Lower in absolute value and have opposite signs.
+	bm.normalizedSum.Add((clamped - bm.lower) + (clamped - bm.upper))
+	bm.count.Increment()
+}
+
+// AddBatch adds entries to a BoundedMeanInt64. It is equivalent to calling Add
+// on each of them, but only checks once that the result hasn't been returned,
+// and clamps the entries without an error path: the bounds were checked when
+// the BoundedMeanInt64 was created.
+func (bm *BoundedMeanInt64) AddBatch(es []int64) {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It cannot be amended.")
+	}
+	lower, upper := bm.lower, bm.upper
+	var doubleNormalizedSum int64
+	for _, e := range es {
+		if e < lower {
+			e = lower
+		}
+		if e > upper {
+			e = upper
+		}
+		doubleNormalizedSum += (e - lower) + (e - upper)
+	}
+	bm.normalizedSum.sum += doubleNormalizedSum
+	bm.count.count += int64(len(es))
+}
+
+// Result returns a differentially private average of elements added so far.
+// It can be called only once, after which no further operation can be done on the BoundedMeanInt64.
+func (bm *BoundedMeanInt64) Result() float64 {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It can only be returned once.")
+	}
+	bm.resultReturned = true
+	noisedCount := math.Max(1.0, float64(bm.count.Result()))
+	noisedSum := bm.normalizedSum.Result()
+	return bm.roundedMean(bm.clampedMean(noisedSum, noisedCount))
+}
+
+// ThresholdedResult is similar to Result() but applies thresholding to the
+// noisy count of entries, like BoundedMeanFloat64.ThresholdedResult.
+func (bm *BoundedMeanInt64) ThresholdedResult(deltaThreshold float64) *float64 {
+	if bm.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The mean has already been calculated and returned. It can only be returned once.")
+	}
+	bm.resultReturned = true
+	c := &bm.count
+	threshold := c.noise.Threshold(c.l0Sensitivity, float64(c.lInfSensitivity), c.epsilon, c.delta, deltaThreshold)
+	noisedCount := c.Result()
+	noisedSum := bm.normalizedSum.Result()
+	if float64(noisedCount) <= threshold {
+		return nil
+	}
+	result := bm.roundedMean(bm.clampedMean(noisedSum, math.Max(1.0, float64(noisedCount))))
+	return &result
+}
+
+// clampedMean returns the mean given twice the normalized sum and the count,
+// shifted back by the midpoint and clamped to the bounds of the
+// BoundedMeanInt64.
+func (bm *BoundedMeanInt64) clampedMean(doubleNormalizedSum int64, count float64) float64 {
+	mean := float64(doubleNormalizedSum)/(2*count) + bm.midPoint
+	return math.Min(math.Max(mean, float64(bm.lower)), float64(bm.upper))
+}
+
+// roundedMean returns mean rounded as specified by the Rounding of the
+// BoundedMeanInt64, and clamped again to [Lower, Upper], like
+// BoundedMeanFloat64.roundedMean.
+func (bm *BoundedMeanInt64) roundedMean(mean float64) float64 {
+	return math.Min(math.Max(bm.rounding.RoundFloat64(mean), float64(bm.lower)), float64(bm.upper))
+}
+
+// Merge merges bm2 into bm (i.e., adds to bm all entries that were added to
+// bm2). bm2 is consumed by this operation: bm2 may not be used after it is
+// merged into bm.
+func (bm *BoundedMeanInt64) Merge(bm2 *BoundedMeanInt64) {
+	if err := checkMergeBoundedMeanInt64(bm, bm2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	bm.normalizedSum.sum += bm2.normalizedSum.sum
+	bm.count.count += bm2.count.count
+	bm2.resultReturned = true
+}
+
+func checkMergeBoundedMeanInt64(bm1, bm2 *BoundedMeanInt64) error {
+	if bm1.resultReturned {
+		return fmt.Errorf("checkMergeBoundedMeanInt64: bm1 already returned the result, cannot be merged with another BoundedMean instance")
+	}
+	if bm2.resultReturned {
+		return fmt.Errorf("checkMergeBoundedMeanInt64: bm2 already returned the result, cannot be merged with another BoundedMean instance")
+	}
+
+	if !bmEquallyInitializedInt64(bm1, bm2) {
+		return fmt.Errorf("checkMergeBoundedMeanInt64: bm1 and bm2 are not compatible")
+	}
+
+	return nil
+}
+
+// GobEncode encodes BoundedMeanInt64.
+func (bm *BoundedMeanInt64) GobEncode() ([]byte, error) {
+	enc := encodableBoundedMeanInt64{
+		Lower:                  bm.lower,
+		Upper:                  bm.upper,
+		Rounding:               bm.rounding,
+		EncodableCount:         &bm.count,
+		EncodableNormalizedSum: &bm.normalizedSum,
+		MidPoint:               bm.midPoint,
+		ResultReturned:         bm.resultReturned,
+	}
+	bm.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedMeanInt64.
+func (bm *BoundedMeanInt64) GobDecode(data []byte) error {
+	var enc encodableBoundedMeanInt64
+	if err := decode(&enc, data); err != nil {
+		return err
+	}
+	*bm = BoundedMeanInt64{
+		lower:          enc.Lower,
+		upper:          enc.Upper,
+		rounding:       enc.Rounding,
+		count:          *enc.EncodableCount,
+		normalizedSum:  *enc.EncodableNormalizedSum,
+		midPoint:       enc.MidPoint,
+		resultReturned: enc.ResultReturned,
+	}
+	return nil
+}
+
+// encodableBoundedMeanInt64 can be encoded by the gob package.
+type encodableBoundedMeanInt64 struct {
+	Lower                  int64
+	Upper                  int64
+	Rounding               Rounding
+	EncodableCount         *Count
+	EncodableNormalizedSum *BoundedSumInt64
+	MidPoint               float64
+	ResultReturned         bool
+}
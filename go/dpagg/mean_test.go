@@ -17,10 +17,12 @@
 package dpagg
 
 import (
+	"errors"
 	"math"
 	"reflect"
 	"testing"
 
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/go/rand"
 	"github.com/google/go-cmp/cmp"
@@ -135,6 +137,73 @@ func TestBMAddFloat64(t *testing.T) {
 	}
 }
 
+func TestBMResultWithConfidenceIntervalFloat64(t *testing.T) {
+	bmf := getNoiselessBMF()
+	bmf.Add(1.5)
+	bmf.Add(2.5)
+	bmf.Add(3.5)
+	bmf.Add(4.5)
+	got, ci, err := bmf.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	if !ApproxEqual(got, 3.0) {
+		t.Errorf("ResultWithConfidenceInterval: got result %f, want 3", got)
+	}
+	// The normalized sum is 4 with interval [3, 5] and the count is 4 with
+	// interval [3, 5], so the mean lies in [2 + 3/5, 2 + 5/3].
+	if !ApproxEqual(ci.LowerBound, 2.6) || !ApproxEqual(ci.UpperBound, 2+5.0/3.0) || !ApproxEqual(ci.ConfidenceLevel, 0.95) {
+		t.Errorf("ResultWithConfidenceInterval: got %+v, want [2.6, %f]", ci, 2+5.0/3.0)
+	}
+}
+
+func TestBMResultWithConfidenceIntervalIsWithinBoundsFloat64(t *testing.T) {
+	bmf := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        5,
+	})
+	bmf.Add(1)
+	got, ci, err := bmf.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	if ci.LowerBound < -1 || ci.UpperBound > 5 || got < ci.LowerBound || got > ci.UpperBound {
+		t.Errorf("ResultWithConfidenceInterval: got result %f with interval %+v, want result within the interval and the interval within [-1, 5]", got, ci)
+	}
+}
+
+func TestBMResultWithConfidenceIntervalInvalidAlphaFloat64(t *testing.T) {
+	bmf := getNoiselessBMF()
+	bmf.Add(3)
+	if _, _, err := bmf.ResultWithConfidenceInterval(-0.5); err == nil {
+		t.Errorf("ResultWithConfidenceInterval(-0.5): got no error")
+	}
+	if got := bmf.Result(); !ApproxEqual(got, 3.0) {
+		t.Errorf("Result: after an invalid ResultWithConfidenceInterval call got %f, want 3", got)
+	}
+}
+
+func TestBMResultWithConfidenceIntervalUnsupportedNoiseFloat64(t *testing.T) {
+	bmf := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noiseWithoutConfidenceIntervals{noNoise{}},
+	})
+	bmf.Add(3)
+	if _, _, err := bmf.ResultWithConfidenceInterval(0.05); err == nil {
+		t.Errorf("ResultWithConfidenceInterval: with a noise that doesn't compute confidence intervals got no error")
+	}
+	if got := bmf.Result(); !ApproxEqual(got, 3.0) {
+		t.Errorf("Result: after an unsupported ResultWithConfidenceInterval call got %f, want 3", got)
+	}
+}
+
 func TestBMAddFloat64IgnoresNaN(t *testing.T) {
 	bmf := getNoiselessBMF()
 	bmf.Add(1)
@@ -691,6 +760,7 @@ func compareBoundedMeanFloat64(bm1, bm2 *BoundedMeanFloat64) bool {
 	return bm1.lower == bm2.lower &&
 		bm1.upper == bm2.upper &&
 		compareCount(&bm1.count, &bm2.count) &&
+		bm1.rounding == bm2.rounding &&
 		compareBoundedSumFloat64(&bm1.normalizedSum, &bm2.normalizedSum) &&
 		bm1.midPoint == bm2.midPoint &&
 		bm1.resultReturned == bm2.resultReturned
@@ -717,6 +787,7 @@ func TestBMFloat64Serialization(t *testing.T) {
 			MaxPartitionsContributed:     5,
 			MaxContributionsPerPartition: 6,
 			Noise:                        noise.Gaussian(),
+			Rounding:                     Rounding{Granularity: 0.1},
 		}},
 	} {
 		bm, bmUnchanged := NewBoundedMeanFloat64(tc.opts), NewBoundedMeanFloat64(tc.opts)
@@ -738,3 +809,503 @@ func TestBMFloat64Serialization(t *testing.T) {
 		}
 	}
 }
+
+func TestBoundedMeanFloat64OptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *BoundedMeanFloat64Options
+		wantErr bool
+	}{
+		{"valid options", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}, false},
+		{"valid Gaussian", &BoundedMeanFloat64Options{Epsilon: ln3, Delta: 1e-5, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5, Noise: noise.Gaussian()}, false},
+		{"nil options", nil, true},
+		{"no MaxContributionsPerPartition", &BoundedMeanFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5}, true},
+		{"no bounds", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1}, true},
+		{"equal bounds", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 2, Upper: 2}, true},
+		{"lower larger than upper", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 5, Upper: -1}, true},
+		{"zero epsilon", &BoundedMeanFloat64Options{MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}, true},
+		{"negative MaxPartitionsContributed", &BoundedMeanFloat64Options{Epsilon: ln3, MaxPartitionsContributed: -1, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}, true},
+		{"invalid Rounding", &BoundedMeanFloat64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5, Rounding: Rounding{Granularity: math.NaN()}}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBMThresholdedResultFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []float64
+		wantNil bool
+		want    float64
+	}{
+		// noNoise has a threshold of 5.
+		{"count below threshold", []float64{1, 2, 3}, true, 0},
+		{"count equal to threshold", []float64{1, 2, 3, 4, 5}, true, 0},
+		{"count above threshold", []float64{-1, 1, 2, 3, 4, 5}, false, 14.0 / 6},
+	} {
+		bm := getNoiselessBMF()
+		for _, e := range tc.entries {
+			bm.Add(e)
+		}
+		got := bm.ThresholdedResult(5e-10)
+		if (got == nil) != tc.wantNil {
+			t.Fatalf("ThresholdedResult: when %s got %v, wantNil %t", tc.desc, got, tc.wantNil)
+		}
+		if got != nil && !ApproxEqual(*got, tc.want) {
+			t.Errorf("ThresholdedResult: when %s got %f, want %f", tc.desc, *got, tc.want)
+		}
+	}
+}
+
+func getNoiselessBMI() *BoundedMeanInt64 {
+	return NewBoundedMeanInt64(&BoundedMeanInt64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        4,
+		Noise:                        noNoise{},
+	})
+}
+
+func TestBMNoInputInt64(t *testing.T) {
+	bm := getNoiselessBMI()
+	got := bm.Result()
+	// count = 0 => returns midPoint = 1.5
+	want := 1.5
+	if !ApproxEqual(got, want) {
+		t.Errorf("BoundedMeanInt64: when there is no input data got=%f, want=%f", got, want)
+	}
+}
+
+func TestBMAddInt64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []int64
+		want    float64
+	}{
+		{"entries within bounds", []int64{1, 2, 3, 4}, 2.5},
+		{"single entry", []int64{-1}, -1},
+		{"clamped entries", []int64{-100, 3, 100}, 2},
+	} {
+		bm := getNoiselessBMI()
+		for _, e := range tc.entries {
+			bm.Add(e)
+		}
+		got := bm.Result()
+		if !ApproxEqual(got, tc.want) {
+			t.Errorf("Add: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestBMExtremeBoundsInt64(t *testing.T) {
+	bm := NewBoundedMeanInt64(&BoundedMeanInt64Options{
+		Epsilon:                      ln3,
+		MaxPartitionsContributed:     1,
+		MaxContributionsPerPartition: 1,
+		Lower:                        math.MinInt64 / 2,
+		Upper:                        math.MaxInt64 / 2,
+		Noise:                        noNoise{},
+	})
+	bm.Add(math.MaxInt64)
+	got := bm.Result()
+	want := float64(math.MaxInt64 / 2)
+	if !ApproxEqual(got, want) {
+		t.Errorf("BoundedMeanInt64: with extreme bounds got %f, want %f", got, want)
+	}
+}
+
+func TestBMThresholdedResultInt64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []int64
+		wantNil bool
+		want    float64
+	}{
+		// noNoise has a threshold of 5.
+		{"count below threshold", []int64{1, 2, 3}, true, 0},
+		{"count equal to threshold", []int64{1, 2, 3, 4, 4}, true, 0},
+		{"count above threshold", []int64{1, 1, 2, 3, 4, 4}, false, 2.5},
+	} {
+		bm := getNoiselessBMI()
+		for _, e := range tc.entries {
+			bm.Add(e)
+		}
+		got := bm.ThresholdedResult(5e-10)
+		if (got == nil) != tc.wantNil {
+			t.Fatalf("ThresholdedResult: when %s got %v, wantNil %t", tc.desc, got, tc.wantNil)
+		}
+		if got != nil && !ApproxEqual(*got, tc.want) {
+			t.Errorf("ThresholdedResult: when %s got %f, want %f", tc.desc, *got, tc.want)
+		}
+	}
+}
+
+func TestMergeBoundedMeanInt64(t *testing.T) {
+	bm1 := getNoiselessBMI()
+	bm2 := getNoiselessBMI()
+	bm1.Add(1)
+	bm1.Add(2)
+	bm2.Add(3)
+	bm2.Add(4)
+	bm1.Merge(bm2)
+	got := bm1.Result()
+	want := 2.5
+	if !ApproxEqual(got, want) {
+		t.Errorf("Merge: when merging 2 instances of BoundedMeanInt64 got %f, want %f", got, want)
+	}
+	if !bm2.resultReturned {
+		t.Errorf("Merge: when merging 2 instances of BoundedMeanInt64 for bm2.resultReturned got false, want true")
+	}
+}
+
+func TestCheckMergeBoundedMeanInt64Compatibility(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt1    *BoundedMeanInt64Options
+		opt2    *BoundedMeanInt64Options
+		wantErr bool
+	}{
+		{"same options",
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			false},
+		{"different epsilon",
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			&BoundedMeanInt64Options{Epsilon: 2, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			true},
+		{"different lower bound",
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 4},
+			true},
+		{"different upper bound",
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5},
+			true},
+		{"different noise",
+			&BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4},
+			&BoundedMeanInt64Options{Epsilon: ln3, Delta: 1e-5, MaxContributionsPerPartition: 1, Lower: -1, Upper: 4, Noise: noise.Gaussian()},
+			true},
+	} {
+		bm1 := NewBoundedMeanInt64(tc.opt1)
+		bm2 := NewBoundedMeanInt64(tc.opt2)
+		if err := checkMergeBoundedMeanInt64(bm1, bm2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCheckMergeBoundedMeanInt64StateChecks(t *testing.T) {
+	for _, tc := range []struct {
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{false, false, false},
+		{true, false, true},
+		{false, true, true},
+		{true, true, true},
+	} {
+		bm1 := getNoiselessBMI()
+		bm2 := getNoiselessBMI()
+		bm1.resultReturned = tc.state1
+		bm2.resultReturned = tc.state2
+		if err := checkMergeBoundedMeanInt64(bm1, bm2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when states [%t, %t] for err got %v, wantErr %t", tc.state1, tc.state2, err, tc.wantErr)
+		}
+	}
+}
+
+func compareBoundedMeanInt64(bm1, bm2 *BoundedMeanInt64) bool {
+	return bm1.lower == bm2.lower &&
+		bm1.upper == bm2.upper &&
+		compareCount(&bm1.count, &bm2.count) &&
+		bm1.rounding == bm2.rounding &&
+		compareBoundedSumInt64(&bm1.normalizedSum, &bm2.normalizedSum) &&
+		bm1.midPoint == bm2.midPoint &&
+		bm1.resultReturned == bm2.resultReturned
+}
+
+// Tests that serialization for BoundedMeanInt64 works as expected.
+func TestBMInt64Serialization(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts *BoundedMeanInt64Options
+	}{
+		{"default options", &BoundedMeanInt64Options{
+			Epsilon:                      ln3,
+			Lower:                        0,
+			Upper:                        1,
+			MaxContributionsPerPartition: 1,
+		}},
+		{"non-default options", &BoundedMeanInt64Options{
+			Lower:                        -100,
+			Upper:                        555,
+			Epsilon:                      ln3,
+			Delta:                        1e-5,
+			MaxPartitionsContributed:     5,
+			MaxContributionsPerPartition: 6,
+			Noise:                        noise.Gaussian(),
+			Rounding:                     Rounding{Granularity: 0.1},
+		}},
+	} {
+		bm, bmUnchanged := NewBoundedMeanInt64(tc.opts), NewBoundedMeanInt64(tc.opts)
+		bytes, err := encode(bm)
+		if err != nil {
+			t.Fatalf("encode(BoundedMeanInt64) error: %v", err)
+		}
+		bmUnmarshalled := new(BoundedMeanInt64)
+		if err := decode(bmUnmarshalled, bytes); err != nil {
+			t.Fatalf("decode(BoundedMeanInt64) error: %v", err)
+		}
+		// Check that encoding -> decoding is the identity function.
+		if !cmp.Equal(bmUnchanged, bmUnmarshalled, cmp.Comparer(compareBoundedMeanInt64)) {
+			t.Errorf("decode(encode(_)): when %s got %v, want %v", tc.desc, bmUnmarshalled, bm)
+		}
+		// Check that the original BoundedMean has its resultReturned set to true after serialization.
+		if !bm.resultReturned {
+			t.Errorf("BoundedMean %v should have its resultReturned set to true after being serialized", bm)
+		}
+	}
+}
+
+func TestBoundedMeanInt64OptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *BoundedMeanInt64Options
+		wantErr bool
+	}{
+		{"valid options", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}, false},
+		{"valid Gaussian", &BoundedMeanInt64Options{Epsilon: ln3, Delta: 1e-5, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5, Noise: noise.Gaussian()}, false},
+		{"nil options", nil, true},
+		{"no MaxContributionsPerPartition", &BoundedMeanInt64Options{Epsilon: ln3, Lower: -1, Upper: 5}, true},
+		{"no bounds", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1}, true},
+		{"equal bounds", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 2, Upper: 2}, true},
+		{"lower larger than upper", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 5, Upper: -1}, true},
+		{"width overflows", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: math.MinInt64 + 1, Upper: math.MaxInt64}, true},
+		{"zero epsilon", &BoundedMeanInt64Options{MaxContributionsPerPartition: 1, Lower: -1, Upper: 5}, true},
+		{"invalid Rounding", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: -1, Upper: 5, Rounding: Rounding{SignificantDigits: -1}}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBoundedMeanOptionsValidateReturnsParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		opt           interface{ Validate() error }
+		wantParameter string
+	}{
+		{"BoundedMeanFloat64 without MaxContributionsPerPartition", &BoundedMeanFloat64Options{Epsilon: ln3, Lower: 0, Upper: 5}, "MaxContributionsPerPartition"},
+		// The error comes from the options of the count of the mean.
+		{"BoundedMeanInt64 with Gaussian noise and no Delta", &BoundedMeanInt64Options{Epsilon: ln3, MaxContributionsPerPartition: 1, Lower: 0, Upper: 5, Noise: noise.Gaussian()}, "Delta"},
+	} {
+		err := tc.opt.Validate()
+		var perr *checks.ParameterError
+		if !errors.As(err, &perr) {
+			t.Errorf("Validate: for %s got err %v, want a *checks.ParameterError", tc.desc, err)
+			continue
+		}
+		if perr.Parameter != tc.wantParameter {
+			t.Errorf("Validate: for %s got an error on %s, want %s", tc.desc, perr.Parameter, tc.wantParameter)
+		}
+	}
+}
+
+func TestBMReplaceOneSensitivity(t *testing.T) {
+	bmf := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 2,
+		Lower:                        -1,
+		Upper:                        5,
+		NeighbouringRelation:         ReplaceOne,
+	})
+	// The normalized sum is bounded by ±3, so its sensitivity is 2·6 instead of 2·3.
+	if got, want := bmf.normalizedSum.lInfSensitivity, 12.0; got != want {
+		t.Errorf("BoundedMeanFloat64 with ReplaceOne: got normalized sum lInfSensitivity %f, want %f", got, want)
+	}
+	if got, want := bmf.count.lInfSensitivity, int64(2); got != want {
+		t.Errorf("BoundedMeanFloat64 with ReplaceOne: got count lInfSensitivity %d, want %d", got, want)
+	}
+
+	bmi := NewBoundedMeanInt64(&BoundedMeanInt64Options{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 2,
+		Lower:                        -1,
+		Upper:                        4,
+		NeighbouringRelation:         ReplaceOne,
+	})
+	// The normalized sum stores twice the distances to the midpoint, so it is
+	// bounded by ±5, and its sensitivity is 2·10 instead of 2·5.
+	if got, want := bmi.normalizedSum.lInfSensitivity, int64(20); got != want {
+		t.Errorf("BoundedMeanInt64 with ReplaceOne: got normalized sum lInfSensitivity %d, want %d", got, want)
+	}
+}
+
+func TestBMRounding(t *testing.T) {
+	bmf := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxContributionsPerPartition: 1,
+		Lower:                        -1,
+		Upper:                        5,
+		Noise:                        noNoise{},
+		Rounding:                     Rounding{Granularity: 0.5},
+	})
+	bmf.Add(1)
+	bmf.Add(1.3)
+	if got, want := bmf.Result(), 1.0; !ApproxEqual(got, want) {
+		t.Errorf("BoundedMeanFloat64.Result with Granularity 0.5: got %f, want %f", got, want)
+	}
+
+	bmi := NewBoundedMeanInt64(&BoundedMeanInt64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxContributionsPerPartition: 1,
+		Lower:                        0,
+		Upper:                        10000,
+		Noise:                        noNoise{},
+		Rounding:                     Rounding{SignificantDigits: 1},
+	})
+	for _, e := range []int64{1000, 2000, 3000, 4000, 4600, 5000} {
+		bmi.Add(e)
+	}
+	// The count (6) is above the threshold (5), and the mean 3266.67 is rounded to 3000.
+	got := bmi.ThresholdedResult(tenten)
+	if got == nil || !ApproxEqual(*got, 3000) {
+		t.Errorf("BoundedMeanInt64.ThresholdedResult with SignificantDigits 1: got %v, want 3000", got)
+	}
+}
+
+func TestBMRoundingKeepsBounds(t *testing.T) {
+	// The bounds aren't multiples of the granularity: the rounded mean is clamped
+	// back to them.
+	for _, tc := range []struct {
+		desc         string
+		lower, upper float64
+		granularity  float64
+		entries      []float64
+		want         float64
+	}{
+		{"rounded above the upper bound", 0.5, 0.9, 1, []float64{0.6, 0.7}, 0.9},
+		{"rounded below the lower bound", 0.1, 0.4, 1, []float64{0.2, 0.3}, 0.1},
+		{"rounded within bounds", 0.5, 2.9, 1, []float64{1.2, 1.4}, 1},
+	} {
+		bmf := NewBoundedMeanFloat64(&BoundedMeanFloat64Options{
+			Epsilon:                      ln3,
+			Delta:                        tenten,
+			MaxContributionsPerPartition: 1,
+			Lower:                        tc.lower,
+			Upper:                        tc.upper,
+			Noise:                        noNoise{},
+			Rounding:                     Rounding{Granularity: tc.granularity},
+		})
+		for _, e := range tc.entries {
+			bmf.Add(e)
+		}
+		if got := bmf.Result(); !ApproxEqual(got, tc.want) {
+			t.Errorf("BoundedMeanFloat64.Result: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc         string
+		lower, upper int64
+		granularity  float64
+		entries      []int64
+		want         float64
+	}{
+		{"rounded above the upper bound", 1, 4, 5, []int64{3, 4}, 4},
+		{"rounded below the lower bound", 1, 4, 5, []int64{1, 2}, 1},
+		{"rounded within bounds", 1, 12, 5, []int64{6, 8}, 5},
+	} {
+		bmi := NewBoundedMeanInt64(&BoundedMeanInt64Options{
+			Epsilon:                      ln3,
+			Delta:                        tenten,
+			MaxContributionsPerPartition: 1,
+			Lower:                        tc.lower,
+			Upper:                        tc.upper,
+			Noise:                        noNoise{},
+			Rounding:                     Rounding{Granularity: tc.granularity},
+		})
+		for _, e := range tc.entries {
+			bmi.Add(e)
+		}
+		if got := bmi.Result(); !ApproxEqual(got, tc.want) {
+			t.Errorf("BoundedMeanInt64.Result: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestBMAddBatchFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []float64
+	}{
+		{"empty batch", nil},
+		{"entries within bounds", []float64{1.1, 2.2, 3.3, 4.4}},
+		{"clamped entries", []float64{-100, 3, math.Inf(1), math.Inf(-1)}},
+		{"NaN entries", []float64{1, math.NaN(), 2}},
+	} {
+		batch, single := getNoiselessBMF(), getNoiselessBMF()
+		batch.Add(0.5)
+		single.Add(0.5)
+		batch.AddBatch(tc.entries)
+		for _, e := range tc.entries {
+			single.Add(e)
+		}
+		if got, want := batch.Result(), single.Result(); got != want {
+			t.Errorf("AddBatch: when %s got %f, want %f", tc.desc, got, want)
+		}
+	}
+}
+
+func TestBMAddBatchFloat64AtBounds(t *testing.T) {
+	// The midpoint of [0.1, 0.8] is rounded, so that 0.1 - midPoint is below
+	// the lower bound of the normalized sum and must be clamped to it.
+	opt := &BoundedMeanFloat64Options{
+		Epsilon:                      ln3,
+		Delta:                        tenten,
+		MaxContributionsPerPartition: 1,
+		Lower:                        0.1,
+		Upper:                        0.8,
+		Noise:                        noNoise{},
+	}
+	entries := []float64{0.1, 0.1, 0.8, 0.1}
+	batch, single := NewBoundedMeanFloat64(opt), NewBoundedMeanFloat64(opt)
+	batch.AddBatch(entries)
+	for _, e := range entries {
+		single.Add(e)
+	}
+	if got, want := batch.normalizedSum.sum, single.normalizedSum.sum; got != want {
+		t.Errorf("AddBatch: at the bounds got normalized sum %v, want %v", got, want)
+	}
+}
+
+func TestBMAddBatchInt64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []int64
+	}{
+		{"empty batch", nil},
+		{"entries within bounds", []int64{1, 2, 3, 4}},
+		{"clamped entries", []int64{-100, 3, math.MaxInt64, math.MinInt64}},
+	} {
+		batch, single := getNoiselessBMI(), getNoiselessBMI()
+		batch.Add(1)
+		single.Add(1)
+		batch.AddBatch(tc.entries)
+		for _, e := range tc.entries {
+			single.Add(e)
+		}
+		if got, want := batch.Result(), single.Result(); got != want {
+			t.Errorf("AddBatch: when %s got %f, want %f", tc.desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// ProposeTestReleaseOptions contains the options necessary to run
+// ProposeTestRelease.
+type ProposeTestReleaseOptions struct {
+	// Privacy budget consumed by ProposeTestRelease, including the test.
+	// Required; Delta must be strictly positive.
+	Epsilon, Delta float64
+	// Proposed bound on the local sensitivity of the released value, i.e., on
+	// how much adding or removing a single privacy unit can change it. Required.
+	ProposedSensitivity float64
+	// Type of noise used to release the value. The test always uses Laplace
+	// noise. Defaults to Laplace noise.
+	Noise noise.Noise
+}
+
+// ProposeTestReleaseResult is the result of ProposeTestRelease. All of its
+// fields are differentially private.
+type ProposeTestReleaseResult struct {
+	// Whether the test succeeded and the value was released. If false, Value
+	// is 0 and the value must be considered unavailable.
+	Released bool
+	// The noisy value, if Released.
+	Value float64
+	// The noisy distance to instability, and the threshold it had to exceed
+	// for the value to be released.
+	NoisyDistance, Threshold float64
+}
+
+// ProposeTestRelease releases a value whose sensitivity is data-dependent with
+// the Propose-Test-Release framework of Dwork and Lei, "Differential Privacy
+// and Robust Statistics".
+//
+// The caller proposes a bound on the local sensitivity of the value, and
+// provides distanceToInstability, which returns the number of privacy units
+// that must be added or removed from the data so that the local sensitivity
+// of the value exceeds the given bound. The distance is noised with Laplace
+// noise, and compared to a threshold chosen so that the test succeeds on
+// unstable data with probability at most δ. If the test succeeds, the value is
+// released with noise scaled to the proposed sensitivity; otherwise, a refused
+// result is returned. Either way, the result is (ε, δ)-differentially private.
+//
+// The budget is split in half between the test and the release. With Laplace
+// noise, δ is entirely used for the test; with Gaussian noise, it is split in
+// half between the test and the release.
+//
+// distanceToInstability must have sensitivity 1: adding or removing a privacy
+// unit must change it by at most 1. This is the case for the exact distance to
+// instability.
+func ProposeTestRelease(value float64, distanceToInstability func(sensitivity float64) int64, opt *ProposeTestReleaseOptions) (ProposeTestReleaseResult, error) {
+	const label = "dpagg.ProposeTestRelease"
+	if opt == nil {
+		return ProposeTestReleaseResult{}, fmt.Errorf("%s: options are required", label)
+	}
+	if err := checks.CheckLInfSensitivity(label+" (ProposedSensitivity)", opt.ProposedSensitivity); err != nil {
+		return ProposeTestReleaseResult{}, err
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	epsTest, epsRelease := opt.Epsilon/2, opt.Epsilon/2
+	deltaTest, deltaRelease := opt.Delta, 0.0
+	if noise.ToKind(n) == noise.GaussianNoise {
+		deltaTest, deltaRelease = opt.Delta/2, opt.Delta/2
+	}
+	if err := checks.CheckEpsilonStrict(label, epsTest); err != nil {
+		return ProposeTestReleaseResult{}, err
+	}
+	if err := checks.CheckDelta(label, deltaTest); err != nil {
+		return ProposeTestReleaseResult{}, err
+	}
+	if deltaTest == 0 {
+		return ProposeTestReleaseResult{}, &checks.ParameterError{Label: label, Parameter: "Delta", Value: opt.Delta, Constraint: checks.StrictlyPositive, AllowedRange: "(0, 1)"}
+	}
+
+	distance := distanceToInstability(opt.ProposedSensitivity)
+	if distance < 0 {
+		return ProposeTestReleaseResult{}, fmt.Errorf("%s: distance to instability is %d, should be nonnegative", label, distance)
+	}
+	// If the data is unstable, i.e., its distance is 0, the noisy distance
+	// exceeds the threshold with probability δ/2.
+	threshold := math.Log(1/deltaTest) / epsTest
+	noisyDistance := noise.Laplace().AddNoiseFloat64(float64(distance), 1, 1, epsTest, 0)
+	result := ProposeTestReleaseResult{NoisyDistance: noisyDistance, Threshold: threshold}
+	if noisyDistance <= threshold {
+		return result, nil
+	}
+	result.Released = true
+	result.Value = n.AddNoiseFloat64(value, 1, opt.ProposedSensitivity, epsRelease, deltaRelease)
+	return result, nil
+}
@@ -0,0 +1,103 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+)
+
+func TestProposeTestRelease(t *testing.T) {
+	opt := &ProposeTestReleaseOptions{
+		Epsilon:             2 * ln3,
+		Delta:               1e-5,
+		ProposedSensitivity: 1e-6,
+	}
+	// The threshold is ln(10⁵)/ln(3) ≈ 10.5, and the noise of the test has scale
+	// 1/ln(3), so a distance of 100 passes the test and a distance of 0 fails it,
+	// except with negligible probability.
+	for _, tc := range []struct {
+		desc         string
+		distance     int64
+		wantReleased bool
+	}{
+		{"stable data", 100, true},
+		{"unstable data", 0, false},
+	} {
+		var gotSensitivity float64
+		got, err := ProposeTestRelease(42, func(sensitivity float64) int64 {
+			gotSensitivity = sensitivity
+			return tc.distance
+		}, opt)
+		if err != nil {
+			t.Fatalf("ProposeTestRelease: for %s got error %v", tc.desc, err)
+		}
+		if gotSensitivity != opt.ProposedSensitivity {
+			t.Errorf("ProposeTestRelease: for %s the distance was computed for sensitivity %f, want %f", tc.desc, gotSensitivity, opt.ProposedSensitivity)
+		}
+		if got.Released != tc.wantReleased {
+			t.Errorf("ProposeTestRelease: for %s got Released %t, want %t", tc.desc, got.Released, tc.wantReleased)
+		}
+		if wantThreshold := math.Log(1e5) / ln3; !ApproxEqual(got.Threshold, wantThreshold) {
+			t.Errorf("ProposeTestRelease: for %s got Threshold %f, want %f", tc.desc, got.Threshold, wantThreshold)
+		}
+		if tc.wantReleased && math.Abs(got.Value-42) > 1e-3 {
+			t.Errorf("ProposeTestRelease: for %s got Value %f, want approximately 42", tc.desc, got.Value)
+		}
+		if !tc.wantReleased && got.Value != 0 {
+			t.Errorf("ProposeTestRelease: for %s got Value %f, want 0", tc.desc, got.Value)
+		}
+	}
+}
+
+func TestProposeTestReleaseGaussianSplitsDelta(t *testing.T) {
+	got, err := ProposeTestRelease(42, func(float64) int64 { return 100 }, &ProposeTestReleaseOptions{
+		Epsilon:             2 * ln3,
+		Delta:               2e-5,
+		ProposedSensitivity: 1e-6,
+		Noise:               noise.Gaussian(),
+	})
+	if err != nil {
+		t.Fatalf("ProposeTestRelease: got error %v", err)
+	}
+	if wantThreshold := math.Log(1e5) / ln3; !ApproxEqual(got.Threshold, wantThreshold) {
+		t.Errorf("ProposeTestRelease: got Threshold %f, want %f", got.Threshold, wantThreshold)
+	}
+}
+
+func TestProposeTestReleaseErrors(t *testing.T) {
+	valid := func(distance int64) func(float64) int64 {
+		return func(float64) int64 { return distance }
+	}
+	for _, tc := range []struct {
+		desc     string
+		distance func(float64) int64
+		opt      *ProposeTestReleaseOptions
+	}{
+		{"nil options", valid(1), nil},
+		{"zero delta", valid(1), &ProposeTestReleaseOptions{Epsilon: ln3, ProposedSensitivity: 1}},
+		{"zero epsilon", valid(1), &ProposeTestReleaseOptions{Delta: 1e-5, ProposedSensitivity: 1}},
+		{"zero sensitivity", valid(1), &ProposeTestReleaseOptions{Epsilon: ln3, Delta: 1e-5}},
+		{"negative distance", valid(-1), &ProposeTestReleaseOptions{Epsilon: ln3, Delta: 1e-5, ProposedSensitivity: 1}},
+	} {
+		if _, err := ProposeTestRelease(0, tc.distance, tc.opt); err == nil {
+			t.Errorf("ProposeTestRelease: for %s got no error", tc.desc)
+		}
+	}
+}
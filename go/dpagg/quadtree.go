@@ -0,0 +1,427 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// maxQuadtreeDepth is the largest depth of a Quadtree. A Quadtree of depth d
+// noises (4^(d+1)-1)/3 nodes when computing its result.
+const maxQuadtreeDepth = 10
+
+// Quadtree calculates a differentially private spatial decomposition of
+// two-dimensional points in the box [MinX, MaxX] × [MinY, MaxY] (e.g.,
+// longitudes and latitudes), which answers rectangular range-count queries.
+//
+// The box is recursively split into four quadrants of equal size, Depth times.
+// Each node of the resulting tree counts the points in its cell. When computing
+// the result, every node is noised, and the noisy counts are made consistent
+// (i.e., each inner node equals the sum of its children) with least-squares
+// post-processing. The privacy budget is split between the levels of the tree
+// with the geometric allocation of Cormode et al., "Differentially Private
+// Spatial Decompositions": the level at depth i receives a share of ε
+// proportional to 2^(i/3), so that deeper levels, which are used more often to
+// answer queries, are less noisy. With Gaussian noise, δ is split evenly
+// between the levels.
+//
+// Quadtree supports privacy units contributing multiple points (via the
+// MaxPointsContributed parameter). The aggregation itself doesn't ensure this
+// limit is respected: the caller must enforce it, e.g., with
+// BoundContributions.
+//
+// Not thread-safe.
+type Quadtree struct {
+	// Parameters
+	epsilon              float64
+	delta                float64
+	maxPointsContributed int64
+	minX, maxX           float64
+	minY, maxY           float64
+	depth                int64
+	noise                noise.Noise
+	noiseKind            noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	counts         map[int64]int64 // counts of the non-empty leaves, keyed by leaf index
+	resultReturned bool            // whether the result has already been returned
+}
+
+func quadtreeEquallyInitialized(q1, q2 *Quadtree) bool {
+	return q1.epsilon == q2.epsilon &&
+		q1.delta == q2.delta &&
+		q1.maxPointsContributed == q2.maxPointsContributed &&
+		q1.minX == q2.minX &&
+		q1.maxX == q2.maxX &&
+		q1.minY == q2.minY &&
+		q1.maxY == q2.maxY &&
+		q1.depth == q2.depth &&
+		q1.noiseKind == q2.noiseKind
+}
+
+// QuadtreeOptions contains the options necessary to initialize a Quadtree.
+type QuadtreeOptions struct {
+	Epsilon float64 // Privacy parameter ε. Required.
+	Delta   float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	// How many points may a single user contribute? Defaults to 1.
+	MaxPointsContributed int64
+	// Bounds of the box containing the points. Points outside of the box are
+	// clamped to it. Must be such that MinX < MaxX and MinY < MaxY.
+	MinX, MaxX, MinY, MaxY float64
+	// Number of times the box is split into quadrants; the leaves of the tree
+	// are a 2^Depth × 2^Depth grid. Must be at most 10. Defaults to 6.
+	Depth int64
+	Noise noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewQuadtree returns a new Quadtree with all counts initialized at 0.
+func NewQuadtree(opt *QuadtreeOptions) *Quadtree {
+	if opt == nil {
+		opt = &QuadtreeOptions{}
+	}
+	if err := checks.CheckBoundsFloat64("NewQuadtree (x)", opt.MinX, opt.MaxX); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("CheckBoundsFloat64(minX %f, maxX %f) failed with %v", opt.MinX, opt.MaxX, err)
+	}
+	if err := checks.CheckBoundsFloat64("NewQuadtree (y)", opt.MinY, opt.MaxY); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("CheckBoundsFloat64(minY %f, maxY %f) failed with %v", opt.MinY, opt.MaxY, err)
+	}
+	if opt.MinX == opt.MaxX || opt.MinY == opt.MaxY {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewQuadtree requires MinX < MaxX and MinY < MaxY")
+	}
+
+	// Set defaults.
+	maxPoints := opt.MaxPointsContributed
+	if maxPoints == 0 {
+		maxPoints = 1
+	}
+	depth := opt.Depth
+	if depth == 0 {
+		depth = 6
+	}
+	if depth < 0 || depth > maxQuadtreeDepth {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewQuadtree: Depth is %d, should be between 1 and %d", depth, maxQuadtreeDepth)
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value, with the budget of the root, which is the
+	// smallest.
+	eps, del := opt.Epsilon, opt.Delta
+	rootEps, rootDel := quadtreeLevelBudget(eps, del, depth, 0)
+	n.AddNoiseInt64(0, 1, maxPoints, rootEps, rootDel)
+
+	return &Quadtree{
+		epsilon:              eps,
+		delta:                del,
+		maxPointsContributed: maxPoints,
+		minX:                 opt.MinX,
+		maxX:                 opt.MaxX,
+		minY:                 opt.MinY,
+		maxY:                 opt.MaxY,
+		depth:                depth,
+		noise:                n,
+		noiseKind:            noise.ToKind(n),
+		counts:               make(map[int64]int64),
+		resultReturned:       false,
+	}
+}
+
+// quadtreeLevelBudget returns the privacy budget of the level at depth i of a
+// quadtree of the given depth. The budgets of the levels sum up to (ε, δ).
+func quadtreeLevelBudget(epsilon, delta float64, depth, i int64) (float64, float64) {
+	r := math.Cbrt(2)
+	// The shares r^0, r^1, ..., r^depth sum up to (r^(depth+1)-1)/(r-1).
+	share := math.Pow(r, float64(i)) * (r - 1) / (math.Pow(r, float64(depth+1)) - 1)
+	return epsilon * share, delta / float64(depth+1)
+}
+
+// Add adds a point to the Quadtree. Points outside of the box are clamped to
+// it, and points with a NaN coordinate are skipped.
+func (q *Quadtree) Add(x, y float64) {
+	if q.resultReturned {
+		log.Fatalf("The quadtree has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return
+	}
+	side := int64(1) << uint(q.depth)
+	ix := quadtreeCell(x, q.minX, q.maxX, side)
+	iy := quadtreeCell(y, q.minY, q.maxY, side)
+	q.counts[iy*side+ix]++
+}
+
+// quadtreeCell returns the index of the cell containing v when [lower, upper]
+// is split into numCells cells of equal size.
+func quadtreeCell(v, lower, upper float64, numCells int64) int64 {
+	i := int64(math.Floor((v - lower) / (upper - lower) * float64(numCells)))
+	if i < 0 {
+		return 0
+	}
+	if i >= numCells {
+		return numCells - 1
+	}
+	return i
+}
+
+// Merge merges q2 into q (i.e., adds to q all points that were added to q2).
+// q2 is consumed by this operation: it may not be used after it is merged
+// into q.
+func (q *Quadtree) Merge(q2 *Quadtree) {
+	if err := checkMergeQuadtree(q, q2); err != nil {
+		log.Exit(err)
+	}
+	for i, c := range q2.counts {
+		q.counts[i] += c
+	}
+	q2.resultReturned = true
+}
+
+func checkMergeQuadtree(q1, q2 *Quadtree) error {
+	if q1.resultReturned {
+		return fmt.Errorf("checkMergeQuadtree: q1 already returned the result, cannot be merged with another Quadtree instance")
+	}
+	if q2.resultReturned {
+		return fmt.Errorf("checkMergeQuadtree: q2 already returned the result, cannot be merged with another Quadtree instance")
+	}
+	if !quadtreeEquallyInitialized(q1, q2) {
+		return fmt.Errorf("checkMergeQuadtree: q1 and q2 are not compatible")
+	}
+	return nil
+}
+
+// Result noises every node of the tree, makes the noisy tree consistent and
+// returns it as a QuadtreeResult, which answers range queries. It can be
+// called only once, after which no further operation can be done on the
+// Quadtree.
+func (q *Quadtree) Result() *QuadtreeResult {
+	if q.resultReturned {
+		log.Fatalf("The quadtree has already been calculated and returned. It can only be returned once.")
+	}
+	q.resultReturned = true
+
+	// tree[i] holds the nodes at depth i: the node of cell (ix, iy) has index
+	// iy*2^i+ix.
+	tree := make([][]int64, q.depth+1)
+	side := int64(1) << uint(q.depth)
+	tree[q.depth] = make([]int64, side*side)
+	for i, c := range q.counts {
+		tree[q.depth][i] = c
+	}
+	for d := q.depth - 1; d >= 0; d-- {
+		side /= 2
+		tree[d] = make([]int64, side*side)
+		for i, c := range tree[d+1] {
+			ix, iy := int64(i)%(2*side), int64(i)/(2*side)
+			tree[d][(iy/2)*side+ix/2] += c
+		}
+	}
+
+	// A user's points contribute to a single level by at most
+	// maxPointsContributed, possibly all to the same node.
+	noisy := make([][]float64, len(tree))
+	variances := make([]float64, len(tree))
+	for d, level := range tree {
+		eps, del := quadtreeLevelBudget(q.epsilon, q.delta, q.depth, int64(d))
+		noisy[d] = make([]float64, len(level))
+		for i, c := range level {
+			noisy[d][i] = float64(q.noise.AddNoiseInt64(c, 1, q.maxPointsContributed, eps, del))
+		}
+		// The variance of the noise is inversely proportional to ε² (exactly for
+		// Laplace noise, approximately for Gaussian noise); only the ratios between
+		// levels matter.
+		variances[d] = 1 / (eps * eps)
+	}
+	return &QuadtreeResult{
+		minX:   q.minX,
+		maxX:   q.maxX,
+		minY:   q.minY,
+		maxY:   q.maxY,
+		counts: consistentQuadtree(noisy, variances),
+	}
+}
+
+// consistentQuadtree returns the consistent quadtree closest (in weighted L2
+// distance) to the given noisy quadtree, where noisy[i] holds the nodes at
+// depth i and the noise of these nodes has variance variances[i]. It
+// generalizes the two-pass algorithm of consistentLeaves to levels with
+// different variances.
+func consistentQuadtree(noisy [][]float64, variances []float64) [][]float64 {
+	depth := len(noisy) - 1
+	// Bottom-up pass: z[i] is the inverse-variance weighted average of the noisy
+	// count of the node and of the sum of its children's z, and v is the
+	// variance of z at the current depth.
+	z := make([][]float64, len(noisy))
+	z[depth] = append([]float64(nil), noisy[depth]...)
+	v := variances[depth]
+	for d := depth - 1; d >= 0; d-- {
+		childrenVariance := 4 * v
+		side := 1 << uint(d)
+		z[d] = make([]float64, len(noisy[d]))
+		for i := range z[d] {
+			childrenSum := sumOfChildren(z[d+1], i, side)
+			z[d][i] = (noisy[d][i]/variances[d] + childrenSum/childrenVariance) /
+				(1/variances[d] + 1/childrenVariance)
+		}
+		v = 1 / (1/variances[d] + 1/childrenVariance)
+	}
+	// Top-down pass: the difference between a node and the sum of its
+	// children's z is split evenly among the children, which have the same
+	// variance.
+	for d := 1; d <= depth; d++ {
+		side := 1 << uint(d-1)
+		for i, parent := range z[d-1] {
+			diff := (parent - sumOfChildren(z[d], i, side)) / 4
+			for _, c := range childrenOf(i, side) {
+				z[d][c] += diff
+			}
+		}
+	}
+	return z
+}
+
+// childrenOf returns the indices of the four children of node i of a level
+// with side × side nodes.
+func childrenOf(i, side int) [4]int {
+	ix, iy := i%side, i/side
+	first := 2*iy*2*side + 2*ix
+	return [4]int{first, first + 1, first + 2*side, first + 2*side + 1}
+}
+
+func sumOfChildren(children []float64, i, side int) float64 {
+	var sum float64
+	for _, c := range childrenOf(i, side) {
+		sum += children[c]
+	}
+	return sum
+}
+
+// QuadtreeResult is the differentially private result of a Quadtree. Since it
+// is obtained by post-processing, it can be queried any number of times
+// without consuming additional privacy budget.
+type QuadtreeResult struct {
+	minX, maxX float64
+	minY, maxY float64
+	counts     [][]float64 // counts[i] holds the estimated counts of the nodes at depth i.
+}
+
+// RangeCount returns the estimated number of points in the rectangle
+// [x1, x2] × [y1, y2]. Cells of the tree that are fully contained in the
+// rectangle contribute their estimated count; leaves that partially overlap
+// the rectangle contribute a fraction of their count proportional to the area
+// of the overlap, assuming points are spread uniformly within a leaf.
+func (r *QuadtreeResult) RangeCount(x1, x2, y1, y2 float64) float64 {
+	return r.rangeCount(x1, x2, y1, y2, 0, 0)
+}
+
+// rangeCount returns the estimated number of points in the intersection of the
+// rectangle with the node at the given depth and index.
+func (r *QuadtreeResult) rangeCount(x1, x2, y1, y2 float64, depth, i int) float64 {
+	side := 1 << uint(depth)
+	ix, iy := i%side, i/side
+	width, height := (r.maxX-r.minX)/float64(side), (r.maxY-r.minY)/float64(side)
+	cellX1, cellY1 := r.minX+float64(ix)*width, r.minY+float64(iy)*height
+	cellX2, cellY2 := cellX1+width, cellY1+height
+	overlapX := math.Min(x2, cellX2) - math.Max(x1, cellX1)
+	overlapY := math.Min(y2, cellY2) - math.Max(y1, cellY1)
+	if overlapX < 0 || overlapY < 0 {
+		return 0
+	}
+	if x1 <= cellX1 && cellX2 <= x2 && y1 <= cellY1 && cellY2 <= y2 {
+		return r.counts[depth][i]
+	}
+	if depth == len(r.counts)-1 {
+		return r.counts[depth][i] * (overlapX / width) * (overlapY / height)
+	}
+	var count float64
+	for _, c := range childrenOf(i, side) {
+		count += r.rangeCount(x1, x2, y1, y2, depth+1, c)
+	}
+	return count
+}
+
+// encodableQuadtree can be encoded by the gob package.
+type encodableQuadtree struct {
+	Epsilon              float64
+	Delta                float64
+	MaxPointsContributed int64
+	MinX, MaxX           float64
+	MinY, MaxY           float64
+	Depth                int64
+	NoiseKind            noise.Kind
+	Counts               map[int64]int64
+	ResultReturned       bool
+}
+
+// GobEncode encodes Quadtree.
+func (q *Quadtree) GobEncode() ([]byte, error) {
+	enc := encodableQuadtree{
+		Epsilon:              q.epsilon,
+		Delta:                q.delta,
+		MaxPointsContributed: q.maxPointsContributed,
+		MinX:                 q.minX,
+		MaxX:                 q.maxX,
+		MinY:                 q.minY,
+		MaxY:                 q.maxY,
+		Depth:                q.depth,
+		NoiseKind:            noise.ToKind(q.noise),
+		Counts:               q.counts,
+		ResultReturned:       q.resultReturned,
+	}
+	q.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes Quadtree.
+func (q *Quadtree) GobDecode(data []byte) error {
+	var enc encodableQuadtree
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode Quadtree from bytes")
+		return err
+	}
+	counts := enc.Counts
+	if counts == nil {
+		// gob doesn't transmit empty maps.
+		counts = make(map[int64]int64)
+	}
+	*q = Quadtree{
+		epsilon:              enc.Epsilon,
+		delta:                enc.Delta,
+		maxPointsContributed: enc.MaxPointsContributed,
+		minX:                 enc.MinX,
+		maxX:                 enc.MaxX,
+		minY:                 enc.MinY,
+		maxY:                 enc.MaxY,
+		depth:                enc.Depth,
+		noiseKind:            enc.NoiseKind,
+		noise:                noise.ToNoise(enc.NoiseKind),
+		counts:               counts,
+		resultReturned:       enc.ResultReturned,
+	}
+	return nil
+}
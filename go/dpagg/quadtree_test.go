@@ -0,0 +1,200 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func getNoiselessQuadtree() *Quadtree {
+	return NewQuadtree(&QuadtreeOptions{
+		Epsilon: ln3,
+		Delta:   tenten,
+		MinX:    0,
+		MaxX:    4,
+		MinY:    0,
+		MaxY:    4,
+		Depth:   2,
+		Noise:   noNoise{},
+	})
+}
+
+func TestNewQuadtree(t *testing.T) {
+	q := NewQuadtree(&QuadtreeOptions{Epsilon: ln3, MinX: -180, MaxX: 180, MinY: -90, MaxY: 90})
+	want := &Quadtree{
+		epsilon:              ln3,
+		maxPointsContributed: 1,
+		minX:                 -180,
+		maxX:                 180,
+		minY:                 -90,
+		maxY:                 90,
+		depth:                6,
+		noise:                noise.Laplace(),
+		noiseKind:            noise.LaplaceNoise,
+		counts:               map[int64]int64{},
+	}
+	if !cmp.Equal(q, want, cmp.AllowUnexported(Quadtree{})) {
+		t.Errorf("NewQuadtree: got %+v, want %+v", q, want)
+	}
+}
+
+func TestQuadtreeLevelBudget(t *testing.T) {
+	var epsSum, delSum float64
+	for i := int64(0); i <= 5; i++ {
+		eps, del := quadtreeLevelBudget(ln3, 1e-5, 5, i)
+		epsSum += eps
+		delSum += del
+		if i > 0 {
+			previous, _ := quadtreeLevelBudget(ln3, 1e-5, 5, i-1)
+			if !ApproxEqual(eps/previous, math.Cbrt(2)) {
+				t.Errorf("quadtreeLevelBudget: budget of depth %d is %f times the budget of depth %d, want 2^(1/3)", i, eps/previous, i-1)
+			}
+		}
+	}
+	if !ApproxEqual(epsSum, ln3) || !ApproxEqual(delSum, 1e-5) {
+		t.Errorf("quadtreeLevelBudget: budgets sum up to (%f, %e), want (%f, %e)", epsSum, delSum, ln3, 1e-5)
+	}
+}
+
+func TestQuadtreeRangeCount(t *testing.T) {
+	q := getNoiselessQuadtree()
+	// With depth 2, the leaves are the 1×1 cells of [0, 4] × [0, 4].
+	for _, p := range [][2]float64{{0.5, 0.5}, {0.5, 0.5}, {1.5, 2.5}, {3.5, 3.5}, {-10, 10}, {math.NaN(), 1}} {
+		q.Add(p[0], p[1])
+	}
+	r := q.Result()
+	for _, tc := range []struct {
+		desc           string
+		x1, x2, y1, y2 float64
+		want           float64
+	}{
+		{"whole box", 0, 4, 0, 4, 5},
+		{"larger than the box", -100, 100, -100, 100, 5},
+		{"single leaf", 0, 1, 0, 1, 2},
+		{"quadrant", 0, 2, 2, 4, 2}, // contains (1.5, 2.5) and the clamped (0, 4)
+		{"partial leaf", 0, 0.5, 0, 1, 1},
+		{"empty range", 2, 4, 0, 2, 0},
+		{"outside the box", 5, 6, 5, 6, 0},
+	} {
+		if got := r.RangeCount(tc.x1, tc.x2, tc.y1, tc.y2); !ApproxEqual(got, tc.want) {
+			t.Errorf("RangeCount: for %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestConsistentQuadtree(t *testing.T) {
+	noisy := [][]float64{{10}, {1, 2, 3, 0}}
+	// Minimizing (r-10)² + Σ(lᵢ-yᵢ)² subject to r = Σlᵢ adds 0.8 to each leaf.
+	got := consistentQuadtree(noisy, []float64{1, 1})
+	want := [][]float64{{9.2}, {1.8, 2.8, 3.8, 0.8}}
+	if !cmp.Equal(got, want, cmpopts.EquateApprox(0, 1e-9)) {
+		t.Errorf("consistentQuadtree: got %v, want %v", got, want)
+	}
+	// A much noisier root barely changes the leaves.
+	got = consistentQuadtree(noisy, []float64{1e12, 1})
+	if !ApproxEqual(got[0][0], 6) {
+		t.Errorf("consistentQuadtree: with a noisy root got root %f, want 6", got[0][0])
+	}
+}
+
+func TestQuadtreeMerge(t *testing.T) {
+	q1, q2 := getNoiselessQuadtree(), getNoiselessQuadtree()
+	q1.Add(1, 1)
+	q2.Add(1, 1)
+	q2.Add(3, 3)
+	q1.Merge(q2)
+	if got, want := q1.Result().RangeCount(0, 4, 0, 4), 3.0; !ApproxEqual(got, want) {
+		t.Errorf("Merge: got %f points, want %f", got, want)
+	}
+	if !q2.resultReturned {
+		t.Errorf("Merge: q2 should be marked as consumed")
+	}
+}
+
+func TestQuadtreeCheckMerge(t *testing.T) {
+	opt := func(depth int64) *QuadtreeOptions {
+		return &QuadtreeOptions{Epsilon: ln3, MinX: 0, MaxX: 1, MinY: 0, MaxY: 1, Depth: depth}
+	}
+	for _, tc := range []struct {
+		desc    string
+		opt1    *QuadtreeOptions
+		opt2    *QuadtreeOptions
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", opt(3), opt(3), false, false, false},
+		{"q1 returned its result", opt(3), opt(3), true, false, true},
+		{"q2 returned its result", opt(3), opt(3), false, true, true},
+		{"different depths", opt(3), opt(4), false, false, true},
+	} {
+		q1, q2 := NewQuadtree(tc.opt1), NewQuadtree(tc.opt2)
+		q1.resultReturned = tc.state1
+		q2.resultReturned = tc.state2
+		if err := checkMergeQuadtree(q1, q2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestQuadtreeSerialization(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		points [][2]float64
+	}{
+		{"empty quadtree", nil},
+		{"non-empty quadtree", [][2]float64{{0.1, 0.2}, {0.7, 0.9}}},
+	} {
+		opt := &QuadtreeOptions{
+			Epsilon:              ln3,
+			Delta:                1e-5,
+			MaxPointsContributed: 2,
+			MinX:                 0,
+			MaxX:                 1,
+			MinY:                 0,
+			MaxY:                 1,
+			Depth:                3,
+			Noise:                noise.Gaussian(),
+		}
+		q, qUnchanged := NewQuadtree(opt), NewQuadtree(opt)
+		for _, p := range tc.points {
+			q.Add(p[0], p[1])
+			qUnchanged.Add(p[0], p[1])
+		}
+		bytes, err := encode(q)
+		if err != nil {
+			t.Fatalf("encode(Quadtree) error: %v", err)
+		}
+		qUnmarshalled := new(Quadtree)
+		if err := decode(qUnmarshalled, bytes); err != nil {
+			t.Fatalf("decode(Quadtree) error: %v", err)
+		}
+		// Check that encoding -> decoding is the identity function.
+		if !cmp.Equal(qUnchanged, qUnmarshalled, cmp.AllowUnexported(Quadtree{})) {
+			t.Errorf("decode(encode(_)) for %s: got %+v, want %+v", tc.desc, qUnmarshalled, qUnchanged)
+		}
+		// Check that the original Quadtree has its resultReturned set to true after serialization.
+		if !q.resultReturned {
+			t.Errorf("Quadtree %v should have its resultReturned set to true after being serialized", q)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// BoundedRatioFloat64 calculates a differentially private ratio of two sums
+// computed over the same contributions, e.g., revenue per visit or errors per
+// request. Each contribution is a (numerator, denominator) pair, and the
+// result is the sum of the numerators divided by the sum of the denominators.
+//
+// The ratio is computed by dividing a noisy sum of the numerators by a noisy
+// sum of the denominators. The privacy budget is split evenly between the two
+// sums. The noisy sum of the denominators is set to at least DenominatorFloor
+// before dividing, which avoids dividing by a very small or negative noisy
+// value. Since this is a mere post-processing step, the DP bounds are
+// preserved.
+//
+// BoundedRatioFloat64 supports scaling the noise in the case where users can
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times
+// (via the MaxContributionsPerPartition parameter).
+//
+// Note: Do not use when your results may cause overflows for int64 or float64
+// values. This aggregation is not hardened for such applications yet.
+//
+// Not thread-safe.
+type BoundedRatioFloat64 struct {
+	// Parameters
+	denominatorFloor float64
+
+	// State variables
+	numerator      BoundedSumFloat64
+	denominator    BoundedSumFloat64
+	resultReturned bool // whether the result has already been returned
+}
+
+func brEquallyInitializedFloat64(br1, br2 *BoundedRatioFloat64) bool {
+	return br1.denominatorFloor == br2.denominatorFloor &&
+		bsEquallyInitializedFloat64(&br1.numerator, &br2.numerator) &&
+		bsEquallyInitializedFloat64(&br1.denominator, &br2.denominator)
+}
+
+// BoundedRatioFloat64Options contains the options necessary to initialize a
+// BoundedRatioFloat64.
+type BoundedRatioFloat64Options struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	// How many times may a single user contribute to a single partition? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds for clamping numerators. Must be such that
+	// LowerNumerator < UpperNumerator.
+	LowerNumerator, UpperNumerator float64
+	// Lower and Upper bounds for clamping denominators. Must be such that
+	// 0 <= LowerDenominator < UpperDenominator.
+	LowerDenominator, UpperDenominator float64
+	// Smallest value of the noisy sum of denominators used to compute the
+	// ratio. Must be strictly positive. Defaults to UpperDenominator, i.e., the
+	// largest denominator of a single contribution.
+	DenominatorFloor float64
+	Noise            noise.Noise // Type of noise used in BoundedRatio. Defaults to Laplace noise.
+}
+
+// NewBoundedRatioFloat64 returns a new BoundedRatioFloat64.
+func NewBoundedRatioFloat64(opt *BoundedRatioFloat64Options) *BoundedRatioFloat64 {
+	if opt == nil {
+		opt = &BoundedRatioFloat64Options{}
+	}
+	if opt.LowerNumerator == opt.UpperNumerator || opt.LowerDenominator == opt.UpperDenominator {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedRatioFloat64 requires LowerNumerator < UpperNumerator and LowerDenominator < UpperDenominator")
+	}
+	if opt.LowerDenominator < 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedRatioFloat64: LowerDenominator is %f, should be nonnegative", opt.LowerDenominator)
+	}
+	floor := opt.DenominatorFloor
+	if floor == 0 {
+		floor = opt.UpperDenominator
+	}
+	if floor <= 0 || math.IsInf(floor, 0) || math.IsNaN(floor) {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewBoundedRatioFloat64: DenominatorFloor is %f, should be finite and strictly positive", floor)
+	}
+
+	// We split the budget in half between the numerator and the denominator.
+	eps, del := opt.Epsilon/2, opt.Delta/2
+	newSum := func(lower, upper float64) BoundedSumFloat64 {
+		return *NewBoundedSumFloat64(&BoundedSumFloat64Options{
+			Epsilon:                      eps,
+			Delta:                        del,
+			MaxPartitionsContributed:     opt.MaxPartitionsContributed,
+			MaxContributionsPerPartition: opt.MaxContributionsPerPartition,
+			Lower:                        lower,
+			Upper:                        upper,
+			Noise:                        opt.Noise,
+		})
+	}
+	return &BoundedRatioFloat64{
+		denominatorFloor: floor,
+		numerator:        newSum(opt.LowerNumerator, opt.UpperNumerator),
+		denominator:      newSum(opt.LowerDenominator, opt.UpperDenominator),
+		resultReturned:   false,
+	}
+}
+
+// Add adds a (numerator, denominator) contribution to a BoundedRatioFloat64.
+// It skips contributions where the numerator or the denominator is NaN, and
+// doesn't count them in the final result.
+func (br *BoundedRatioFloat64) Add(numerator, denominator float64) {
+	if br.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The ratio has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(numerator) || math.IsNaN(denominator) {
+		return
+	}
+	br.numerator.Add(numerator)
+	br.denominator.Add(denominator)
+}
+
+// Result returns a differentially private ratio of the sum of the numerators
+// by the sum of the denominators added so far. It can be called only once,
+// after which no further operation can be done on the BoundedRatioFloat64.
+func (br *BoundedRatioFloat64) Result() float64 {
+	if br.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The ratio has already been calculated and returned. It can only be returned once.")
+	}
+	br.resultReturned = true
+	return br.numerator.Result() / math.Max(br.denominatorFloor, br.denominator.Result())
+}
+
+// ResultWithConfidenceInterval is similar to Result() but additionally returns
+// a confidence interval that contains the true (unnoised) ratio of the clamped
+// sums with a probability of at least 1 - alpha, if the true sum of the
+// denominators is at least DenominatorFloor.
+//
+// As for BoundedMeanFloat64, the interval is computed from a confidence
+// interval for each of the two noisy sums, each holding with probability
+// sqrt(1 - alpha).
+//
+// An error is returned, and the BoundedRatioFloat64 is left untouched, if alpha
+// is not strictly between 0 and 1, or if the noise doesn't implement
+// noise.ConfidenceIntervalComputer.
+func (br *BoundedRatioFloat64) ResultWithConfidenceInterval(alpha float64) (float64, noise.ConfidenceInterval, error) {
+	const label = "dpagg.BoundedRatioFloat64.ResultWithConfidenceInterval"
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	numCINoise, err := confidenceIntervalComputer(label, br.numerator.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	denCINoise, err := confidenceIntervalComputer(label, br.denominator.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	if br.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The ratio has already been calculated and returned. It can only be returned once.")
+	}
+	br.resultReturned = true
+	noisedNumerator := br.numerator.Result()
+	noisedDenominator := br.denominator.Result()
+	result := noisedNumerator / math.Max(br.denominatorFloor, noisedDenominator)
+
+	alphaPart := 1 - math.Sqrt(1-alpha)
+	n := &br.numerator
+	numCI, err := numCINoise.ComputeConfidenceIntervalFloat64(noisedNumerator, n.l0Sensitivity, n.lInfSensitivity, n.epsilon, n.delta, alphaPart)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+	numCI = clampSumConfidenceInterval(numCI, n.lower, n.upper)
+	d := &br.denominator
+	denCI, err := denCINoise.ComputeConfidenceIntervalFloat64(noisedDenominator, d.l0Sensitivity, d.lInfSensitivity, d.epsilon, d.delta, alphaPart)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+
+	lower, upper := ratioBounds(numCI.LowerBound, numCI.UpperBound,
+		math.Max(br.denominatorFloor, denCI.LowerBound), math.Max(br.denominatorFloor, denCI.UpperBound))
+	return result, noise.ConfidenceInterval{
+		LowerBound:      lower,
+		UpperBound:      upper,
+		ConfidenceLevel: 1 - alpha,
+	}, nil
+}
+
+// ratioBounds returns the smallest and largest values of the ratio x/y, for
+// x in [xLower, xUpper] and y in [yLower, yUpper], assuming that 0 < yLower.
+func ratioBounds(xLower, xUpper, yLower, yUpper float64) (lower, upper float64) {
+	// For a fixed x, the ratio x/y is the most extreme when y is the smallest;
+	// it is the least extreme when y is the largest.
+	if xLower < 0 {
+		lower = xLower / yLower
+	} else {
+		lower = xLower / yUpper
+	}
+	if xUpper < 0 {
+		upper = xUpper / yUpper
+	} else {
+		upper = xUpper / yLower
+	}
+	return lower, upper
+}
+
+// Merge merges br2 into br (i.e., adds to br all entries that were added to
+// br2). br2 is consumed by this operation: br2 may not be used after it is
+// merged into br.
+func (br *BoundedRatioFloat64) Merge(br2 *BoundedRatioFloat64) {
+	if err := checkMergeBoundedRatioFloat64(br, br2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	br.numerator.sum += br2.numerator.sum
+	br.denominator.sum += br2.denominator.sum
+	br2.resultReturned = true
+}
+
+func checkMergeBoundedRatioFloat64(br1, br2 *BoundedRatioFloat64) error {
+	if br1.resultReturned {
+		return fmt.Errorf("checkMergeBoundedRatioFloat64: br1 already returned the result, cannot be merged with another BoundedRatio instance")
+	}
+	if br2.resultReturned {
+		return fmt.Errorf("checkMergeBoundedRatioFloat64: br2 already returned the result, cannot be merged with another BoundedRatio instance")
+	}
+
+	if !brEquallyInitializedFloat64(br1, br2) {
+		return fmt.Errorf("checkMergeBoundedRatioFloat64: br1 and br2 are not compatible")
+	}
+
+	return nil
+}
+
+// GobEncode encodes BoundedRatioFloat64.
+func (br *BoundedRatioFloat64) GobEncode() ([]byte, error) {
+	enc := encodableBoundedRatioFloat64{
+		DenominatorFloor:     br.denominatorFloor,
+		EncodableNumerator:   &br.numerator,
+		EncodableDenominator: &br.denominator,
+		ResultReturned:       br.resultReturned,
+	}
+	br.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes BoundedRatioFloat64.
+func (br *BoundedRatioFloat64) GobDecode(data []byte) error {
+	var enc encodableBoundedRatioFloat64
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode BoundedRatioFloat64 from bytes")
+		return err
+	}
+	*br = BoundedRatioFloat64{
+		denominatorFloor: enc.DenominatorFloor,
+		numerator:        *enc.EncodableNumerator,
+		denominator:      *enc.EncodableDenominator,
+		resultReturned:   enc.ResultReturned,
+	}
+	return nil
+}
+
+// encodableBoundedRatioFloat64 can be encoded by the gob package.
+type encodableBoundedRatioFloat64 struct {
+	DenominatorFloor     float64
+	EncodableNumerator   *BoundedSumFloat64
+	EncodableDenominator *BoundedSumFloat64
+	ResultReturned       bool
+}
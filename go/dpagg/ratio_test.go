@@ -0,0 +1,190 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessBRF(floor float64) *BoundedRatioFloat64 {
+	return NewBoundedRatioFloat64(&BoundedRatioFloat64Options{
+		Epsilon:          ln3,
+		Delta:            tenten,
+		LowerNumerator:   0,
+		UpperNumerator:   10,
+		LowerDenominator: 0,
+		UpperDenominator: 5,
+		DenominatorFloor: floor,
+		Noise:            noNoise{},
+	})
+}
+
+func TestNewBoundedRatioFloat64(t *testing.T) {
+	br := NewBoundedRatioFloat64(&BoundedRatioFloat64Options{
+		Epsilon:                  ln3,
+		MaxPartitionsContributed: 2,
+		LowerNumerator:           -1,
+		UpperNumerator:           3,
+		LowerDenominator:         1,
+		UpperDenominator:         4,
+	})
+	if br.denominatorFloor != 4 {
+		t.Errorf("NewBoundedRatioFloat64: got denominatorFloor %f, want the default of 4", br.denominatorFloor)
+	}
+	for _, s := range []*BoundedSumFloat64{&br.numerator, &br.denominator} {
+		if s.epsilon != ln3/2 || s.l0Sensitivity != 2 || s.noiseKind != noise.LaplaceNoise {
+			t.Errorf("NewBoundedRatioFloat64: got sum %+v, want half of the budget, l0Sensitivity 2 and Laplace noise", s)
+		}
+	}
+}
+
+func TestBRResultFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		floor float64
+		want  float64
+	}{
+		{"denominator above the floor", 1, 13.0 / 4.0},
+		{"denominator below the floor", 6, 13.0 / 6.0},
+	} {
+		br := getNoiselessBRF(tc.floor)
+		br.Add(3, 1)
+		br.Add(20, 2) // numerator clamped to 10
+		br.Add(-1, 1) // numerator clamped to 0
+		br.Add(math.NaN(), 1)
+		if got := br.Result(); !ApproxEqual(got, tc.want) {
+			t.Errorf("Result: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestBRResultWithConfidenceIntervalFloat64(t *testing.T) {
+	br := getNoiselessBRF(1)
+	br.Add(3, 1)
+	br.Add(5, 2)
+	br.Add(4, 1)
+	got, ci, err := br.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	if !ApproxEqual(got, 3) {
+		t.Errorf("ResultWithConfidenceInterval: got result %f, want 3", got)
+	}
+	// The numerator is 12 with interval [11, 13] and the denominator is 4 with
+	// interval [3, 5], so the ratio lies in [11/5, 13/3].
+	if !ApproxEqual(ci.LowerBound, 11.0/5.0) || !ApproxEqual(ci.UpperBound, 13.0/3.0) || !ApproxEqual(ci.ConfidenceLevel, 0.95) {
+		t.Errorf("ResultWithConfidenceInterval: got %+v, want [%f, %f]", ci, 11.0/5.0, 13.0/3.0)
+	}
+}
+
+func TestBRResultWithConfidenceIntervalInvalidAlphaFloat64(t *testing.T) {
+	br := getNoiselessBRF(1)
+	if _, _, err := br.ResultWithConfidenceInterval(1); err == nil {
+		t.Errorf("ResultWithConfidenceInterval: with alpha 1 got no error")
+	}
+	if br.resultReturned {
+		t.Errorf("ResultWithConfidenceInterval: with an invalid alpha the ratio should be left untouched")
+	}
+}
+
+func TestRatioBounds(t *testing.T) {
+	for _, tc := range []struct {
+		xLower, xUpper, yLower, yUpper float64
+		wantLower, wantUpper           float64
+	}{
+		{2, 4, 1, 2, 1, 4},
+		{-4, -2, 1, 2, -4, -1},
+		{-2, 4, 1, 2, -2, 4},
+	} {
+		lower, upper := ratioBounds(tc.xLower, tc.xUpper, tc.yLower, tc.yUpper)
+		if !ApproxEqual(lower, tc.wantLower) || !ApproxEqual(upper, tc.wantUpper) {
+			t.Errorf("ratioBounds(%f, %f, %f, %f): got [%f, %f], want [%f, %f]",
+				tc.xLower, tc.xUpper, tc.yLower, tc.yUpper, lower, upper, tc.wantLower, tc.wantUpper)
+		}
+	}
+}
+
+func TestBRMergeFloat64(t *testing.T) {
+	br1, br2 := getNoiselessBRF(1), getNoiselessBRF(1)
+	br1.Add(3, 1)
+	br2.Add(5, 1)
+	br1.Merge(br2)
+	if got := br1.Result(); !ApproxEqual(got, 4) {
+		t.Errorf("Merge: got %f, want 4", got)
+	}
+	if !br2.resultReturned {
+		t.Errorf("Merge: br2 should be marked as consumed")
+	}
+}
+
+func TestCheckMergeBRFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		br1     *BoundedRatioFloat64
+		br2     *BoundedRatioFloat64
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", getNoiselessBRF(1), getNoiselessBRF(1), false, false, false},
+		{"br1 returned its result", getNoiselessBRF(1), getNoiselessBRF(1), true, false, true},
+		{"br2 returned its result", getNoiselessBRF(1), getNoiselessBRF(1), false, true, true},
+		{"different floors", getNoiselessBRF(1), getNoiselessBRF(2), false, false, true},
+	} {
+		tc.br1.resultReturned = tc.state1
+		tc.br2.resultReturned = tc.state2
+		if err := checkMergeBoundedRatioFloat64(tc.br1, tc.br2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBRSerializationFloat64(t *testing.T) {
+	opt := &BoundedRatioFloat64Options{
+		Epsilon:          ln3,
+		Delta:            1e-5,
+		LowerNumerator:   -5,
+		UpperNumerator:   5,
+		LowerDenominator: 0,
+		UpperDenominator: 1,
+		DenominatorFloor: 10,
+		Noise:            noise.Gaussian(),
+	}
+	br, brUnchanged := NewBoundedRatioFloat64(opt), NewBoundedRatioFloat64(opt)
+	br.Add(1, 1)
+	brUnchanged.Add(1, 1)
+	bytes, err := encode(br)
+	if err != nil {
+		t.Fatalf("encode(BoundedRatioFloat64) error: %v", err)
+	}
+	brUnmarshalled := new(BoundedRatioFloat64)
+	if err := decode(brUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(BoundedRatioFloat64) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(brUnchanged, brUnmarshalled, cmp.AllowUnexported(BoundedRatioFloat64{}, BoundedSumFloat64{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", brUnmarshalled, brUnchanged)
+	}
+	// Check that the original BoundedRatioFloat64 has its resultReturned set to true after serialization.
+	if !br.resultReturned {
+		t.Errorf("BoundedRatioFloat64 %v should have its resultReturned set to true after being serialized", br)
+	}
+}
@@ -0,0 +1,135 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/differential-privacy/go/checks"
+)
+
+// maxSignificantDigits is the largest valid Rounding.SignificantDigits. Every
+// float64 is exactly determined by 17 significant digits, so rounding to more
+// digits wouldn't change any value.
+const maxSignificantDigits = 17
+
+// Rounding coarsens the results of an aggregation, e.g., to release counts
+// rounded to the nearest 10, or with 2 significant digits. It is applied as
+// a post-processing step on the noisy result: it does not consume privacy
+// budget, and it is applied after thresholding and clamping, so it doesn't
+// change which results are released.
+//
+// At most one of Granularity and SignificantDigits can be set. The zero value
+// doesn't round results.
+type Rounding struct {
+	// Granularity rounds results to the nearest multiple of Granularity,
+	// halfway values being rounded away from zero. Must be nonnegative and
+	// finite; 0 means no rounding.
+	Granularity float64
+	// SignificantDigits rounds results to this number of significant digits,
+	// halfway values being rounded away from zero. Must be in [0, 17]; 0
+	// means no rounding.
+	SignificantDigits int
+}
+
+// Validate returns an error if r is not a valid Rounding.
+func (r Rounding) Validate() error {
+	return checkRounding("dpagg.Rounding", r)
+}
+
+// checkRounding returns an error if r is not a valid Rounding.
+func checkRounding(label string, r Rounding) error {
+	if math.IsNaN(r.Granularity) || math.IsInf(r.Granularity, 0) || r.Granularity < 0 {
+		return &checks.ParameterError{Label: label, Parameter: "Rounding.Granularity", Value: r.Granularity, Constraint: checks.InRange, AllowedRange: "[0, MaxFloat64]"}
+	}
+	if r.SignificantDigits < 0 || r.SignificantDigits > maxSignificantDigits {
+		return &checks.ParameterError{Label: label, Parameter: "Rounding.SignificantDigits", Value: r.SignificantDigits, Constraint: checks.InRange, AllowedRange: fmt.Sprintf("[0, %d]", maxSignificantDigits)}
+	}
+	if r.Granularity > 0 && r.SignificantDigits > 0 {
+		return fmt.Errorf("%s: Rounding.Granularity (%f) and Rounding.SignificantDigits (%d) cannot both be set", label, r.Granularity, r.SignificantDigits)
+	}
+	return nil
+}
+
+// RoundFloat64 returns x rounded as specified by r. NaN and infinite values
+// are returned unchanged. So are values too small for their significant digits
+// to be computed accurately, i.e., subnormal values, or values rounded to so
+// many digits that the last one is smaller than the smallest float64.
+func (r Rounding) RoundFloat64(x float64) float64 {
+	if x == 0 || math.IsNaN(x) || math.IsInf(x, 0) {
+		return x
+	}
+	switch {
+	case r.Granularity > 0:
+		return math.Round(x/r.Granularity) * r.Granularity
+	case r.SignificantDigits > 0:
+		// math.Log10 is inaccurate for subnormal values, i.e., smaller than 2^-1022.
+		if math.Abs(x) < 0x1p-1022 {
+			return x
+		}
+		// The first significant digit of x has weight 10^(exponent-1), and the
+		// last one kept has weight 10^(exponent-SignificantDigits).
+		exponent := int(math.Floor(math.Log10(math.Abs(x)))) + 1
+		shift := exponent - r.SignificantDigits
+		if shift >= 0 {
+			scale := math.Pow10(shift)
+			return math.Round(x/scale) * scale
+		}
+		// Multiplying by the inverse scale, which is an exact integer for small
+		// shifts, gives the nearest float64 to the rounded result.
+		scale := math.Pow10(-shift)
+		if math.IsInf(scale, 0) {
+			return x
+		}
+		return math.Round(x*scale) / scale
+	default:
+		return x
+	}
+}
+
+// RoundInt64 returns x rounded as specified by r. Rounding to an integer
+// Granularity uses integer arithmetic; other roundings are computed in
+// floating point, and may lose precision for values larger than 2^53 in
+// absolute value. Values whose rounding would overflow an int64 are rounded
+// toward zero instead, or saturated at math.MinInt64 and math.MaxInt64, so
+// that rounding never changes the sign of x.
+func (r Rounding) RoundInt64(x int64) int64 {
+	if r.Granularity == 0 && r.SignificantDigits == 0 {
+		return x
+	}
+	if g := int64(r.Granularity); g > 0 && float64(g) == r.Granularity {
+		remainder := x % g // has the sign of x
+		x -= remainder
+		if remainder > 0 && remainder >= g-remainder && x <= math.MaxInt64-g {
+			x += g
+		}
+		if remainder < 0 && -remainder >= g+remainder && x >= math.MinInt64+g {
+			x -= g
+		}
+		return x
+	}
+	rounded := math.Round(r.RoundFloat64(float64(x)))
+	// float64(math.MaxInt64) is 2^63, which doesn't fit in an int64.
+	if rounded >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if rounded <= math.MinInt64 {
+		return math.MinInt64
+	}
+	return int64(rounded)
+}
@@ -0,0 +1,106 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		rounding Rounding
+		x        float64
+		want     float64
+	}{
+		{"no rounding", Rounding{}, 1234.5678, 1234.5678},
+		{"granularity 10", Rounding{Granularity: 10}, 1234.5678, 1230},
+		{"granularity 10, halfway", Rounding{Granularity: 10}, 15, 20},
+		{"granularity 10, negative halfway", Rounding{Granularity: 10}, -15, -20},
+		{"granularity 0.25", Rounding{Granularity: 0.25}, 1.3, 1.25},
+		{"granularity larger than x", Rounding{Granularity: 100}, 42, 0},
+		{"2 significant digits", Rounding{SignificantDigits: 2}, 1234.5678, 1200},
+		{"2 significant digits, power of 10", Rounding{SignificantDigits: 2}, 1000, 1000},
+		{"2 significant digits, carry", Rounding{SignificantDigits: 2}, 996, 1000},
+		{"2 significant digits, small value", Rounding{SignificantDigits: 2}, 0.012345, 0.012},
+		{"2 significant digits, negative value", Rounding{SignificantDigits: 2}, -1250, -1300},
+		{"6 significant digits", Rounding{SignificantDigits: 6}, math.Pi, 3.14159},
+		{"many significant digits", Rounding{SignificantDigits: 17}, 1234.5678, 1234.5678},
+		{"many significant digits, tiny value", Rounding{SignificantDigits: 17}, 1e-300, 1e-300},
+		{"1 significant digit, subnormal value", Rounding{SignificantDigits: 1}, math.SmallestNonzeroFloat64, math.SmallestNonzeroFloat64},
+		{"zero", Rounding{SignificantDigits: 2}, 0, 0},
+		{"infinity", Rounding{Granularity: 10}, math.Inf(-1), math.Inf(-1)},
+	} {
+		if got := tc.rounding.RoundFloat64(tc.x); got != tc.want {
+			t.Errorf("RoundFloat64: when %s got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestRoundInt64(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		rounding Rounding
+		x        int64
+		want     int64
+	}{
+		{"no rounding", Rounding{}, math.MaxInt64, math.MaxInt64},
+		{"granularity 10", Rounding{Granularity: 10}, 1234, 1230},
+		{"granularity 10, halfway", Rounding{Granularity: 10}, 1235, 1240},
+		{"granularity 10, negative", Rounding{Granularity: 10}, -1236, -1240},
+		{"granularity 10, negative halfway", Rounding{Granularity: 10}, -1235, -1240},
+		{"granularity 3", Rounding{Granularity: 3}, 7, 6},
+		{"granularity 10, large value", Rounding{Granularity: 10}, 1<<60 + 1, 1152921504606846980},
+		{"granularity 2.5", Rounding{Granularity: 2.5}, 6, 5},
+		{"2 significant digits", Rounding{SignificantDigits: 2}, 1250, 1300},
+		{"2 significant digits, small value", Rounding{SignificantDigits: 2}, 7, 7},
+		{"granularity 10, max value", Rounding{Granularity: 10}, math.MaxInt64, math.MaxInt64 - 7},
+		{"granularity 10, min value", Rounding{Granularity: 10}, math.MinInt64, math.MinInt64 + 8},
+		{"granularity 2.5, max value", Rounding{Granularity: 2.5}, math.MaxInt64, math.MaxInt64},
+		{"1 significant digit, min value", Rounding{SignificantDigits: 1}, math.MinInt64, -9000000000000000000},
+		{"17 significant digits, max value", Rounding{SignificantDigits: 17}, math.MaxInt64, math.MaxInt64},
+		{"17 significant digits", Rounding{SignificantDigits: 17}, 12345, 12345},
+	} {
+		if got := tc.rounding.RoundInt64(tc.x); got != tc.want {
+			t.Errorf("RoundInt64: when %s got %d, want %d", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestCheckRounding(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		rounding Rounding
+		wantErr  bool
+	}{
+		{"no rounding", Rounding{}, false},
+		{"granularity", Rounding{Granularity: 10}, false},
+		{"significant digits", Rounding{SignificantDigits: 2}, false},
+		{"negative granularity", Rounding{Granularity: -10}, true},
+		{"NaN granularity", Rounding{Granularity: math.NaN()}, true},
+		{"infinite granularity", Rounding{Granularity: math.Inf(1)}, true},
+		{"negative significant digits", Rounding{SignificantDigits: -1}, true},
+		{"17 significant digits", Rounding{SignificantDigits: 17}, false},
+		{"too many significant digits", Rounding{SignificantDigits: 18}, true},
+		{"both set", Rounding{Granularity: 10, SignificantDigits: 2}, true},
+	} {
+		if err := checkRounding("test", tc.rounding); (err != nil) != tc.wantErr {
+			t.Errorf("checkRounding: when %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
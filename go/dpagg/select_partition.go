@@ -23,6 +23,7 @@ import (
 
 	log "github.com/golang/glog"
 	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/go/rand"
 )
 
@@ -64,6 +65,10 @@ import (
 // recurrence relation. See https://arxiv.org/pdf/2006.03684.pdf for details
 // on the underlying mathematics.
 //
+// This is the default OptimalSelection strategy. Other strategies can be
+// chosen in PreAggSelectPartitionOptions, and a PreThreshold can be set to
+// never select partitions with too few privacy IDs.
+//
 // PreAggSelectPartition is a utility for maintaining the count of IDs in a single
 // partition and then determining whether the partition should be
 // materialized. Use Add() to increment the count of IDs and Result() to decide
@@ -73,6 +78,14 @@ type PreAggSelectPartition struct {
 	epsilon       float64
 	delta         float64
 	l0Sensitivity int64
+	strategy      PartitionSelectionStrategy
+	preThreshold  int64
+	// threshold and noiseScale (the scale λ of Laplace noise or the standard
+	// deviation σ of Gaussian noise) of LaplaceThresholding and
+	// GaussianThresholding. They are derived from the parameters once, since
+	// computing them for Gaussian noise requires binary searches.
+	threshold  float64
+	noiseScale float64
 
 	// State variables
 	// idCount is the count of unique privacy IDs in the partition.
@@ -82,8 +95,41 @@ type PreAggSelectPartition struct {
 }
 
 func (s *PreAggSelectPartition) String() string {
-	return fmt.Sprintf("&PreAggSelectPartition(epsilon %f, delta %e, l0Sensitivity %d, resultReturned %t)",
-		s.epsilon, s.delta, s.l0Sensitivity, s.resultReturned)
+	return fmt.Sprintf("&PreAggSelectPartition(epsilon %f, delta %e, l0Sensitivity %d, strategy %v, preThreshold %d, resultReturned %t)",
+		s.epsilon, s.delta, s.l0Sensitivity, s.strategy, s.preThreshold, s.resultReturned)
+}
+
+// PartitionSelectionStrategy is the mechanism used by PreAggSelectPartition
+// to decide whether to materialize a partition given its count of privacy IDs.
+type PartitionSelectionStrategy int
+
+const (
+	// OptimalSelection selects partitions with the probability
+	// selectPartitionPr described in the PreAggSelectPartition godoc, which is
+	// the largest possible under (ε,δ)-differential privacy.
+	OptimalSelection PartitionSelectionStrategy = iota
+	// LaplaceThresholding adds Laplace noise to the count of privacy IDs and
+	// selects the partition if the noisy count is above the threshold given by
+	// noise.Laplace().Threshold.
+	LaplaceThresholding
+	// GaussianThresholding adds Gaussian noise to the count of privacy IDs and
+	// selects the partition if the noisy count is above the threshold given by
+	// noise.Gaussian().Threshold. δ is split in half between the noise and the
+	// threshold, like in aggregations with Gaussian noise.
+	GaussianThresholding
+)
+
+func (st PartitionSelectionStrategy) String() string {
+	switch st {
+	case OptimalSelection:
+		return "OptimalSelection"
+	case LaplaceThresholding:
+		return "LaplaceThresholding"
+	case GaussianThresholding:
+		return "GaussianThresholding"
+	default:
+		return fmt.Sprintf("PartitionSelectionStrategy(%d)", int(st))
+	}
 }
 
 // PreAggSelectPartitionOptions is used to set the privacy parameters when
@@ -97,6 +143,57 @@ type PreAggSelectPartitionOptions struct {
 	// contribute to.
 	// Defaults to 1.
 	MaxPartitionsContributed int64
+	// Strategy is the mechanism used to select partitions. Defaults to
+	// OptimalSelection.
+	Strategy PartitionSelectionStrategy
+	// PreThreshold is the minimum number of privacy IDs a partition must have
+	// to be selected, e.g., to honor a regulatory threshold. A partition with
+	// n ≥ PreThreshold privacy IDs is then selected with the probability that
+	// Strategy gives to n-PreThreshold+1 privacy IDs, so the budget is not
+	// affected. Defaults to 0, i.e., no pre-threshold.
+	PreThreshold int64
+}
+
+// Validate returns an error if NewPreAggSelectPartition would fail with these
+// options. It does not build a PreAggSelectPartition.
+func (opt *PreAggSelectPartitionOptions) Validate() error {
+	const label = "dpagg.PreAggSelectPartitionOptions"
+	if opt == nil {
+		opt = &PreAggSelectPartitionOptions{}
+	}
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	if err := checks.CheckDelta(label, opt.Delta); err != nil {
+		return err
+	}
+	if err := checks.CheckEpsilon(label, opt.Epsilon); err != nil {
+		return err
+	}
+	if err := checks.CheckL0Sensitivity(label, l0); err != nil {
+		return err
+	}
+	return checkSelectionStrategy(label, opt.Strategy, opt.PreThreshold, l0, opt.Epsilon, opt.Delta)
+}
+
+// checkSelectionStrategy returns an error if the strategy and the
+// pre-threshold cannot be used with the other parameters of a
+// PreAggSelectPartition, which are assumed to be valid.
+func checkSelectionStrategy(label string, strategy PartitionSelectionStrategy, preThreshold, l0Sensitivity int64, epsilon, delta float64) error {
+	if preThreshold < 0 {
+		return &checks.ParameterError{Label: label, Parameter: "PreThreshold", Value: preThreshold, Constraint: checks.Nonnegative, AllowedRange: "[0, MaxInt64]"}
+	}
+	switch strategy {
+	case OptimalSelection:
+		return nil
+	case LaplaceThresholding:
+		return noise.CheckParameters(label, noise.Laplace(), l0Sensitivity, 1, epsilon, 0)
+	case GaussianThresholding:
+		return noise.CheckParameters(label, noise.Gaussian(), l0Sensitivity, 1, epsilon, delta/2)
+	default:
+		return fmt.Errorf("%s: unknown Strategy %v", label, strategy)
+	}
 }
 
 // NewPreAggSelectPartition constructs a new PreAggSelectPartition from opt.
@@ -105,6 +202,8 @@ func NewPreAggSelectPartition(opt *PreAggSelectPartitionOptions) *PreAggSelectPa
 		epsilon:       opt.Epsilon,
 		delta:         opt.Delta,
 		l0Sensitivity: opt.MaxPartitionsContributed,
+		strategy:      opt.Strategy,
+		preThreshold:  opt.PreThreshold,
 	}
 	// Override the 0-default, but do not override any explicitly set (i.e., negative) values
 	// for l0Sensitivity.
@@ -121,9 +220,30 @@ func NewPreAggSelectPartition(opt *PreAggSelectPartitionOptions) *PreAggSelectPa
 	if err := checks.CheckL0Sensitivity("dpagg.NewPreAggSelectPartition", s.l0Sensitivity); err != nil {
 		log.Fatalf("%s: CheckL0Sensitivity failed with %v", &s, err)
 	}
+	if err := checkSelectionStrategy("dpagg.NewPreAggSelectPartition", s.strategy, s.preThreshold, s.l0Sensitivity, s.epsilon, s.delta); err != nil {
+		log.Fatalf("%s: checkSelectionStrategy failed with %v", &s, err)
+	}
+	s.setThreshold()
 	return &s
 }
 
+// setThreshold sets the threshold and the noise scale of s from its
+// parameters, which are assumed to be valid.
+func (s *PreAggSelectPartition) setThreshold() {
+	switch s.strategy {
+	case LaplaceThresholding:
+		s.threshold = noise.Laplace().Threshold(s.l0Sensitivity, 1, s.epsilon, 0, s.delta)
+		s.noiseScale = float64(s.l0Sensitivity) / s.epsilon
+	case GaussianThresholding:
+		s.threshold = noise.Gaussian().Threshold(s.l0Sensitivity, 1, s.epsilon, s.delta/2, s.delta/2)
+		sigma, err := noise.SigmaForGaussian(s.l0Sensitivity, 1, s.epsilon, s.delta/2)
+		if err != nil {
+			log.Fatalf("%s: SigmaForGaussian failed with %v", s, err)
+		}
+		s.noiseScale = sigma
+	}
+}
+
 // Add increments the count of privacy IDs.
 func (s *PreAggSelectPartition) Add() {
 	if s.resultReturned {
@@ -169,7 +289,46 @@ func (s *PreAggSelectPartition) Result() bool {
 		log.Exitf("This PreAggSelectPartition has already returned a Result. It can only be used once.")
 	}
 	s.resultReturned = true
-	return rand.Uniform() < selectPartitionPr(s.idCount, s.l0Sensitivity, s.epsilon, s.delta)
+	return rand.Uniform() < s.SelectionProbability(s.idCount)
+}
+
+// SelectionProbability returns the probability that Result selects the
+// partition if it has idCount privacy IDs, e.g., to find how many privacy IDs
+// a partition needs to be likely to be materialized. It does not depend on
+// the privacy IDs added to s, and can be called at any time.
+func (s *PreAggSelectPartition) SelectionProbability(idCount int64) float64 {
+	if s.preThreshold > 1 {
+		if idCount < s.preThreshold {
+			return 0
+		}
+		idCount -= s.preThreshold - 1
+	}
+	if idCount <= 0 {
+		return 0
+	}
+	switch s.strategy {
+	case LaplaceThresholding:
+		return laplaceTailPr(float64(idCount)-s.threshold, s.noiseScale)
+	case GaussianThresholding:
+		return gaussianTailPr(float64(idCount)-s.threshold, s.noiseScale)
+	default:
+		return selectPartitionPr(idCount, s.l0Sensitivity, s.epsilon, s.delta)
+	}
+}
+
+// laplaceTailPr returns the probability that Laplace noise of scale λ is
+// larger than -x, i.e., that x plus the noise is positive.
+func laplaceTailPr(x, lambda float64) float64 {
+	if x >= 0 {
+		return 1 - 0.5*math.Exp(-x/lambda)
+	}
+	return 0.5 * math.Exp(x/lambda)
+}
+
+// gaussianTailPr returns the probability that Gaussian noise of standard
+// deviation σ is larger than -x, i.e., that x plus the noise is positive.
+func gaussianTailPr(x, sigma float64) float64 {
+	return 0.5 * math.Erfc(-x/(sigma*math.Sqrt2))
 }
 
 // sumExpPowers returns the evaluation of
@@ -273,6 +432,8 @@ type encodablePreAggSelectPartition struct {
 	Epsilon        float64
 	Delta          float64
 	L0Sensitivity  int64
+	Strategy       PartitionSelectionStrategy
+	PreThreshold   int64
 	IDCount        int64
 	ResultReturned bool
 }
@@ -283,6 +444,8 @@ func (s *PreAggSelectPartition) GobEncode() ([]byte, error) {
 		Epsilon:        s.epsilon,
 		Delta:          s.delta,
 		L0Sensitivity:  s.l0Sensitivity,
+		Strategy:       s.strategy,
+		PreThreshold:   s.preThreshold,
 		IDCount:        s.idCount,
 		ResultReturned: s.resultReturned,
 	}
@@ -298,8 +461,13 @@ func (s *PreAggSelectPartition) GobDecode(data []byte) error {
 		epsilon:        enc.Epsilon,
 		delta:          enc.Delta,
 		l0Sensitivity:  enc.L0Sensitivity,
+		strategy:       enc.Strategy,
+		preThreshold:   enc.PreThreshold,
 		idCount:        enc.IDCount,
 		resultReturned: enc.ResultReturned,
 	}
+	if err == nil {
+		s.setThreshold()
+	}
 	return err
 }
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -38,6 +39,10 @@ func comparePreAggSelectPartitionSelection(s1, s2 *PreAggSelectPartition) bool {
 	return s1.epsilon == s2.epsilon &&
 		s1.delta == s2.delta &&
 		s1.l0Sensitivity == s2.l0Sensitivity &&
+		s1.strategy == s2.strategy &&
+		s1.preThreshold == s2.preThreshold &&
+		s1.threshold == s2.threshold &&
+		s1.noiseScale == s2.noiseScale &&
 		s1.idCount == s2.idCount &&
 		s1.resultReturned == s2.resultReturned
 }
@@ -57,6 +62,12 @@ func TestPreAggSelectPartitionSerialization(t *testing.T) {
 			Delta:                    1e-5,
 			MaxPartitionsContributed: 5,
 		}},
+		{"thresholding strategy with pre-threshold", &PreAggSelectPartitionOptions{
+			Epsilon:      ln3,
+			Delta:        1e-5,
+			Strategy:     GaussianThresholding,
+			PreThreshold: 10,
+		}},
 	} {
 		s, sUnchanged := NewPreAggSelectPartition(tc.opts), NewPreAggSelectPartition(tc.opts)
 		bytes, err := encode(s)
@@ -492,6 +503,18 @@ func TestCheckMergePreAggSelectPartition(t *testing.T) {
 			right:              PreAggSelectPartition{epsilon: 0.1, delta: 0.2, l0Sensitivity: 2, idCount: 2},
 			wantErrorSubstring: "s and s2 are not compatible",
 		},
+		{
+			name:               "Parameter disagreement: strategy",
+			left:               PreAggSelectPartition{epsilon: 0.1, delta: 0.2, l0Sensitivity: 1, strategy: LaplaceThresholding},
+			right:              PreAggSelectPartition{epsilon: 0.1, delta: 0.2, l0Sensitivity: 1},
+			wantErrorSubstring: "s and s2 are not compatible",
+		},
+		{
+			name:               "Parameter disagreement: preThreshold",
+			left:               PreAggSelectPartition{epsilon: 0.1, delta: 0.2, l0Sensitivity: 1, preThreshold: 5},
+			right:              PreAggSelectPartition{epsilon: 0.1, delta: 0.2, l0Sensitivity: 1},
+			wantErrorSubstring: "s and s2 are not compatible",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			err := checkMergePreAggSelectPartition(tc.left, tc.right)
@@ -505,3 +528,80 @@ func TestCheckMergePreAggSelectPartition(t *testing.T) {
 		})
 	}
 }
+
+func TestPreAggSelectPartitionOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *PreAggSelectPartitionOptions
+		wantErr bool
+	}{
+		{"valid options", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5}, false},
+		{"nil options", nil, true},
+		{"zero delta", &PreAggSelectPartitionOptions{Epsilon: ln3}, true},
+		{"delta equal to 1", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1}, true},
+		{"negative epsilon", &PreAggSelectPartitionOptions{Epsilon: -1, Delta: 1e-5}, true},
+		{"negative MaxPartitionsContributed", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5, MaxPartitionsContributed: -1}, true},
+		{"valid thresholding", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5, Strategy: LaplaceThresholding, PreThreshold: 10}, false},
+		{"negative PreThreshold", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5, PreThreshold: -1}, true},
+		{"unknown Strategy", &PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5, Strategy: 42}, true},
+		{"Laplace thresholding with zero epsilon", &PreAggSelectPartitionOptions{Delta: 1e-5, Strategy: LaplaceThresholding}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSelectionProbability(t *testing.T) {
+	newSelection := func(strategy PartitionSelectionStrategy, preThreshold int64) *PreAggSelectPartition {
+		return NewPreAggSelectPartition(&PreAggSelectPartitionOptions{
+			Epsilon:                  ln3,
+			Delta:                    1e-5,
+			MaxPartitionsContributed: 2,
+			Strategy:                 strategy,
+			PreThreshold:             preThreshold,
+		})
+	}
+	laplaceThreshold := noise.Laplace().Threshold(2, 1, ln3, 0, 1e-5)
+	gaussianThreshold := noise.Gaussian().Threshold(2, 1, ln3, 5e-6, 5e-6)
+	gaussianSigma, err := noise.SigmaForGaussian(2, 1, ln3, 5e-6)
+	if err != nil {
+		t.Fatalf("SigmaForGaussian: got error %v", err)
+	}
+	for _, tc := range []struct {
+		desc     string
+		s        *PreAggSelectPartition
+		idCount  int64
+		wantProb float64
+	}{
+		{"optimal selection", newSelection(OptimalSelection, 0), 3, selectPartitionPr(3, 2, ln3, 1e-5)},
+		{"optimal selection with no privacy IDs", newSelection(OptimalSelection, 0), 0, 0},
+		{"below the pre-threshold", newSelection(OptimalSelection, 10), 9, 0},
+		{"at the pre-threshold", newSelection(OptimalSelection, 10), 10, selectPartitionPr(1, 2, ln3, 1e-5)},
+		{"above the pre-threshold", newSelection(OptimalSelection, 10), 15, selectPartitionPr(6, 2, ln3, 1e-5)},
+		{"Laplace thresholding near the threshold", newSelection(LaplaceThresholding, 0), int64(laplaceThreshold), laplaceTailPr(float64(int64(laplaceThreshold))-laplaceThreshold, 2/ln3)},
+		{"Laplace thresholding far above the threshold", newSelection(LaplaceThresholding, 0), 1000, 1},
+		{"Laplace thresholding with one privacy ID", newSelection(LaplaceThresholding, 0), 1, 1e-5 / 2},
+		{"Gaussian thresholding near the threshold", newSelection(GaussianThresholding, 0), int64(gaussianThreshold), gaussianTailPr(float64(int64(gaussianThreshold))-gaussianThreshold, gaussianSigma)},
+		{"Gaussian thresholding far above the threshold", newSelection(GaussianThresholding, 0), 1000, 1},
+		{"Gaussian thresholding with no privacy IDs", newSelection(GaussianThresholding, 0), 0, 0},
+	} {
+		if got := tc.s.SelectionProbability(tc.idCount); !ApproxEqual(got, tc.wantProb) {
+			t.Errorf("SelectionProbability: for %s got %e, want %e", tc.desc, got, tc.wantProb)
+		}
+	}
+}
+
+func TestSelectionProbabilityIsIncreasing(t *testing.T) {
+	for _, strategy := range []PartitionSelectionStrategy{OptimalSelection, LaplaceThresholding, GaussianThresholding} {
+		s := NewPreAggSelectPartition(&PreAggSelectPartitionOptions{Epsilon: ln3, Delta: 1e-5, Strategy: strategy})
+		prev := 0.0
+		for n := int64(0); n < 50; n++ {
+			got := s.SelectionProbability(n)
+			if got < prev || got > 1 {
+				t.Errorf("SelectionProbability(%d) with %v: got %f after %f, want a nondecreasing probability", n, strategy, got, prev)
+			}
+			prev = got
+		}
+	}
+}
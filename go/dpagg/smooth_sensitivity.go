@@ -0,0 +1,370 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/rand"
+)
+
+// maxSmoothSensitivityDegreesOfFreedom is the largest number of degrees of
+// freedom of the noise of a SmoothSensitivityQuantile. Sampling the noise takes
+// time linear in the degrees of freedom; beyond this, the Student's
+// t-distribution is close to a normal distribution anyway.
+const maxSmoothSensitivityDegreesOfFreedom = 100
+
+// SmoothSensitivityQuantile calculates a differentially private quantile
+// (e.g., the median) of a collection of float64 values with the
+// smooth-sensitivity framework of Nissim et al., "Smooth Sensitivity and
+// Sampling in Private Data Analysis".
+//
+// Instead of scaling the noise with the largest possible change of the
+// quantile, which is Upper - Lower, the noise is scaled with a β-smooth upper
+// bound on the local sensitivity of the quantile for the actual data, which is
+// the spread of the values around the quantile. The influence of Lower and
+// Upper on the noise decreases exponentially with the number of values, so they
+// can be very loose (e.g., several orders of magnitude larger than the
+// values), which makes SmoothSensitivityQuantile suited to heavy-tailed data
+// for which no tight bounds exist.
+//
+// The noise follows a Student's t-distribution with DegreesOfFreedom degrees of
+// freedom; a single degree of freedom gives Cauchy noise. More degrees of
+// freedom give lighter tails, at the cost of a smaller smoothing parameter β.
+// The result is ε-differentially private, with δ = 0.
+//
+// Values are stored until the result is computed, so the memory used by a
+// SmoothSensitivityQuantile is proportional to the number of values.
+//
+// SmoothSensitivityQuantile supports privacy units contributing to multiple
+// partitions (via the MaxPartitionsContributed parameter), in which case ε is
+// split evenly between the partitions, and multiple times to a single partition
+// (via the MaxContributionsPerPartition parameter). The aggregation itself
+// doesn't ensure these limits are respected.
+//
+// Not thread-safe.
+type SmoothSensitivityQuantile struct {
+	// Parameters
+	epsilon          float64 // ε of a single partition
+	lInfSensitivity  int64
+	lower            float64
+	upper            float64
+	quantile         float64
+	degreesOfFreedom int64
+
+	// State variables
+	values         []float64
+	resultReturned bool // whether the result has already been returned
+}
+
+func ssqEquallyInitialized(q1, q2 *SmoothSensitivityQuantile) bool {
+	return q1.epsilon == q2.epsilon &&
+		q1.lInfSensitivity == q2.lInfSensitivity &&
+		q1.lower == q2.lower &&
+		q1.upper == q2.upper &&
+		q1.quantile == q2.quantile &&
+		q1.degreesOfFreedom == q2.degreesOfFreedom
+}
+
+// SmoothSensitivityQuantileOptions contains the options necessary to
+// initialize a SmoothSensitivityQuantile.
+type SmoothSensitivityQuantileOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	MaxPartitionsContributed int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	// How many times may a single user contribute to a single partition? Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Lower and Upper bounds for clamping. Must be such that Lower < Upper, but
+	// need not be tight.
+	Lower, Upper float64
+	// Quantile to release, in [0, 1]; e.g., 0.5 for the median. Required.
+	Quantile float64
+	// Degrees of freedom of the Student's t-distribution of the noise, at most
+	// maxSmoothSensitivityDegreesOfFreedom. Defaults to 1, i.e., Cauchy noise.
+	DegreesOfFreedom int64
+}
+
+// NewSmoothSensitivityQuantile returns a new SmoothSensitivityQuantile.
+func NewSmoothSensitivityQuantile(opt *SmoothSensitivityQuantileOptions) *SmoothSensitivityQuantile {
+	if opt == nil {
+		opt = &SmoothSensitivityQuantileOptions{}
+	}
+	if err := checks.CheckBoundsFloat64("NewSmoothSensitivityQuantile", opt.Lower, opt.Upper); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", opt.Lower, opt.Upper, err)
+	}
+	if opt.Lower == opt.Upper {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile requires Lower < Upper")
+	}
+	if math.IsNaN(opt.Quantile) || opt.Quantile < 0 || opt.Quantile > 1 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: Quantile is %f, should be in [0, 1]", opt.Quantile)
+	}
+
+	// Set defaults.
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	lInf := opt.MaxContributionsPerPartition
+	if lInf == 0 {
+		lInf = 1
+	}
+	dof := opt.DegreesOfFreedom
+	if dof == 0 {
+		dof = 1
+	}
+	if err := checks.CheckL0Sensitivity("NewSmoothSensitivityQuantile", l0); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: %v", err)
+	}
+	if err := checks.CheckLInfSensitivity("NewSmoothSensitivityQuantile", float64(lInf)); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: %v", err)
+	}
+	if dof < 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: DegreesOfFreedom is %d, should be strictly positive", dof)
+	}
+	if dof > maxSmoothSensitivityDegreesOfFreedom {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: DegreesOfFreedom is %d, should be at most %d", dof, maxSmoothSensitivityDegreesOfFreedom)
+	}
+	// A user contributing to l0 partitions consumes ε/l0 in each of them.
+	eps := opt.Epsilon / float64(l0)
+	if err := checks.CheckEpsilonStrict("NewSmoothSensitivityQuantile", eps); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewSmoothSensitivityQuantile: %v", err)
+	}
+
+	return &SmoothSensitivityQuantile{
+		epsilon:          eps,
+		lInfSensitivity:  lInf,
+		lower:            opt.Lower,
+		upper:            opt.Upper,
+		quantile:         opt.Quantile,
+		degreesOfFreedom: dof,
+		resultReturned:   false,
+	}
+}
+
+// Add adds an entry to a SmoothSensitivityQuantile. It skips NaN entries and
+// doesn't count them in the final result because introducing even a single
+// NaN entry will result in a NaN quantile regardless of other entries.
+func (q *SmoothSensitivityQuantile) Add(e float64) {
+	if q.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The quantile has already been calculated and returned. It cannot be amended.")
+	}
+	if math.IsNaN(e) {
+		return
+	}
+	clamped, err := ClampFloat64(e, q.lower, q.upper)
+	if err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("Couldn't clamp input value %v, err %v", e, err)
+	}
+	q.values = append(q.values, clamped)
+}
+
+// Merge merges q2 into q (i.e., adds to q all entries that were added to q2).
+// q2 is consumed by this operation: q2 may not be used after it is merged
+// into q.
+func (q *SmoothSensitivityQuantile) Merge(q2 *SmoothSensitivityQuantile) {
+	if err := checkMergeSmoothSensitivityQuantile(q, q2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	q.values = append(q.values, q2.values...)
+	q2.resultReturned = true
+}
+
+func checkMergeSmoothSensitivityQuantile(q1, q2 *SmoothSensitivityQuantile) error {
+	if q1.resultReturned {
+		return fmt.Errorf("checkMergeSmoothSensitivityQuantile: q1 already returned the result, cannot be merged with another SmoothSensitivityQuantile instance")
+	}
+	if q2.resultReturned {
+		return fmt.Errorf("checkMergeSmoothSensitivityQuantile: q2 already returned the result, cannot be merged with another SmoothSensitivityQuantile instance")
+	}
+	if !ssqEquallyInitialized(q1, q2) {
+		return fmt.Errorf("checkMergeSmoothSensitivityQuantile: q1 and q2 are not compatible")
+	}
+	return nil
+}
+
+// Result returns a differentially private estimate of the quantile of the
+// values added so far, clamped to [Lower, Upper]. It can be called only once,
+// after which no further operation can be done on the
+// SmoothSensitivityQuantile.
+func (q *SmoothSensitivityQuantile) Result() float64 {
+	if q.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The quantile has already been calculated and returned. It can only be returned once.")
+	}
+	q.resultReturned = true
+	sort.Float64s(q.values)
+	alpha, beta := smoothNoiseParameters(q.epsilon, q.degreesOfFreedom)
+	rank := quantileRank(len(q.values), q.quantile)
+	s := q.smoothSensitivity(rank, beta)
+	noisy := q.orderStatistic(rank) + s/alpha*studentT(q.degreesOfFreedom)
+	return math.Min(math.Max(noisy, q.lower), q.upper)
+}
+
+// quantileRank returns the (0-based) rank of the given quantile among n sorted
+// values, or -1 if n is 0. The rank changes by at most 1 when a value is added
+// or removed.
+func quantileRank(n int, quantile float64) int {
+	if n == 0 {
+		return -1
+	}
+	rank := int(math.Ceil(quantile*float64(n))) - 1
+	if rank < 0 {
+		return 0
+	}
+	if rank >= n {
+		return n - 1
+	}
+	return rank
+}
+
+// orderStatistic returns the sorted value of the given rank, where the values
+// are padded with Lower at negative ranks and with Upper at ranks beyond the
+// last value.
+func (q *SmoothSensitivityQuantile) orderStatistic(rank int) float64 {
+	if rank < 0 {
+		return q.lower
+	}
+	if rank >= len(q.values) {
+		return q.upper
+	}
+	return q.values[rank]
+}
+
+// smoothSensitivity returns a β-smooth upper bound on the local sensitivity of
+// the order statistic of the given rank, namely
+//
+//	S(x) = max_{k≥0} e^(-kβ) · (x[rank+r(k)] - x[rank-r(k)]), with r(k) = 2·lInf·(k+1),
+//
+// where x is padded as in orderStatistic.
+//
+// Adding or removing a value changes the rank by at most 1 and shifts every
+// value by at most one position, so the order statistic of a neighbouring
+// dataset lies in [x[rank-2], x[rank+2]], and a window of radius r around the
+// rank of a neighbouring dataset lies within the window of radius r+2 around
+// the rank of x. Hence S bounds the local sensitivity, and S(y) ≤ e^β S(x)
+// for all neighbouring datasets x and y. With lInf contributions per user, the
+// radius grows by 2·lInf per user instead of 2.
+func (q *SmoothSensitivityQuantile) smoothSensitivity(rank int, beta float64) float64 {
+	step := 2 * int(q.lInfSensitivity)
+	maxWidth := q.upper - q.lower
+	var s float64
+	for k := 0; ; k++ {
+		decay := math.Exp(-float64(k) * beta)
+		if decay*maxWidth <= s {
+			// No subsequent term can be larger than s.
+			return s
+		}
+		r := step * (k + 1)
+		s = math.Max(s, decay*(q.orderStatistic(rank+r)-q.orderStatistic(rank-r)))
+		if rank-r < 0 && rank+r >= len(q.values) {
+			// All subsequent windows span [Lower, Upper], with a smaller factor.
+			return s
+		}
+	}
+}
+
+// smoothNoiseParameters returns the scaling parameter α and the smoothing
+// parameter β such that releasing f(x) + S(x)/α · T, where S is a β-smooth
+// upper bound on the local sensitivity of f and T follows a Student's
+// t-distribution with the given degrees of freedom d, is ε-differentially
+// private.
+//
+// The log-density of T has a derivative of magnitude at most (d+1)/(2√d), so
+// shifting T by at most α costs at most α(d+1)/(2√d); scaling T by a factor
+// e^λ costs at most d|λ|. The budget is split evenly between the two.
+func smoothNoiseParameters(epsilon float64, degreesOfFreedom int64) (alpha, beta float64) {
+	d := float64(degreesOfFreedom)
+	alpha = epsilon / 2 / ((d + 1) / (2 * math.Sqrt(d)))
+	beta = epsilon / 2 / d
+	return alpha, beta
+}
+
+// studentT returns a sample of the Student's t-distribution with the given
+// degrees of freedom, as the ratio of a standard normal sample and of the
+// square root of an independent chi-squared sample divided by its degrees of
+// freedom.
+func studentT(degreesOfFreedom int64) float64 {
+	var chiSquared float64
+	for i := int64(0); i < degreesOfFreedom; i++ {
+		z := rand.Normal()
+		chiSquared += z * z
+	}
+	return rand.Normal() / math.Sqrt(chiSquared/float64(degreesOfFreedom))
+}
+
+// encodableSmoothSensitivityQuantile can be encoded by the gob package.
+type encodableSmoothSensitivityQuantile struct {
+	Epsilon          float64
+	LInfSensitivity  int64
+	Lower            float64
+	Upper            float64
+	Quantile         float64
+	DegreesOfFreedom int64
+	Values           []float64
+	ResultReturned   bool
+}
+
+// GobEncode encodes SmoothSensitivityQuantile.
+func (q *SmoothSensitivityQuantile) GobEncode() ([]byte, error) {
+	enc := encodableSmoothSensitivityQuantile{
+		Epsilon:          q.epsilon,
+		LInfSensitivity:  q.lInfSensitivity,
+		Lower:            q.lower,
+		Upper:            q.upper,
+		Quantile:         q.quantile,
+		DegreesOfFreedom: q.degreesOfFreedom,
+		Values:           q.values,
+		ResultReturned:   q.resultReturned,
+	}
+	q.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes SmoothSensitivityQuantile.
+func (q *SmoothSensitivityQuantile) GobDecode(data []byte) error {
+	var enc encodableSmoothSensitivityQuantile
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode SmoothSensitivityQuantile from bytes")
+		return err
+	}
+	*q = SmoothSensitivityQuantile{
+		epsilon:          enc.Epsilon,
+		lInfSensitivity:  enc.LInfSensitivity,
+		lower:            enc.Lower,
+		upper:            enc.Upper,
+		quantile:         enc.Quantile,
+		degreesOfFreedom: enc.DegreesOfFreedom,
+		values:           enc.Values,
+		resultReturned:   enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewSmoothSensitivityQuantile(t *testing.T) {
+	q := NewSmoothSensitivityQuantile(&SmoothSensitivityQuantileOptions{
+		Epsilon:                  ln3,
+		MaxPartitionsContributed: 2,
+		Lower:                    -1e9,
+		Upper:                    1e9,
+		Quantile:                 0.5,
+	})
+	want := &SmoothSensitivityQuantile{
+		epsilon:          ln3 / 2,
+		lInfSensitivity:  1,
+		lower:            -1e9,
+		upper:            1e9,
+		quantile:         0.5,
+		degreesOfFreedom: 1,
+	}
+	if !cmp.Equal(q, want, cmp.AllowUnexported(SmoothSensitivityQuantile{})) {
+		t.Errorf("NewSmoothSensitivityQuantile: got %+v, want %+v", q, want)
+	}
+}
+
+func TestQuantileRank(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		quantile float64
+		want     int
+	}{
+		{0, 0.5, -1},
+		{1, 0.5, 0},
+		{10, 0, 0},
+		{10, 0.5, 4},
+		{11, 0.5, 5},
+		{10, 1, 9},
+	} {
+		if got := quantileRank(tc.n, tc.quantile); got != tc.want {
+			t.Errorf("quantileRank(%d, %f): got %d, want %d", tc.n, tc.quantile, got, tc.want)
+		}
+	}
+}
+
+func TestSmoothSensitivity(t *testing.T) {
+	newQuantile := func(values []float64) *SmoothSensitivityQuantile {
+		q := NewSmoothSensitivityQuantile(&SmoothSensitivityQuantileOptions{
+			Epsilon:  ln3,
+			Lower:    0,
+			Upper:    100,
+			Quantile: 0.5,
+		})
+		for _, v := range values {
+			q.Add(v)
+		}
+		return q
+	}
+	for _, tc := range []struct {
+		desc   string
+		values []float64
+		beta   float64
+		want   float64
+	}{
+		{"no values", nil, 1, 100},
+		// The window of radius 2 spans the whole padded domain.
+		{"few values", []float64{1, 2, 3}, 1, 100},
+		// The window of radius 2 around the median 5 is [3, 7]; the window of
+		// radius 4 is [1, 9], and the window of radius 6 is [0, 100].
+		{"values around the median", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}, 2, math.Max(4, 100*math.Exp(-4))},
+		{"strong smoothing", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}, 0.1, 100 * math.Exp(-0.2)},
+	} {
+		q := newQuantile(tc.values)
+		rank := quantileRank(len(q.values), 0.5)
+		if got := q.smoothSensitivity(rank, tc.beta); !ApproxEqual(got, tc.want) {
+			t.Errorf("smoothSensitivity: for %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestSmoothNoiseParameters(t *testing.T) {
+	alpha, beta := smoothNoiseParameters(ln3, 1)
+	if !ApproxEqual(alpha, ln3/2) || !ApproxEqual(beta, ln3/2) {
+		t.Errorf("smoothNoiseParameters(ln3, 1): got (%f, %f), want (%f, %f)", alpha, beta, ln3/2, ln3/2)
+	}
+	alpha, beta = smoothNoiseParameters(ln3, 4)
+	if want := ln3 / 2 / 1.25; !ApproxEqual(alpha, want) || !ApproxEqual(beta, ln3/8) {
+		t.Errorf("smoothNoiseParameters(ln3, 4): got (%f, %f), want (%f, %f)", alpha, beta, want, ln3/8)
+	}
+}
+
+func TestStudentT(t *testing.T) {
+	const numSamples = 20000
+	for _, tc := range []struct {
+		degreesOfFreedom int64
+		// Probability that a sample is in [-1, 1].
+		want float64
+	}{
+		{1, 0.5},
+		{3, 0.6090},
+	} {
+		var inRange int
+		for i := 0; i < numSamples; i++ {
+			if math.Abs(studentT(tc.degreesOfFreedom)) <= 1 {
+				inRange++
+			}
+		}
+		// The standard deviation of the proportion is at most 0.0036.
+		if got := float64(inRange) / numSamples; math.Abs(got-tc.want) > 0.02 {
+			t.Errorf("studentT(%d): got %f of the samples in [-1, 1], want %f", tc.degreesOfFreedom, got, tc.want)
+		}
+	}
+}
+
+func TestSmoothSensitivityQuantileResult(t *testing.T) {
+	// With loose bounds and many values concentrated around the median, the
+	// noise is small compared to the bounds.
+	q := NewSmoothSensitivityQuantile(&SmoothSensitivityQuantileOptions{
+		Epsilon:  1,
+		Lower:    -1e6,
+		Upper:    1e6,
+		Quantile: 0.5,
+	})
+	for i := 0; i <= 10000; i++ {
+		q.Add(float64(i) / 100)
+	}
+	// The smooth sensitivity is about 0.05 and the noise scale about 0.1, while
+	// noise scaled with the bounds would be of the order of 10⁶. Cauchy noise
+	// exceeds 10⁴ times its scale with probability about 0.00006.
+	if got := q.Result(); math.Abs(got-50) > 1000 {
+		t.Errorf("Result: got %f, want approximately 50", got)
+	}
+}
+
+func TestSmoothSensitivityQuantileMerge(t *testing.T) {
+	opt := &SmoothSensitivityQuantileOptions{Epsilon: ln3, Lower: 0, Upper: 10, Quantile: 0.5}
+	q1, q2 := NewSmoothSensitivityQuantile(opt), NewSmoothSensitivityQuantile(opt)
+	q1.Add(1)
+	q2.Add(2)
+	q2.Add(30)
+	q1.Merge(q2)
+	if want := []float64{1, 2, 10}; !cmp.Equal(q1.values, want) {
+		t.Errorf("Merge: got values %v, want %v", q1.values, want)
+	}
+	if !q2.resultReturned {
+		t.Errorf("Merge: q2 should be marked as consumed")
+	}
+}
+
+func TestSmoothSensitivityQuantileCheckMerge(t *testing.T) {
+	opt := func(quantile float64) *SmoothSensitivityQuantileOptions {
+		return &SmoothSensitivityQuantileOptions{Epsilon: ln3, Lower: 0, Upper: 10, Quantile: quantile}
+	}
+	for _, tc := range []struct {
+		desc    string
+		opt1    *SmoothSensitivityQuantileOptions
+		opt2    *SmoothSensitivityQuantileOptions
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", opt(0.5), opt(0.5), false, false, false},
+		{"q1 returned its result", opt(0.5), opt(0.5), true, false, true},
+		{"q2 returned its result", opt(0.5), opt(0.5), false, true, true},
+		{"different quantiles", opt(0.5), opt(0.9), false, false, true},
+	} {
+		q1, q2 := NewSmoothSensitivityQuantile(tc.opt1), NewSmoothSensitivityQuantile(tc.opt2)
+		q1.resultReturned = tc.state1
+		q2.resultReturned = tc.state2
+		if err := checkMergeSmoothSensitivityQuantile(q1, q2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSmoothSensitivityQuantileSerialization(t *testing.T) {
+	opt := &SmoothSensitivityQuantileOptions{
+		Epsilon:                      ln3,
+		MaxContributionsPerPartition: 2,
+		Lower:                        0,
+		Upper:                        10,
+		Quantile:                     0.25,
+		DegreesOfFreedom:             3,
+	}
+	q, qUnchanged := NewSmoothSensitivityQuantile(opt), NewSmoothSensitivityQuantile(opt)
+	for _, v := range []float64{1, 5, 3} {
+		q.Add(v)
+		qUnchanged.Add(v)
+	}
+	bytes, err := encode(q)
+	if err != nil {
+		t.Fatalf("encode(SmoothSensitivityQuantile) error: %v", err)
+	}
+	qUnmarshalled := new(SmoothSensitivityQuantile)
+	if err := decode(qUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(SmoothSensitivityQuantile) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(qUnchanged, qUnmarshalled, cmp.AllowUnexported(SmoothSensitivityQuantile{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", qUnmarshalled, qUnchanged)
+	}
+	// Check that the original SmoothSensitivityQuantile has its resultReturned set to true after serialization.
+	if !q.resultReturned {
+		t.Errorf("SmoothSensitivityQuantile %v should have its resultReturned set to true after being serialized", q)
+	}
+}
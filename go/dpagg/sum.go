@@ -28,9 +28,11 @@ import (
 
 // BoundedSumInt64 calculates a differentially private sum of a collection of
 // int64 values. It supports scaling the noise in the case where users can
-// contribute to multiple partitions (via the MaxPartitionsContributed parameter), but it
-// assumes that in each BoundedSum instance (partition), each user contributes
-// at most one value.
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times (via the
+// MaxContributionsPerPartition parameter). These limits are not enforced by
+// BoundedSumInt64: the caller is responsible for bounding the contributions of
+// each user, e.g. with BoundContributions.
 //
 // Note: Do not use when your results may cause overflows for int64
 // values. This aggregation is not hardened for such applications yet.
@@ -46,6 +48,9 @@ type BoundedSumInt64 struct {
 	upper           int64
 	noise           noise.Noise
 	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+	// Neighbouring relation used to compute lInfSensitivity.
+	neighbouringRelation NeighbouringRelation
+	rounding             Rounding
 
 	// State variables
 	sum            int64
@@ -59,7 +64,9 @@ func bsEquallyInitializedint64(s1, s2 *BoundedSumInt64) bool {
 		s1.lInfSensitivity == s2.lInfSensitivity &&
 		s1.lower == s2.lower &&
 		s1.upper == s2.upper &&
-		s1.noiseKind == s2.noiseKind
+		s1.noiseKind == s2.noiseKind &&
+		s1.neighbouringRelation == s2.neighbouringRelation &&
+		s1.rounding == s2.rounding
 }
 
 // BoundedSumInt64Options contains the options necessary to initialize a BoundedSumInt64.
@@ -71,9 +78,50 @@ type BoundedSumInt64Options struct {
 	Lower, Upper						 int64
 	Noise                    noise.Noise // Type of noise used in BoundedSum. Defaults to Laplace noise.
 	// How many times may a single user contribute to a single partition?
-	// Defaults to 1. This is only needed for other aggregation functions using BoundedSum;
-	// which is why the option is not exported.
-	maxContributionsPerPartition int64
+	// Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Definition of neighbouring datasets used to compute the sensitivity.
+	// Defaults to AddOrRemoveOne.
+	NeighbouringRelation NeighbouringRelation
+	// Rounding applied to the result. Defaults to no rounding.
+	Rounding Rounding
+}
+
+// Validate returns an error if NewBoundedSumInt64 would fail with these
+// options. It does not build a BoundedSumInt64, and does not consume
+// randomness. Only the parameters of noise.Laplace() and noise.Gaussian() are
+// checked: with another Noise, NewBoundedSumInt64 may still fail.
+func (opt *BoundedSumInt64Options) Validate() error {
+	const label = "dpagg.BoundedSumInt64Options"
+	if opt == nil {
+		opt = &BoundedSumInt64Options{}
+	}
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		maxContributionsPerPartition = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	if opt.Lower == 0 && opt.Upper == 0 {
+		return errNoBounds(label)
+	}
+	if err := checks.CheckBoundsInt64(label, opt.Lower, opt.Upper); err != nil {
+		return err
+	}
+	lInf, err := getLInfIntForRelation(opt.Lower, opt.Upper, maxContributionsPerPartition, opt.NeighbouringRelation)
+	if err != nil {
+		return err
+	}
+	if err := checkRounding(label, opt.Rounding); err != nil {
+		return err
+	}
+	return noise.CheckParameters(label, n, l0, float64(lInf), opt.Epsilon, opt.Delta)
 }
 
 // NewBoundedSumInt64 returns a new BoundedSumInt64, whose sum is initialized at 0.
@@ -87,7 +135,7 @@ func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
 		l0 = 1
 	}
 
-	maxContributionsPerPartition := opt.maxContributionsPerPartition
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
 	if maxContributionsPerPartition == 0 {
 		maxContributionsPerPartition = 1
 	}
@@ -106,10 +154,14 @@ func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("CheckBoundsInt64(lower %d, upper %d) failed with %v", lower, upper, err)
 	}
-	lInf, err := getLInfInt(lower, upper, maxContributionsPerPartition)
+	lInf, err := getLInfIntForRelation(lower, upper, maxContributionsPerPartition, opt.NeighbouringRelation)
 	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("getLInfInt(lower %d, upper %d, maxContributionsPerPartition %d) failed with %v", lower, upper, maxContributionsPerPartition, err)
+		log.Fatalf("getLInfIntForRelation(lower %d, upper %d, maxContributionsPerPartition %d, neighbouringRelation %v) failed with %v", lower, upper, maxContributionsPerPartition, opt.NeighbouringRelation, err)
+	}
+	if err := checkRounding("NewBoundedSumInt64", opt.Rounding); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("checkRounding(%+v) failed with %v", opt.Rounding, err)
 	}
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
@@ -117,16 +169,18 @@ func NewBoundedSumInt64(opt *BoundedSumInt64Options) *BoundedSumInt64 {
 	n.AddNoiseInt64(0, l0, lInf, eps, del)
 
 	return &BoundedSumInt64{
-		epsilon:         eps,
-		delta:           del,
-		l0Sensitivity:   l0,
-		lInfSensitivity: lInf,
-		lower:           lower,
-		upper:           upper,
-		noise:           n,
-		noiseKind:       noise.ToKind(n),
-		sum:             0,
-		resultReturned:  false,
+		epsilon:              eps,
+		delta:                del,
+		l0Sensitivity:        l0,
+		lInfSensitivity:      lInf,
+		lower:                lower,
+		upper:                upper,
+		noise:                n,
+		noiseKind:            noise.ToKind(n),
+		neighbouringRelation: opt.NeighbouringRelation,
+		rounding:             opt.Rounding,
+		sum:                  0,
+		resultReturned:       false,
 	}
 }
 
@@ -144,7 +198,7 @@ func lInfIntOverflows(bound, maxContributionsPerPartition int64) bool {
 func getLInfInt(lower, upper, maxContributionsPerPartition int64) (int64, error) {
 	// If lower or upper is math.MinInt64, the sensitivity will overflow.
 	if lower == math.MinInt64 || upper == math.MinInt64 {
-		return 0, fmt.Errorf("lower = %d and upper = %d must be strictly larger than math.MinInt64 to avoid sensitivity overflow", lower, upper)
+		return 0, checks.CheckBoundsInt64("dpagg.BoundedSumInt64", lower, upper)
 	}
 	if lower < 0 {
 		lower = -lower
@@ -153,14 +207,10 @@ func getLInfInt(lower, upper, maxContributionsPerPartition int64) (int64, error)
 		upper = -upper
 	}
 	if lInfIntOverflows(lower, maxContributionsPerPartition) {
-		return 0, fmt.Errorf(
-			"lower = %d and maxContributionsPerPartition = %d are too high - the lInf sensitivity may overflow",
-			lower, maxContributionsPerPartition)
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumInt64", maxContributionsPerPartition, fmt.Sprint(math.MaxInt64/lower))
 	}
 	if lInfIntOverflows(upper, maxContributionsPerPartition) {
-		return 0, fmt.Errorf(
-			"upper = %dr and maxContributionsPerPartition = %d are too high - the lInf sensitivity may overflow",
-			upper, maxContributionsPerPartition)
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumInt64", maxContributionsPerPartition, fmt.Sprint(math.MaxInt64/upper))
 	}
 	if lower > upper {
 		return lower * maxContributionsPerPartition, nil
@@ -168,6 +218,46 @@ func getLInfInt(lower, upper, maxContributionsPerPartition int64) (int64, error)
 	return upper * maxContributionsPerPartition, nil
 }
 
+// getLInfIntForRelation returns the L_inf sensitivity of the BoundedSum object
+// under the given neighbouring relation.
+func getLInfIntForRelation(lower, upper, maxContributionsPerPartition int64, relation NeighbouringRelation) (int64, error) {
+	switch relation {
+	case AddOrRemoveOne:
+		return getLInfInt(lower, upper, maxContributionsPerPartition)
+	case ReplaceOne:
+		return getReplaceOneLInfInt(lower, upper, maxContributionsPerPartition)
+	default:
+		return 0, fmt.Errorf("dpagg.BoundedSumInt64: unknown NeighbouringRelation %v", relation)
+	}
+}
+
+// getReplaceOneLInfInt checks that the sensitivity parameters will not create
+// overflow errors, and returns the L_inf sensitivity of the BoundedSum object
+// under the ReplaceOne relation, which is calculated by the formula
+// = (upper - lower) * maxContributionsPerPartition. It requires lower < upper.
+func getReplaceOneLInfInt(lower, upper, maxContributionsPerPartition int64) (int64, error) {
+	width := upper - lower
+	if width < 0 {
+		return 0, widthOverflowError("dpagg.BoundedSumInt64", lower, upper)
+	}
+	if lInfIntOverflows(width, maxContributionsPerPartition) {
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumInt64", maxContributionsPerPartition, fmt.Sprint(math.MaxInt64/width))
+	}
+	return width * maxContributionsPerPartition, nil
+}
+
+// widthOverflowError returns the error for int64 bounds such that
+// upper - lower overflows.
+func widthOverflowError(label string, lower, upper int64) error {
+	return &checks.ParameterError{
+		Label:        label,
+		Parameter:    "Upper",
+		Value:        upper,
+		Constraint:   checks.InRange,
+		AllowedRange: fmt.Sprintf("[%d, %d] (Upper - Lower would overflow)", lower, int64(math.MaxInt64)+lower),
+	}
+}
+
 // Add adds a new summand to the BoundedSumInt64.
 func (bs *BoundedSumInt64) Add(e int64) {
 	if bs.resultReturned {
@@ -182,6 +272,29 @@ func (bs *BoundedSumInt64) Add(e int64) {
 	bs.sum += clamped
 }
 
+// AddBatch adds new summands to the BoundedSumInt64. It is equivalent to
+// calling Add on each of them, but only checks once that the result hasn't
+// been returned, and clamps the summands without an error path: the bounds
+// were checked when the BoundedSumInt64 was created.
+func (bs *BoundedSumInt64) AddBatch(es []int64) {
+	if bs.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The sum has already been calculated and returned. It cannot be amended.")
+	}
+	lower, upper := bs.lower, bs.upper
+	sum := bs.sum
+	for _, e := range es {
+		if e < lower {
+			e = lower
+		}
+		if e > upper {
+			e = upper
+		}
+		sum += e
+	}
+	bs.sum = sum
+}
+
 // Merge merges bs2 into bs (i.e., adds to bs all entries that were added to
 // bs2). bs2 is consumed by this operation: bs2 may not be used after it is
 // merged into bs.
@@ -211,6 +324,12 @@ func checkMergeBoundedSumInt64(bs1, bs2 *BoundedSumInt64) error {
 // elements added so far. It can be called only once, after which no further
 // operation can be done on the BoundedSumInt64.
 func (bs *BoundedSumInt64) Result() int64 {
+	return bs.rounding.RoundInt64(bs.noisedResult())
+}
+
+// noisedResult returns the noised sum, before rounding. Like Result, it can be
+// called only once.
+func (bs *BoundedSumInt64) noisedResult() int64 {
 	if bs.resultReturned {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("The sum has already been calculated and returned. It can only be returned once.")
@@ -221,43 +340,77 @@ func (bs *BoundedSumInt64) Result() int64 {
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise
-// mechanism, it returns nil. Otherwise, it returns the result.
+// mechanism, it returns nil. Otherwise, it returns the result. Thresholding is
+// applied before rounding.
 func (bs *BoundedSumInt64) ThresholdedResult(deltaThreshold float64) *int64 {
 	threshold := bs.noise.Threshold(bs.l0Sensitivity, float64(bs.lInfSensitivity), bs.epsilon, bs.delta, deltaThreshold)
-	result := bs.Result()
+	result := bs.noisedResult()
 	// To make sure floating-point rounding doesn't break DP guarantees, we err on
 	// the side of dropping the result if it is exactly equal to the threshold.
 	if float64(result) <= threshold {
 		return nil
 	}
+	result = bs.rounding.RoundInt64(result)
 	return &result
 }
 
+// ResultWithConfidenceInterval is similar to Result() but additionally returns
+// a confidence interval that contains the true (unnoised) clamped sum with a
+// probability of at least 1 - alpha. The interval is derived from the noise
+// parameters used to compute the result. If the bounds only allow nonnegative
+// (resp. nonpositive) summands, the interval is clamped accordingly. It is
+// computed from the result before rounding, and its bounds are not rounded.
+//
+// An error is returned, and the BoundedSumInt64 is left untouched, if alpha is
+// not strictly between 0 and 1, or if the noise doesn't implement
+// noise.ConfidenceIntervalComputer.
+func (bs *BoundedSumInt64) ResultWithConfidenceInterval(alpha float64) (int64, noise.ConfidenceInterval, error) {
+	const label = "dpagg.BoundedSumInt64.ResultWithConfidenceInterval"
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	ciNoise, err := confidenceIntervalComputer(label, bs.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	noisedResult := bs.noisedResult()
+	result := bs.rounding.RoundInt64(noisedResult)
+	ci, err := ciNoise.ComputeConfidenceIntervalInt64(noisedResult, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, alpha)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+	return result, clampSumConfidenceInterval(ci, float64(bs.lower), float64(bs.upper)), nil
+}
+
 // encodableBoundedSumFloat64 can be encoded by the gob package.
 type encodableBoundedSumInt64 struct {
-	Epsilon         float64
-	Delta           float64
-	L0Sensitivity   int64
-	LInfSensitivity int64
-	Lower           int64
-	Upper           int64
-	NoiseKind       noise.Kind
-	Sum             int64
-	ResultReturned  bool
+	Epsilon              float64
+	Delta                float64
+	L0Sensitivity        int64
+	LInfSensitivity      int64
+	Lower                int64
+	Upper                int64
+	NoiseKind            noise.Kind
+	NeighbouringRelation NeighbouringRelation
+	Rounding             Rounding
+	Sum                  int64
+	ResultReturned       bool
 }
 
 // GobEncode encodes BoundedSumInt64.
 func (bs *BoundedSumInt64) GobEncode() ([]byte, error) {
 	enc := encodableBoundedSumInt64{
-		Epsilon:         bs.epsilon,
-		Delta:           bs.delta,
-		L0Sensitivity:   bs.l0Sensitivity,
-		LInfSensitivity: bs.lInfSensitivity,
-		Lower:           bs.lower,
-		Upper:           bs.upper,
-		NoiseKind:       noise.ToKind(bs.noise),
-		Sum:             bs.sum,
-		ResultReturned:  bs.resultReturned,
+		Epsilon:              bs.epsilon,
+		Delta:                bs.delta,
+		L0Sensitivity:        bs.l0Sensitivity,
+		LInfSensitivity:      bs.lInfSensitivity,
+		Lower:                bs.lower,
+		Upper:                bs.upper,
+		NoiseKind:            noise.ToKind(bs.noise),
+		NeighbouringRelation: bs.neighbouringRelation,
+		Rounding:             bs.rounding,
+		Sum:                  bs.sum,
+		ResultReturned:       bs.resultReturned,
 	}
 	bs.resultReturned = true
 	return encode(enc)
@@ -272,25 +425,29 @@ func (bs *BoundedSumInt64) GobDecode(data []byte) error {
 		return err
 	}
 	*bs = BoundedSumInt64{
-		epsilon:         enc.Epsilon,
-		delta:           enc.Delta,
-		l0Sensitivity:   enc.L0Sensitivity,
-		lInfSensitivity: enc.LInfSensitivity,
-		lower:           enc.Lower,
-		upper:           enc.Upper,
-		noiseKind:       enc.NoiseKind,
-		noise:           noise.ToNoise(enc.NoiseKind),
-		sum:             enc.Sum,
-		resultReturned:  enc.ResultReturned,
+		epsilon:              enc.Epsilon,
+		delta:                enc.Delta,
+		l0Sensitivity:        enc.L0Sensitivity,
+		lInfSensitivity:      enc.LInfSensitivity,
+		lower:                enc.Lower,
+		upper:                enc.Upper,
+		noiseKind:            enc.NoiseKind,
+		noise:                noise.ToNoise(enc.NoiseKind),
+		neighbouringRelation: enc.NeighbouringRelation,
+		rounding:             enc.Rounding,
+		sum:                  enc.Sum,
+		resultReturned:       enc.ResultReturned,
 	}
 	return nil
 }
 
 // BoundedSumFloat64 calculates a differentially private sum of a collection of
 // float64 values. It supports scaling the noise in the case where users can
-// contribute to multiple partitions (via the MaxPartitionsContributed parameter), but it
-// assumes that in each BoundedSum instance (i.e., partition), each user contributes
-// at most one value.
+// contribute to multiple partitions (via the MaxPartitionsContributed parameter)
+// and can contribute to a single partition multiple times (via the
+// MaxContributionsPerPartition parameter). These limits are not enforced by
+// BoundedSumFloat64: the caller is responsible for bounding the contributions
+// of each user, e.g. with BoundContributions.
 //
 // Note: Do not use when your results may cause overflows for float64
 // values. This aggregation is not hardened for such applications yet.
@@ -306,6 +463,9 @@ type BoundedSumFloat64 struct {
 	upper           float64
 	noise           noise.Noise
 	noiseKind       noise.Kind // necessary for serializing noise.Noise information
+	// Neighbouring relation used to compute lInfSensitivity.
+	neighbouringRelation NeighbouringRelation
+	rounding             Rounding
 
 	// State variables
 	sum            float64
@@ -319,7 +479,9 @@ func bsEquallyInitializedFloat64(s1, s2 *BoundedSumFloat64) bool {
 		s1.lInfSensitivity == s2.lInfSensitivity &&
 		s1.lower == s2.lower &&
 		s1.upper == s2.upper &&
-		s1.noiseKind == s2.noiseKind
+		s1.noiseKind == s2.noiseKind &&
+		s1.neighbouringRelation == s2.neighbouringRelation &&
+		s1.rounding == s2.rounding
 }
 
 // BoundedSumFloat64Options contains the options necessary to initialize a BoundedSumFloat64.
@@ -331,9 +493,50 @@ type BoundedSumFloat64Options struct {
 	Lower, Upper             float64
 	Noise                    noise.Noise // Type of noise used in BoundedSum. Defaults to Laplace noise.
 	// How many times may a single user contribute to a single partition?
-	// Defaults to 1. This is only needed for other aggregation functions using BoundedSum;
-	// which is why the option is not exported.
-	maxContributionsPerPartition int64
+	// Defaults to 1.
+	MaxContributionsPerPartition int64
+	// Definition of neighbouring datasets used to compute the sensitivity.
+	// Defaults to AddOrRemoveOne.
+	NeighbouringRelation NeighbouringRelation
+	// Rounding applied to the result. Defaults to no rounding.
+	Rounding Rounding
+}
+
+// Validate returns an error if NewBoundedSumFloat64 would fail with these
+// options. It does not build a BoundedSumFloat64, and does not consume
+// randomness. Only the parameters of noise.Laplace() and noise.Gaussian() are
+// checked: with another Noise, NewBoundedSumFloat64 may still fail.
+func (opt *BoundedSumFloat64Options) Validate() error {
+	const label = "dpagg.BoundedSumFloat64Options"
+	if opt == nil {
+		opt = &BoundedSumFloat64Options{}
+	}
+	l0 := opt.MaxPartitionsContributed
+	if l0 == 0 {
+		l0 = 1
+	}
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
+	if maxContributionsPerPartition == 0 {
+		maxContributionsPerPartition = 1
+	}
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	if opt.Lower == 0 && opt.Upper == 0 {
+		return errNoBounds(label)
+	}
+	if err := checks.CheckBoundsFloat64(label, opt.Lower, opt.Upper); err != nil {
+		return err
+	}
+	lInf, err := getLInfFloatForRelation(opt.Lower, opt.Upper, maxContributionsPerPartition, opt.NeighbouringRelation)
+	if err != nil {
+		return err
+	}
+	if err := checkRounding(label, opt.Rounding); err != nil {
+		return err
+	}
+	return noise.CheckParameters(label, n, l0, lInf, opt.Epsilon, opt.Delta)
 }
 
 // NewBoundedSumFloat64 returns a new BoundedSumFloat64, whose sum is initialized at 0.
@@ -347,7 +550,7 @@ func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
 		l0 = 1
 	}
 
-	maxContributionsPerPartition := opt.maxContributionsPerPartition
+	maxContributionsPerPartition := opt.MaxContributionsPerPartition
 	if maxContributionsPerPartition == 0 {
 		maxContributionsPerPartition = 1
 	}
@@ -366,10 +569,14 @@ func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", lower, upper, err)
 	}
-	lInf, err := getLInfFloat(lower, upper, maxContributionsPerPartition)
+	lInf, err := getLInfFloatForRelation(lower, upper, maxContributionsPerPartition, opt.NeighbouringRelation)
 	if err != nil {
 		// TODO: do not exit the program from within library code
-		log.Fatalf("getLInfFloat(lower %f, upper %f, maxContributionsPerPartition %d) failed with %v", lower, upper, maxContributionsPerPartition, err)
+		log.Fatalf("getLInfFloatForRelation(lower %f, upper %f, maxContributionsPerPartition %d, neighbouringRelation %v) failed with %v", lower, upper, maxContributionsPerPartition, opt.NeighbouringRelation, err)
+	}
+	if err := checkRounding("NewBoundedSumFloat64", opt.Rounding); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("checkRounding(%+v) failed with %v", opt.Rounding, err)
 	}
 	// Check that the parameters are compatible with the noise chosen by calling
 	// the noise on some dummy value.
@@ -377,16 +584,37 @@ func NewBoundedSumFloat64(opt *BoundedSumFloat64Options) *BoundedSumFloat64 {
 	n.AddNoiseFloat64(0, l0, lInf, eps, del)
 
 	return &BoundedSumFloat64{
-		epsilon:         eps,
-		delta:           del,
-		l0Sensitivity:   l0,
-		lInfSensitivity: lInf,
-		lower:           lower,
-		upper:           upper,
-		noise:           n,
-		noiseKind:       noise.ToKind(n),
-		sum:             0,
-		resultReturned:  false,
+		epsilon:              eps,
+		delta:                del,
+		l0Sensitivity:        l0,
+		lInfSensitivity:      lInf,
+		lower:                lower,
+		upper:                upper,
+		noise:                n,
+		noiseKind:            noise.ToKind(n),
+		neighbouringRelation: opt.NeighbouringRelation,
+		rounding:             opt.Rounding,
+		sum:                  0,
+		resultReturned:       false,
+	}
+}
+
+// errNoBounds returns the error for options where neither Lower nor Upper is
+// set.
+func errNoBounds(label string) error {
+	return fmt.Errorf("%s: Lower and Upper are both 0, at least one of them must be set (automatic bounds determination is not implemented yet)", label)
+}
+
+// maxContributionsOverflowError returns the error for a
+// maxContributionsPerPartition so large that the lInf sensitivity would
+// overflow, given the largest value that would not.
+func maxContributionsOverflowError(label string, maxContributionsPerPartition int64, largest string) error {
+	return &checks.ParameterError{
+		Label:        label,
+		Parameter:    "MaxContributionsPerPartition",
+		Value:        maxContributionsPerPartition,
+		Constraint:   checks.InRange,
+		AllowedRange: "[1, " + largest + "] (the lInf sensitivity would overflow)",
 	}
 }
 
@@ -405,14 +633,10 @@ func getLInfFloat(lower, upper float64, maxContributionsPerPartition int64) (flo
 		upper = -upper
 	}
 	if lInfFloatOverflows(lower, maxContributionsPerPartition) {
-		return 0, fmt.Errorf(
-			"lower = %f and maxContributionsPerPartition =%d are too high - the lInf sensitivity may overflow",
-			lower, maxContributionsPerPartition)
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumFloat64", maxContributionsPerPartition, fmt.Sprint(math.MaxFloat64/lower))
 	}
 	if lInfFloatOverflows(upper, maxContributionsPerPartition) {
-		return 0, fmt.Errorf(
-			"upper = %f and maxContributionsPerPartition = %d are too high - the lInf sensitivity may overflow",
-			upper, maxContributionsPerPartition)
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumFloat64", maxContributionsPerPartition, fmt.Sprint(math.MaxFloat64/upper))
 	}
 	if lower > upper {
 		return lower * float64(maxContributionsPerPartition), nil
@@ -420,6 +644,40 @@ func getLInfFloat(lower, upper float64, maxContributionsPerPartition int64) (flo
 	return upper * float64(maxContributionsPerPartition), nil
 }
 
+// getLInfFloatForRelation returns the L_inf sensitivity of the BoundedSum
+// object under the given neighbouring relation.
+func getLInfFloatForRelation(lower, upper float64, maxContributionsPerPartition int64, relation NeighbouringRelation) (float64, error) {
+	switch relation {
+	case AddOrRemoveOne:
+		return getLInfFloat(lower, upper, maxContributionsPerPartition)
+	case ReplaceOne:
+		return getReplaceOneLInfFloat(lower, upper, maxContributionsPerPartition)
+	default:
+		return 0, fmt.Errorf("dpagg.BoundedSumFloat64: unknown NeighbouringRelation %v", relation)
+	}
+}
+
+// getReplaceOneLInfFloat checks that the sensitivity parameters will not
+// create overflow errors, and returns the L_inf sensitivity of the BoundedSum
+// object under the ReplaceOne relation, which is calculated by the formula
+// = (upper - lower) * maxContributionsPerPartition. It requires lower < upper.
+func getReplaceOneLInfFloat(lower, upper float64, maxContributionsPerPartition int64) (float64, error) {
+	width := upper - lower
+	if math.IsInf(width, 0) {
+		return 0, &checks.ParameterError{
+			Label:        "dpagg.BoundedSumFloat64",
+			Parameter:    "Upper",
+			Value:        upper,
+			Constraint:   checks.InRange,
+			AllowedRange: fmt.Sprintf("[%g, %g] (Upper - Lower would overflow)", lower, math.MaxFloat64+lower),
+		}
+	}
+	if lInfFloatOverflows(width, maxContributionsPerPartition) {
+		return 0, maxContributionsOverflowError("dpagg.BoundedSumFloat64", maxContributionsPerPartition, fmt.Sprint(math.MaxFloat64/width))
+	}
+	return width * float64(maxContributionsPerPartition), nil
+}
+
 // Add adds a new summand to the BoundedSumFloat64. It ignores NaN summands
 // because introducing even a single NaN summand will result in a NaN sum
 // regardless of other summands, which would break the indistinguishability
@@ -439,6 +697,33 @@ func (bs *BoundedSumFloat64) Add(e float64) {
 	}
 }
 
+// AddBatch adds new summands to the BoundedSumFloat64, ignoring NaN summands
+// like Add. It is equivalent to calling Add on each of them, and adds them in
+// the same order, but only checks once that the result hasn't been returned,
+// and clamps the summands without an error path: the bounds were checked when
+// the BoundedSumFloat64 was created.
+func (bs *BoundedSumFloat64) AddBatch(es []float64) {
+	if bs.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The sum has already been calculated and returned. It cannot be amended.")
+	}
+	lower, upper := bs.lower, bs.upper
+	sum := bs.sum
+	for _, e := range es {
+		if math.IsNaN(e) {
+			continue
+		}
+		if e < lower {
+			e = lower
+		}
+		if e > upper {
+			e = upper
+		}
+		sum += e
+	}
+	bs.sum = sum
+}
+
 // Merge merges bs2 into bs (i.e., adds to bs all entries that were added to
 // bs2). bs2 is consumed by this operation: bs2 may not be used after it is
 // merged into bs.
@@ -468,6 +753,12 @@ func checkMergeBoundedSumFloat64(bs1, bs2 *BoundedSumFloat64) error {
 // elements added so far. It can be called only once, after which no further
 // operation can be done on the BoundedSumFloat64.
 func (bs *BoundedSumFloat64) Result() float64 {
+	return bs.rounding.RoundFloat64(bs.noisedResult())
+}
+
+// noisedResult returns the noised sum, before rounding. Like Result, it can be
+// called only once.
+func (bs *BoundedSumFloat64) noisedResult() float64 {
 	if bs.resultReturned {
 		// TODO: do not exit the program from within library code
 		log.Fatalf("The sum has already been calculated and returned. It can only be returned once.")
@@ -478,41 +769,90 @@ func (bs *BoundedSumFloat64) Result() float64 {
 
 // ThresholdedResult is similar to Result() but applies thresholding to the
 // result. So, if the result is less than the threshold specified by the noise,
-// mechanism, it returns nil. Otherwise, it returns the result.
+// mechanism, it returns nil. Otherwise, it returns the result. Thresholding is
+// applied before rounding.
 func (bs *BoundedSumFloat64) ThresholdedResult(deltaThreshold float64) *float64 {
 	threshold := bs.noise.Threshold(bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, deltaThreshold)
-	result := bs.Result()
+	result := bs.noisedResult()
 	if result < threshold {
 		return nil
 	}
+	result = bs.rounding.RoundFloat64(result)
 	return &result
 }
 
+// ResultWithConfidenceInterval is similar to Result() but additionally returns
+// a confidence interval that contains the true (unnoised) clamped sum with a
+// probability of 1 - alpha. The interval is derived from the noise parameters
+// used to compute the result. If the bounds only allow nonnegative (resp.
+// nonpositive) summands, the interval is clamped accordingly. It is computed
+// from the result before rounding, and its bounds are not rounded.
+//
+// An error is returned, and the BoundedSumFloat64 is left untouched, if alpha
+// is not strictly between 0 and 1, or if the noise doesn't implement
+// noise.ConfidenceIntervalComputer.
+func (bs *BoundedSumFloat64) ResultWithConfidenceInterval(alpha float64) (float64, noise.ConfidenceInterval, error) {
+	const label = "dpagg.BoundedSumFloat64.ResultWithConfidenceInterval"
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	ciNoise, err := confidenceIntervalComputer(label, bs.noise)
+	if err != nil {
+		return 0, noise.ConfidenceInterval{}, err
+	}
+	noisedResult := bs.noisedResult()
+	result := bs.rounding.RoundFloat64(noisedResult)
+	ci, err := ciNoise.ComputeConfidenceIntervalFloat64(noisedResult, bs.l0Sensitivity, bs.lInfSensitivity, bs.epsilon, bs.delta, alpha)
+	if err != nil {
+		return result, noise.ConfidenceInterval{}, err
+	}
+	return result, clampSumConfidenceInterval(ci, bs.lower, bs.upper), nil
+}
+
+// clampSumConfidenceInterval restricts the confidence interval of a sum to
+// nonnegative values if lower >= 0 and to nonpositive values if upper <= 0,
+// since the true sum is then known to have that sign.
+func clampSumConfidenceInterval(ci noise.ConfidenceInterval, lower, upper float64) noise.ConfidenceInterval {
+	if lower >= 0 {
+		ci.LowerBound = math.Max(ci.LowerBound, 0)
+		ci.UpperBound = math.Max(ci.UpperBound, 0)
+	}
+	if upper <= 0 {
+		ci.LowerBound = math.Min(ci.LowerBound, 0)
+		ci.UpperBound = math.Min(ci.UpperBound, 0)
+	}
+	return ci
+}
+
 // encodableBoundedSumFloat64 can be encoded by the gob package.
 type encodableBoundedSumFloat64 struct {
-	Epsilon         float64
-	Delta           float64
-	L0Sensitivity   int64
-	LInfSensitivity float64
-	Lower           float64
-	Upper           float64
-	NoiseKind       noise.Kind
-	Sum             float64
-	ResultReturned  bool
+	Epsilon              float64
+	Delta                float64
+	L0Sensitivity        int64
+	LInfSensitivity      float64
+	Lower                float64
+	Upper                float64
+	NoiseKind            noise.Kind
+	NeighbouringRelation NeighbouringRelation
+	Rounding             Rounding
+	Sum                  float64
+	ResultReturned       bool
 }
 
 // GobEncode encodes BoundedSumInt64.
 func (bs *BoundedSumFloat64) GobEncode() ([]byte, error) {
 	enc := encodableBoundedSumFloat64{
-		Epsilon:         bs.epsilon,
-		Delta:           bs.delta,
-		L0Sensitivity:   bs.l0Sensitivity,
-		LInfSensitivity: bs.lInfSensitivity,
-		Lower:           bs.lower,
-		Upper:           bs.upper,
-		NoiseKind:       noise.ToKind(bs.noise),
-		Sum:             bs.sum,
-		ResultReturned:  bs.resultReturned,
+		Epsilon:              bs.epsilon,
+		Delta:                bs.delta,
+		L0Sensitivity:        bs.l0Sensitivity,
+		LInfSensitivity:      bs.lInfSensitivity,
+		Lower:                bs.lower,
+		Upper:                bs.upper,
+		NoiseKind:            noise.ToKind(bs.noise),
+		NeighbouringRelation: bs.neighbouringRelation,
+		Rounding:             bs.rounding,
+		Sum:                  bs.sum,
+		ResultReturned:       bs.resultReturned,
 	}
 	bs.resultReturned = true
 	return encode(enc)
@@ -527,16 +867,18 @@ func (bs *BoundedSumFloat64) GobDecode(data []byte) error {
 		return err
 	}
 	*bs = BoundedSumFloat64{
-		epsilon:         enc.Epsilon,
-		delta:           enc.Delta,
-		l0Sensitivity:   enc.L0Sensitivity,
-		lInfSensitivity: enc.LInfSensitivity,
-		lower:           enc.Lower,
-		upper:           enc.Upper,
-		noiseKind:       enc.NoiseKind,
-		noise:           noise.ToNoise(enc.NoiseKind),
-		sum:             enc.Sum,
-		resultReturned:  enc.ResultReturned,
+		epsilon:              enc.Epsilon,
+		delta:                enc.Delta,
+		l0Sensitivity:        enc.L0Sensitivity,
+		lInfSensitivity:      enc.LInfSensitivity,
+		lower:                enc.Lower,
+		upper:                enc.Upper,
+		noiseKind:            enc.NoiseKind,
+		noise:                noise.ToNoise(enc.NoiseKind),
+		neighbouringRelation: enc.NeighbouringRelation,
+		rounding:             enc.Rounding,
+		sum:                  enc.Sum,
+		resultReturned:       enc.ResultReturned,
 	}
 	return nil
 }
@@ -17,10 +17,12 @@
 package dpagg
 
 import (
+	"errors"
 	"math"
 	"reflect"
 	"testing"
 
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/go-cmp/cmp"
 )
@@ -56,6 +58,8 @@ func compareBoundedSumInt64(bs1, bs2 *BoundedSumInt64) bool {
 		bs1.upper == bs2.upper &&
 		bs1.noise == bs2.noise &&
 		bs1.noiseKind == bs2.noiseKind &&
+		bs1.neighbouringRelation == bs2.neighbouringRelation &&
+		bs1.rounding == bs2.rounding &&
 		bs1.sum == bs2.sum &&
 		bs1.resultReturned == bs2.resultReturned
 }
@@ -79,6 +83,8 @@ func TestBoundedSumInt64Serialization(t *testing.T) {
 			Lower:                    0,
 			Upper:                    1,
 			Noise:                    noise.Gaussian(),
+			NeighbouringRelation:     ReplaceOne,
+			Rounding:                 Rounding{SignificantDigits: 3},
 		}},
 	} {
 		bs, bsUnchanged := NewBoundedSumInt64(tc.opts), NewBoundedSumInt64(tc.opts)
@@ -110,6 +116,8 @@ func compareBoundedSumFloat64(bs1, bs2 *BoundedSumFloat64) bool {
 		bs1.upper == bs2.upper &&
 		bs1.noise == bs2.noise &&
 		bs1.noiseKind == bs2.noiseKind &&
+		bs1.neighbouringRelation == bs2.neighbouringRelation &&
+		bs1.rounding == bs2.rounding &&
 		bs1.sum == bs2.sum &&
 		bs1.resultReturned == bs2.resultReturned
 }
@@ -133,6 +141,8 @@ func TestBoundedSumFloat64Serialization(t *testing.T) {
 			Lower:                    0,
 			Upper:                    1,
 			Noise:                    noise.Gaussian(),
+			NeighbouringRelation:     ReplaceOne,
+			Rounding:                 Rounding{SignificantDigits: 3},
 		}},
 	} {
 		bs, bsUnchanged := NewBoundedSumFloat64(tc.opts), NewBoundedSumFloat64(tc.opts)
@@ -215,6 +225,67 @@ func TestGetLInfFloat(t *testing.T) {
 	}
 }
 
+func TestGetLInfIntForRelation(t *testing.T) {
+	for _, tc := range []struct {
+		desc                         string
+		lower                        int64
+		upper                        int64
+		maxContributionsPerPartition int64
+		relation                     NeighbouringRelation
+		want                         int64
+		wantErr                      bool
+	}{
+		{"AddOrRemoveOne", -7, 5, 2, AddOrRemoveOne, 14, false},
+		{"ReplaceOne & lower < 0 & upper > 0", -7, 5, 2, ReplaceOne, 24, false},
+		{"ReplaceOne & lower > 0 & upper > 0", 3, 5, 1, ReplaceOne, 2, false},
+		{"ReplaceOne & lower = math.MinInt64", math.MinInt64, -5, 1, ReplaceOne, math.MaxInt64 - 4, false},
+		{"ReplaceOne & upper - lower overflows", -1, math.MaxInt64, 1, ReplaceOne, 0, true},
+		{"ReplaceOne & lInf sensitivity overflows", 0, math.MaxInt64/2 + 1, 2, ReplaceOne, 0, true},
+		{"unknown relation", -7, 5, 1, NeighbouringRelation(-1), 0, true},
+	} {
+		got, err := getLInfIntForRelation(tc.lower, tc.upper, tc.maxContributionsPerPartition, tc.relation)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("getLInfIntForRelation: when %s for err got %v, want %t", tc.desc, err, tc.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("getLInfIntForRelation: when %s got %d, want %d", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestGetLInfFloatForRelation(t *testing.T) {
+	for _, tc := range []struct {
+		desc                         string
+		lower                        float64
+		upper                        float64
+		maxContributionsPerPartition int64
+		relation                     NeighbouringRelation
+		want                         float64
+		wantErr                      bool
+	}{
+		{"AddOrRemoveOne", -7, 5, 2, AddOrRemoveOne, 14, false},
+		{"ReplaceOne & lower < 0 & upper > 0", -7, 5, 2, ReplaceOne, 24, false},
+		{"ReplaceOne & lower > 0 & upper > 0", 3, 5.5, 1, ReplaceOne, 2.5, false},
+		{"ReplaceOne & upper - lower overflows", -math.MaxFloat64, math.MaxFloat64, 1, ReplaceOne, 0, true},
+		{"ReplaceOne & lInf sensitivity overflows", 0, math.MaxFloat64, 2, ReplaceOne, 0, true},
+		{"unknown relation", -7, 5, 1, NeighbouringRelation(-1), 0, true},
+	} {
+		got, err := getLInfFloatForRelation(tc.lower, tc.upper, tc.maxContributionsPerPartition, tc.relation)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("getLInfFloatForRelation: when %s for err got %v, want %t", tc.desc, err, tc.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("getLInfFloatForRelation: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
 func TestNewBoundedSumInt64(t *testing.T) {
 	for _, tc := range []struct {
 		desc string
@@ -228,7 +299,7 @@ func TestNewBoundedSumInt64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noNoise{},
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumInt64{
 				epsilon:         ln3,
@@ -268,7 +339,7 @@ func TestNewBoundedSumInt64(t *testing.T) {
 				MaxPartitionsContributed:     1,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumInt64{
 				epsilon:         ln3,
@@ -303,7 +374,7 @@ func TestNewBoundedSumFloat64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noNoise{},
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumFloat64{
 				epsilon:         ln3,
@@ -343,7 +414,7 @@ func TestNewBoundedSumFloat64(t *testing.T) {
 				MaxPartitionsContributed:     1,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumFloat64{
 				epsilon:         ln3,
@@ -402,6 +473,52 @@ func TestAddFloat64IgnoresNaN(t *testing.T) {
 	}
 }
 
+func TestAddBatchInt64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []int64
+	}{
+		{"empty batch", nil},
+		{"entries within bounds", []int64{1, 2, 3, 4}},
+		{"clamped entries", []int64{-100, 3, math.MaxInt64, math.MinInt64}},
+	} {
+		batch, single := getNoiselessBSI(), getNoiselessBSI()
+		batch.Add(2)
+		single.Add(2)
+		batch.AddBatch(tc.entries)
+		for _, e := range tc.entries {
+			single.Add(e)
+		}
+		if got, want := batch.Result(), single.Result(); got != want {
+			t.Errorf("AddBatch: when %s got %d, want %d", tc.desc, got, want)
+		}
+	}
+}
+
+func TestAddBatchFloat64(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []float64
+	}{
+		{"empty batch", nil},
+		{"entries within bounds", []float64{1.1, 2.2, 3.3, 4.4}},
+		{"clamped entries", []float64{-100, 3, math.Inf(1), math.Inf(-1)}},
+		{"NaN entries", []float64{1, math.NaN(), 2}},
+	} {
+		batch, single := getNoiselessBSF(), getNoiselessBSF()
+		batch.Add(0.1)
+		single.Add(0.1)
+		batch.AddBatch(tc.entries)
+		for _, e := range tc.entries {
+			single.Add(e)
+		}
+		// The summands are added in the same order, so the sums are exactly equal.
+		if got, want := batch.Result(), single.Result(); got != want {
+			t.Errorf("AddBatch: when %s got %f, want %f", tc.desc, got, want)
+		}
+	}
+}
+
 func TestMergeBoundedSumInt64(t *testing.T) {
 	bs1 := getNoiselessBSI()
 	bs2 := getNoiselessBSI()
@@ -457,7 +574,7 @@ func TestCheckMergeBoundedSumInt64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumInt64Options{
 				Epsilon:                      ln3,
@@ -466,7 +583,7 @@ func TestCheckMergeBoundedSumInt64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			false,
 			false,
@@ -566,13 +683,13 @@ func TestCheckMergeBoundedSumInt64(t *testing.T) {
 				Epsilon:                      ln3,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumInt64Options{
 				Epsilon:                      ln3,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 5,
+				MaxContributionsPerPartition: 5,
 			},
 			false,
 			false,
@@ -656,7 +773,7 @@ func TestCheckMergeBoundedSumFloat64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumFloat64Options{
 				Epsilon:                      ln3,
@@ -665,7 +782,7 @@ func TestCheckMergeBoundedSumFloat64(t *testing.T) {
 				Lower:                        -1,
 				Upper:                        5,
 				Noise:                        noise.Gaussian(),
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			false,
 			false,
@@ -765,13 +882,13 @@ func TestCheckMergeBoundedSumFloat64(t *testing.T) {
 				Epsilon:                      ln3,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 2,
+				MaxContributionsPerPartition: 2,
 			},
 			&BoundedSumFloat64Options{
 				Epsilon:                      ln3,
 				Lower:                        -1,
 				Upper:                        5,
-				maxContributionsPerPartition: 5,
+				MaxContributionsPerPartition: 5,
 			},
 			false,
 			false,
@@ -902,6 +1019,97 @@ func TestThresholdedResultFloat64(t *testing.T) {
 	}
 }
 
+func TestBoundedSumRounding(t *testing.T) {
+	bsi := NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 100, Noise: noNoise{}, Rounding: Rounding{Granularity: 10}})
+	bsi.Add(42)
+	bsi.Add(3)
+	if got, want := bsi.Result(), int64(50); got != want {
+		t.Errorf("BoundedSumInt64.Result with Granularity 10: got %d, want %d", got, want)
+	}
+
+	// Thresholding is applied to the noisy sum (6 > 5) before rounding.
+	bsi = NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 100, Noise: noNoise{}, Rounding: Rounding{Granularity: 20}})
+	bsi.Add(6)
+	if got := bsi.ThresholdedResult(tenten); got == nil || *got != 0 {
+		t.Errorf("BoundedSumInt64.ThresholdedResult with Granularity 20: got %v, want 0", got)
+	}
+
+	bsf := NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 100, Noise: noNoise{}, Rounding: Rounding{SignificantDigits: 2}})
+	bsf.Add(12.5)
+	bsf.Add(0.05)
+	if got, want := bsf.Result(), 13.0; !ApproxEqual(got, want) {
+		t.Errorf("BoundedSumFloat64.Result with SignificantDigits 2: got %f, want %f", got, want)
+	}
+
+	bsf = NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Delta: tenten, Lower: 0, Upper: 100, Noise: noNoise{}, Rounding: Rounding{Granularity: 0.5}})
+	bsf.Add(1.1)
+	if got := bsf.ThresholdedResult(tenten); got != nil {
+		t.Errorf("BoundedSumFloat64.ThresholdedResult below threshold: got %v, want nil", *got)
+	}
+}
+
+func TestBoundedSumInt64ResultWithConfidenceInterval(t *testing.T) {
+	bs := getNoiselessBSI()
+	bs.Add(1)
+	bs.Add(2)
+	bs.Add(3)
+	bs.Add(4)
+	got, ci, err := bs.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	want := noise.ConfidenceInterval{LowerBound: 9, UpperBound: 11, ConfidenceLevel: 0.95}
+	if got != 10 || !cmp.Equal(ci, want) {
+		t.Errorf("ResultWithConfidenceInterval: when 1, 2, 3, 4 were added got %d, %+v, want 10, %+v", got, ci, want)
+	}
+
+	// For nonnegative bounds, the interval is clamped to nonnegative values.
+	bs = NewBoundedSumInt64(&BoundedSumInt64Options{Epsilon: ln3, Lower: 0, Upper: 5, Noise: noNoise{}})
+	_, ci, err = bs.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	want = noise.ConfidenceInterval{LowerBound: 0, UpperBound: 1, ConfidenceLevel: 0.95}
+	if !cmp.Equal(ci, want) {
+		t.Errorf("ResultWithConfidenceInterval: with nonnegative bounds got %+v, want %+v", ci, want)
+	}
+
+	bs = getNoiselessBSI()
+	if _, _, err := bs.ResultWithConfidenceInterval(0); err == nil {
+		t.Errorf("ResultWithConfidenceInterval(0): got no error")
+	}
+}
+
+func TestBoundedSumFloat64ResultWithConfidenceInterval(t *testing.T) {
+	bs := getNoiselessBSF()
+	bs.Add(1.5)
+	bs.Add(2.5)
+	got, ci, err := bs.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	want := noise.ConfidenceInterval{LowerBound: 3, UpperBound: 5, ConfidenceLevel: 0.95}
+	if got != 4 || !cmp.Equal(ci, want) {
+		t.Errorf("ResultWithConfidenceInterval: when 1.5, 2.5 were added got %f, %+v, want 4, %+v", got, ci, want)
+	}
+
+	// For nonpositive bounds, the interval is clamped to nonpositive values.
+	bs = NewBoundedSumFloat64(&BoundedSumFloat64Options{Epsilon: ln3, Lower: -5, Upper: 0, Noise: noNoise{}})
+	_, ci, err = bs.ResultWithConfidenceInterval(0.05)
+	if err != nil {
+		t.Fatalf("ResultWithConfidenceInterval: got err %v", err)
+	}
+	want = noise.ConfidenceInterval{LowerBound: -1, UpperBound: 0, ConfidenceLevel: 0.95}
+	if !cmp.Equal(ci, want) {
+		t.Errorf("ResultWithConfidenceInterval: with nonpositive bounds got %+v, want %+v", ci, want)
+	}
+
+	bs = getNoiselessBSF()
+	if _, _, err := bs.ResultWithConfidenceInterval(math.NaN()); err == nil {
+		t.Errorf("ResultWithConfidenceInterval(NaN): got no error")
+	}
+}
+
 type mockNoise struct {
 	t *testing.T
 	noise.Noise
@@ -1201,6 +1409,32 @@ func TestBSEquallyInitializedInt64(t *testing.T) {
 				resultReturned:  false},
 			false,
 		},
+		{
+			"different neighbouring relation",
+			&BoundedSumInt64{
+				epsilon:              ln3,
+				delta:                0,
+				l0Sensitivity:        1,
+				lInfSensitivity:      1,
+				noiseKind:            noise.LaplaceNoise,
+				lower:                0,
+				upper:                1,
+				neighbouringRelation: AddOrRemoveOne,
+				sum:                  0,
+				resultReturned:       false},
+			&BoundedSumInt64{
+				epsilon:              ln3,
+				delta:                0,
+				l0Sensitivity:        1,
+				lInfSensitivity:      1,
+				noiseKind:            noise.LaplaceNoise,
+				lower:                0,
+				upper:                1,
+				neighbouringRelation: ReplaceOne,
+				sum:                  0,
+				resultReturned:       false},
+			false,
+		},
 	} {
 		if bsEquallyInitializedint64(tc.bs1, tc.bs2) != tc.equal {
 			t.Errorf("bsEquallyInitializedint64: when %v got %t, want %t", tc.desc, !tc.equal, tc.equal)
@@ -1383,9 +1617,104 @@ func TestBSEquallyInitializedFloat64(t *testing.T) {
 				resultReturned:  false},
 			false,
 		},
+		{
+			"different neighbouring relation",
+			&BoundedSumFloat64{
+				epsilon:              ln3,
+				delta:                0,
+				l0Sensitivity:        1,
+				lInfSensitivity:      1,
+				noiseKind:            noise.LaplaceNoise,
+				lower:                0,
+				upper:                1,
+				neighbouringRelation: AddOrRemoveOne,
+				sum:                  0,
+				resultReturned:       false},
+			&BoundedSumFloat64{
+				epsilon:              ln3,
+				delta:                0,
+				l0Sensitivity:        1,
+				lInfSensitivity:      1,
+				noiseKind:            noise.LaplaceNoise,
+				lower:                0,
+				upper:                1,
+				neighbouringRelation: ReplaceOne,
+				sum:                  0,
+				resultReturned:       false},
+			false,
+		},
 	} {
 		if bsEquallyInitializedFloat64(tc.bs1, tc.bs2) != tc.equal {
 			t.Errorf("bsEquallyInitializedFloat64: when %v got %t, want %t", tc.desc, !tc.equal, tc.equal)
 		}
 	}
 }
+
+func TestBoundedSumInt64OptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *BoundedSumInt64Options
+		wantErr bool
+	}{
+		{"valid options", &BoundedSumInt64Options{Epsilon: ln3, Lower: -1, Upper: 5}, false},
+		{"nil options", nil, true},
+		{"no bounds", &BoundedSumInt64Options{Epsilon: ln3}, true},
+		{"lower larger than upper", &BoundedSumInt64Options{Epsilon: ln3, Lower: 5, Upper: -1}, true},
+		{"lower is MinInt64", &BoundedSumInt64Options{Epsilon: ln3, Lower: math.MinInt64, Upper: 5}, true},
+		{"lInf sensitivity overflows", &BoundedSumInt64Options{Epsilon: ln3, Lower: 0, Upper: math.MaxInt64, MaxContributionsPerPartition: 2}, true},
+		{"Laplace with delta", &BoundedSumInt64Options{Epsilon: ln3, Delta: 1e-5, Lower: -1, Upper: 5}, true},
+		{"valid ReplaceOne", &BoundedSumInt64Options{Epsilon: ln3, Lower: -1, Upper: 5, NeighbouringRelation: ReplaceOne}, false},
+		{"ReplaceOne width overflows", &BoundedSumInt64Options{Epsilon: ln3, Lower: -1, Upper: math.MaxInt64, NeighbouringRelation: ReplaceOne}, true},
+		{"unknown NeighbouringRelation", &BoundedSumInt64Options{Epsilon: ln3, Lower: -1, Upper: 5, NeighbouringRelation: NeighbouringRelation(-1)}, true},
+		{"invalid Rounding", &BoundedSumInt64Options{Epsilon: ln3, Lower: -1, Upper: 5, Rounding: Rounding{Granularity: 10, SignificantDigits: 1}}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBoundedSumFloat64OptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		opt     *BoundedSumFloat64Options
+		wantErr bool
+	}{
+		{"valid options", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5}, false},
+		{"valid Gaussian", &BoundedSumFloat64Options{Epsilon: ln3, Delta: 1e-5, Lower: -1, Upper: 5, Noise: noise.Gaussian()}, false},
+		{"nil options", nil, true},
+		{"no bounds", &BoundedSumFloat64Options{Epsilon: ln3}, true},
+		{"NaN bound", &BoundedSumFloat64Options{Epsilon: ln3, Lower: math.NaN(), Upper: 5}, true},
+		{"infinite bound", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: math.Inf(1)}, true},
+		{"lInf sensitivity overflows", &BoundedSumFloat64Options{Epsilon: ln3, Lower: 0, Upper: math.MaxFloat64, MaxContributionsPerPartition: 2}, true},
+		{"Gaussian without delta", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5, Noise: noise.Gaussian()}, true},
+		{"valid ReplaceOne", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5, NeighbouringRelation: ReplaceOne}, false},
+		{"ReplaceOne width overflows", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -math.MaxFloat64, Upper: math.MaxFloat64, NeighbouringRelation: ReplaceOne}, true},
+		{"invalid Rounding", &BoundedSumFloat64Options{Epsilon: ln3, Lower: -1, Upper: 5, Rounding: Rounding{Granularity: math.Inf(1)}}, true},
+	} {
+		if err := tc.opt.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBoundedSumOptionsValidateReturnsParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		opt           interface{ Validate() error }
+		wantParameter string
+	}{
+		{"BoundedSumInt64 with zero Epsilon", &BoundedSumInt64Options{Lower: 0, Upper: 5}, "Epsilon"},
+		{"BoundedSumFloat64 with NaN Lower", &BoundedSumFloat64Options{Epsilon: ln3, Lower: math.NaN(), Upper: 5}, "Lower"},
+	} {
+		err := tc.opt.Validate()
+		var perr *checks.ParameterError
+		if !errors.As(err, &perr) {
+			t.Errorf("Validate: for %s got err %v, want a *checks.ParameterError", tc.desc, err)
+			continue
+		}
+		if perr.Parameter != tc.wantParameter {
+			t.Errorf("Validate: for %s got an error on %s, want %s", tc.desc, perr.Parameter, tc.wantParameter)
+		}
+	}
+}
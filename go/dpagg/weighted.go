@@ -0,0 +1,405 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+)
+
+// WeightedSum calculates a differentially private sum of weighted float64
+// values, i.e., the sum of weight*value over all records, where each record
+// carries a weight (e.g., a sampling weight from survey data).
+//
+// The contributions of a user are bounded by their weight rather than by
+// their number of records: the total weight of a single user in a single
+// partition is at most MaxWeightPerPartition, and their total weight across
+// all partitions is at most MaxTotalWeight. The noise is scaled from these
+// bounds. Values are clamped to [Lower, Upper].
+//
+// All the records of a user in a partition are added with a single call to
+// AddUserValues, which scales their weights down if their total exceeds
+// MaxWeightPerPartition. WeightedSum does not enforce MaxTotalWeight and
+// MaxPartitionsContributed: the caller is responsible for bounding the total
+// weight of each user across partitions, e.g. with BoundWeights.
+//
+// Not thread-safe.
+type WeightedSum struct {
+	// Parameters
+	epsilon               float64
+	delta                 float64
+	l0Sensitivity         int64
+	lInfSensitivity       float64
+	maxWeightPerPartition float64
+	lower                 float64
+	upper                 float64
+	noise                 noise.Noise
+	noiseKind             noise.Kind // necessary for serializing noise.Noise information
+
+	// State variables
+	sum            float64
+	resultReturned bool // whether the result has already been returned
+}
+
+func wsEquallyInitialized(ws1, ws2 *WeightedSum) bool {
+	return ws1.epsilon == ws2.epsilon &&
+		ws1.delta == ws2.delta &&
+		ws1.l0Sensitivity == ws2.l0Sensitivity &&
+		ws1.lInfSensitivity == ws2.lInfSensitivity &&
+		ws1.maxWeightPerPartition == ws2.maxWeightPerPartition &&
+		ws1.lower == ws2.lower &&
+		ws1.upper == ws2.upper &&
+		ws1.noiseKind == ws2.noiseKind
+}
+
+// WeightedSumOptions contains the options necessary to initialize a
+// WeightedSum.
+type WeightedSumOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	// What is the largest total weight of the records of a single user in a
+	// single partition? Required.
+	MaxWeightPerPartition float64
+	// What is the largest total weight of the records of a single user across
+	// all partitions? Defaults to MaxPartitionsContributed*MaxWeightPerPartition.
+	MaxTotalWeight float64
+	// Lower and Upper bounds for clamping values. Must be such that Lower < Upper.
+	Lower, Upper float64
+	Noise        noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewWeightedSum returns a new WeightedSum, initialized at 0.
+func NewWeightedSum(opt *WeightedSumOptions) *WeightedSum {
+	if opt == nil {
+		opt = &WeightedSumOptions{}
+	}
+	if err := checks.CheckBoundsFloat64("NewWeightedSum", opt.Lower, opt.Upper); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("CheckBoundsFloat64(lower %f, upper %f) failed with %v", opt.Lower, opt.Upper, err)
+	}
+	l0, lInf := weightedSensitivities("NewWeightedSum", opt)
+	lInf *= math.Max(math.Abs(opt.Lower), math.Abs(opt.Upper))
+
+	n := opt.Noise
+	if n == nil {
+		n = noise.Laplace()
+	}
+	// Check that the parameters are compatible with the noise chosen by calling
+	// the noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseFloat64(0, l0, lInf, eps, del)
+
+	return &WeightedSum{
+		epsilon:               eps,
+		delta:                 del,
+		l0Sensitivity:         l0,
+		lInfSensitivity:       lInf,
+		maxWeightPerPartition: opt.MaxWeightPerPartition,
+		lower:                 opt.Lower,
+		upper:                 opt.Upper,
+		noise:                 n,
+		noiseKind:             noise.ToKind(n),
+		sum:                   0,
+		resultReturned:        false,
+	}
+}
+
+// weightedSensitivities checks the weight bounds of opt, and returns the L_0
+// sensitivity and the L_∞ sensitivity of the total weight per partition.
+//
+// A user changes the total weight of at most MaxPartitionsContributed
+// partitions, by at most MaxWeightPerPartition each and by at most
+// MaxTotalWeight in total. This is captured by an L_∞ sensitivity of
+// MaxWeightPerPartition and an L_0 sensitivity of
+// min(MaxPartitionsContributed, ⌈MaxTotalWeight/MaxWeightPerPartition⌉),
+// which bounds both the L_1 and the L_2 sensitivities.
+func weightedSensitivities(label string, opt *WeightedSumOptions) (int64, float64) {
+	maxPartitionsContributed := opt.MaxPartitionsContributed
+	if maxPartitionsContributed == 0 {
+		maxPartitionsContributed = 1
+	}
+	w := opt.MaxWeightPerPartition
+	if err := checks.CheckLInfSensitivity(label+" (MaxWeightPerPartition)", w); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("%s: %v", label, err)
+	}
+	l0 := maxPartitionsContributed
+	if opt.MaxTotalWeight != 0 {
+		if err := checks.CheckLInfSensitivity(label+" (MaxTotalWeight)", opt.MaxTotalWeight); err != nil {
+			// TODO: do not exit the program from within library code
+			log.Fatalf("%s: %v", label, err)
+		}
+		if byWeight := int64(math.Ceil(opt.MaxTotalWeight / w)); byWeight < l0 {
+			l0 = byWeight
+		}
+	}
+	return l0, w
+}
+
+// AddUserValues adds all the weighted values contributed by a single user to
+// the WeightedSum; weights[i] is the weight of values[i]. Values are clamped to
+// [Lower, Upper], negative weights are treated as 0, and records with a NaN
+// value or weight are skipped. If the total weight of the records exceeds
+// MaxWeightPerPartition, all weights are scaled down proportionally.
+func (ws *WeightedSum) AddUserValues(values, weights []float64) {
+	if ws.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The weighted sum has already been calculated and returned. It cannot be amended.")
+	}
+	if len(values) != len(weights) {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("AddUserValues: got %d values and %d weights, want as many of each", len(values), len(weights))
+	}
+	var sum, totalWeight float64
+	for i, v := range values {
+		w := weights[i]
+		if math.IsNaN(v) || math.IsNaN(w) || w <= 0 {
+			continue
+		}
+		clamped, err := ClampFloat64(v, ws.lower, ws.upper)
+		if err != nil {
+			// TODO: do not exit the program from within library code
+			log.Fatalf("Couldn't clamp input value %v, err %v", v, err)
+		}
+		sum += w * clamped
+		totalWeight += w
+	}
+	if totalWeight > ws.maxWeightPerPartition {
+		sum *= ws.maxWeightPerPartition / totalWeight
+	}
+	ws.sum += sum
+}
+
+// Merge merges ws2 into ws (i.e., adds to ws all entries that were added to
+// ws2). ws2 is consumed by this operation: ws2 may not be used after it is
+// merged into ws.
+func (ws *WeightedSum) Merge(ws2 *WeightedSum) {
+	if err := checkMergeWeightedSum(ws, ws2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	ws.sum += ws2.sum
+	ws2.resultReturned = true
+}
+
+func checkMergeWeightedSum(ws1, ws2 *WeightedSum) error {
+	if ws1.resultReturned {
+		return fmt.Errorf("checkMergeWeightedSum: ws1 already returned the result, cannot be merged with another WeightedSum instance")
+	}
+	if ws2.resultReturned {
+		return fmt.Errorf("checkMergeWeightedSum: ws2 already returned the result, cannot be merged with another WeightedSum instance")
+	}
+	if !wsEquallyInitialized(ws1, ws2) {
+		return fmt.Errorf("checkMergeWeightedSum: ws1 and ws2 are not compatible")
+	}
+	return nil
+}
+
+// Result returns a differentially private estimate of the weighted sum of the
+// values added so far. It can be called only once, after which no further
+// operation can be done on the WeightedSum.
+func (ws *WeightedSum) Result() float64 {
+	if ws.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The weighted sum has already been calculated and returned. It can only be returned once.")
+	}
+	ws.resultReturned = true
+	return ws.noise.AddNoiseFloat64(ws.sum, ws.l0Sensitivity, ws.lInfSensitivity, ws.epsilon, ws.delta)
+}
+
+// encodableWeightedSum can be encoded by the gob package.
+type encodableWeightedSum struct {
+	Epsilon               float64
+	Delta                 float64
+	L0Sensitivity         int64
+	LInfSensitivity       float64
+	MaxWeightPerPartition float64
+	Lower                 float64
+	Upper                 float64
+	NoiseKind             noise.Kind
+	Sum                   float64
+	ResultReturned        bool
+}
+
+// GobEncode encodes WeightedSum.
+func (ws *WeightedSum) GobEncode() ([]byte, error) {
+	enc := encodableWeightedSum{
+		Epsilon:               ws.epsilon,
+		Delta:                 ws.delta,
+		L0Sensitivity:         ws.l0Sensitivity,
+		LInfSensitivity:       ws.lInfSensitivity,
+		MaxWeightPerPartition: ws.maxWeightPerPartition,
+		Lower:                 ws.lower,
+		Upper:                 ws.upper,
+		NoiseKind:             noise.ToKind(ws.noise),
+		Sum:                   ws.sum,
+		ResultReturned:        ws.resultReturned,
+	}
+	ws.resultReturned = true
+	return encode(enc)
+}
+
+// GobDecode decodes WeightedSum.
+func (ws *WeightedSum) GobDecode(data []byte) error {
+	var enc encodableWeightedSum
+	err := decode(&enc, data)
+	if err != nil {
+		log.Fatalf("GobDecode: couldn't decode WeightedSum from bytes")
+		return err
+	}
+	*ws = WeightedSum{
+		epsilon:               enc.Epsilon,
+		delta:                 enc.Delta,
+		l0Sensitivity:         enc.L0Sensitivity,
+		lInfSensitivity:       enc.LInfSensitivity,
+		maxWeightPerPartition: enc.MaxWeightPerPartition,
+		lower:                 enc.Lower,
+		upper:                 enc.Upper,
+		noiseKind:             enc.NoiseKind,
+		noise:                 noise.ToNoise(enc.NoiseKind),
+		sum:                   enc.Sum,
+		resultReturned:        enc.ResultReturned,
+	}
+	return nil
+}
+
+// WeightedCount calculates a differentially private count of weighted records,
+// i.e., the sum of their weights. It is a WeightedSum where every value is 1,
+// and follows the same contribution bounding rules.
+//
+// Not thread-safe.
+type WeightedCount struct {
+	sum WeightedSum
+}
+
+// WeightedCountOptions contains the options necessary to initialize a
+// WeightedCount.
+type WeightedCountOptions struct {
+	Epsilon                  float64 // Privacy parameter ε. Required.
+	Delta                    float64 // Privacy parameter δ. Required with Gaussian noise, must be 0 with Laplace noise.
+	MaxPartitionsContributed int64   // How many distinct partitions may a single user contribute to? Defaults to 1.
+	// What is the largest total weight of the records of a single user in a
+	// single partition? Required.
+	MaxWeightPerPartition float64
+	// What is the largest total weight of the records of a single user across
+	// all partitions? Defaults to MaxPartitionsContributed*MaxWeightPerPartition.
+	MaxTotalWeight float64
+	Noise          noise.Noise // Type of noise used. Defaults to Laplace noise.
+}
+
+// NewWeightedCount returns a new WeightedCount, initialized at 0.
+func NewWeightedCount(opt *WeightedCountOptions) *WeightedCount {
+	if opt == nil {
+		opt = &WeightedCountOptions{}
+	}
+	return &WeightedCount{sum: *NewWeightedSum(&WeightedSumOptions{
+		Epsilon:                  opt.Epsilon,
+		Delta:                    opt.Delta,
+		MaxPartitionsContributed: opt.MaxPartitionsContributed,
+		MaxWeightPerPartition:    opt.MaxWeightPerPartition,
+		MaxTotalWeight:           opt.MaxTotalWeight,
+		Lower:                    0,
+		Upper:                    1,
+		Noise:                    opt.Noise,
+	})}
+}
+
+// AddUserWeights adds the weights of all the records contributed by a single
+// user to the WeightedCount. Negative weights are treated as 0, and NaN weights
+// are skipped. If the total weight exceeds MaxWeightPerPartition, all weights
+// are scaled down proportionally.
+func (wc *WeightedCount) AddUserWeights(weights []float64) {
+	values := make([]float64, len(weights))
+	for i := range values {
+		values[i] = 1
+	}
+	wc.sum.AddUserValues(values, weights)
+}
+
+// Merge merges wc2 into wc (i.e., adds to wc all entries that were added to
+// wc2). wc2 is consumed by this operation: wc2 may not be used after it is
+// merged into wc.
+func (wc *WeightedCount) Merge(wc2 *WeightedCount) {
+	wc.sum.Merge(&wc2.sum)
+}
+
+// Result returns a differentially private estimate of the total weight of the
+// records added so far. It can be called only once, after which no further
+// operation can be done on the WeightedCount.
+func (wc *WeightedCount) Result() float64 {
+	return wc.sum.Result()
+}
+
+// GobEncode encodes WeightedCount.
+func (wc *WeightedCount) GobEncode() ([]byte, error) {
+	return wc.sum.GobEncode()
+}
+
+// GobDecode decodes WeightedCount.
+func (wc *WeightedCount) GobDecode(data []byte) error {
+	return wc.sum.GobDecode(data)
+}
+
+// BoundWeights bounds the weights of the records of a single user across
+// partitions; weights[p] holds the weights of the user's records in partition
+// p. It modifies weights in place: first, the weights of each partition are
+// scaled down so that their total is at most maxWeightPerPartition; then, all
+// weights are scaled down so that their grand total is at most maxTotalWeight.
+// Negative and NaN weights are set to 0.
+//
+// It returns an error if one of the bounds is not strictly positive.
+func BoundWeights(weights [][]float64, maxWeightPerPartition, maxTotalWeight float64) error {
+	if err := checks.CheckLInfSensitivity("BoundWeights (maxWeightPerPartition)", maxWeightPerPartition); err != nil {
+		return err
+	}
+	if err := checks.CheckLInfSensitivity("BoundWeights (maxTotalWeight)", maxTotalWeight); err != nil {
+		return err
+	}
+	var total float64
+	for _, partition := range weights {
+		var partitionTotal float64
+		for i, w := range partition {
+			if math.IsNaN(w) || w < 0 {
+				partition[i] = 0
+				continue
+			}
+			partitionTotal += w
+		}
+		if partitionTotal > maxWeightPerPartition {
+			scaleWeights(partition, maxWeightPerPartition/partitionTotal)
+			partitionTotal = maxWeightPerPartition
+		}
+		total += partitionTotal
+	}
+	if total > maxTotalWeight {
+		for _, partition := range weights {
+			scaleWeights(partition, maxTotalWeight/total)
+		}
+	}
+	return nil
+}
+
+func scaleWeights(weights []float64, factor float64) {
+	for i := range weights {
+		weights[i] *= factor
+	}
+}
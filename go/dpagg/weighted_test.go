@@ -0,0 +1,243 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpagg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+)
+
+func getNoiselessWeightedSum() *WeightedSum {
+	return NewWeightedSum(&WeightedSumOptions{
+		Epsilon:               ln3,
+		Delta:                 tenten,
+		MaxWeightPerPartition: 10,
+		Lower:                 -1,
+		Upper:                 5,
+		Noise:                 noNoise{},
+	})
+}
+
+func TestNewWeightedSum(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opt  *WeightedSumOptions
+		want *WeightedSum
+	}{
+		{"MaxPartitionsContributed is not set",
+			&WeightedSumOptions{
+				Epsilon:               ln3,
+				MaxWeightPerPartition: 2.5,
+				Lower:                 -1,
+				Upper:                 5,
+			},
+			&WeightedSum{
+				epsilon:               ln3,
+				l0Sensitivity:         1,
+				lInfSensitivity:       12.5,
+				maxWeightPerPartition: 2.5,
+				lower:                 -1,
+				upper:                 5,
+				noise:                 noise.Laplace(),
+				noiseKind:             noise.LaplaceNoise,
+			}},
+		{"MaxTotalWeight is not limiting",
+			&WeightedSumOptions{
+				Epsilon:                  ln3,
+				MaxPartitionsContributed: 3,
+				MaxWeightPerPartition:    2,
+				MaxTotalWeight:           100,
+				Lower:                    -4,
+				Upper:                    1,
+			},
+			&WeightedSum{
+				epsilon:               ln3,
+				l0Sensitivity:         3,
+				lInfSensitivity:       8,
+				maxWeightPerPartition: 2,
+				lower:                 -4,
+				upper:                 1,
+				noise:                 noise.Laplace(),
+				noiseKind:             noise.LaplaceNoise,
+			}},
+		{"MaxTotalWeight is limiting",
+			&WeightedSumOptions{
+				Epsilon:                  ln3,
+				Delta:                    tenten,
+				MaxPartitionsContributed: 10,
+				MaxWeightPerPartition:    2,
+				MaxTotalWeight:           5,
+				Lower:                    0,
+				Upper:                    1,
+				Noise:                    noise.Gaussian(),
+			},
+			&WeightedSum{
+				epsilon:               ln3,
+				delta:                 tenten,
+				l0Sensitivity:         3,
+				lInfSensitivity:       2,
+				maxWeightPerPartition: 2,
+				lower:                 0,
+				upper:                 1,
+				noise:                 noise.Gaussian(),
+				noiseKind:             noise.GaussianNoise,
+			}},
+	} {
+		if got := NewWeightedSum(tc.opt); !cmp.Equal(got, tc.want, cmp.AllowUnexported(WeightedSum{})) {
+			t.Errorf("NewWeightedSum: when %s got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestWeightedSumAddUserValues(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		values  []float64
+		weights []float64
+		want    float64
+	}{
+		{"no records", nil, nil, 0},
+		{"total weight within bound", []float64{1, 2}, []float64{3, 4}, 11},
+		{"values are clamped", []float64{-3, 9}, []float64{1, 1}, 4},
+		{"total weight exceeds bound", []float64{1, 2}, []float64{5, 15}, 17.5},
+		{"negative and NaN weights are ignored", []float64{1, 2, 3}, []float64{2, -4, math.NaN()}, 2},
+		{"NaN values are ignored", []float64{math.NaN(), 2}, []float64{20, 1}, 2},
+	} {
+		ws := getNoiselessWeightedSum()
+		ws.AddUserValues(tc.values, tc.weights)
+		if got := ws.Result(); !ApproxEqual(got, tc.want) {
+			t.Errorf("AddUserValues: when %s got %f, want %f", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestWeightedCount(t *testing.T) {
+	wc := NewWeightedCount(&WeightedCountOptions{
+		Epsilon:               ln3,
+		Delta:                 tenten,
+		MaxWeightPerPartition: 5,
+		Noise:                 noNoise{},
+	})
+	wc.AddUserWeights([]float64{1.5, 2})
+	wc.AddUserWeights([]float64{4, 4}) // scaled down to a total of 5
+	if got, want := wc.Result(), 8.5; !ApproxEqual(got, want) {
+		t.Errorf("WeightedCount: got %f, want %f", got, want)
+	}
+}
+
+func TestWeightedSumMerge(t *testing.T) {
+	ws1, ws2 := getNoiselessWeightedSum(), getNoiselessWeightedSum()
+	ws1.AddUserValues([]float64{1}, []float64{2})
+	ws2.AddUserValues([]float64{3}, []float64{1.5})
+	ws1.Merge(ws2)
+	if got, want := ws1.Result(), 6.5; !ApproxEqual(got, want) {
+		t.Errorf("Merge: got %f, want %f", got, want)
+	}
+	if !ws2.resultReturned {
+		t.Errorf("Merge: ws2 should be marked as consumed")
+	}
+}
+
+func TestWeightedSumCheckMerge(t *testing.T) {
+	opt := func(maxTotalWeight float64) *WeightedSumOptions {
+		return &WeightedSumOptions{
+			Epsilon:                  ln3,
+			MaxPartitionsContributed: 4,
+			MaxWeightPerPartition:    2,
+			MaxTotalWeight:           maxTotalWeight,
+			Lower:                    0,
+			Upper:                    1,
+		}
+	}
+	for _, tc := range []struct {
+		desc    string
+		opt1    *WeightedSumOptions
+		opt2    *WeightedSumOptions
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", opt(0), opt(0), false, false, false},
+		{"ws1 returned its result", opt(0), opt(0), true, false, true},
+		{"ws2 returned its result", opt(0), opt(0), false, true, true},
+		{"different total weight bounds", opt(0), opt(3), false, false, true},
+	} {
+		ws1, ws2 := NewWeightedSum(tc.opt1), NewWeightedSum(tc.opt2)
+		ws1.resultReturned = tc.state1
+		ws2.resultReturned = tc.state2
+		if err := checkMergeWeightedSum(ws1, ws2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestWeightedSumSerialization(t *testing.T) {
+	opt := &WeightedSumOptions{
+		Epsilon:                  ln3,
+		Delta:                    1e-5,
+		MaxPartitionsContributed: 2,
+		MaxWeightPerPartition:    3.5,
+		Lower:                    -1,
+		Upper:                    2,
+		Noise:                    noise.Gaussian(),
+	}
+	ws, wsUnchanged := NewWeightedSum(opt), NewWeightedSum(opt)
+	ws.AddUserValues([]float64{1, 2}, []float64{0.5, 1})
+	wsUnchanged.AddUserValues([]float64{1, 2}, []float64{0.5, 1})
+	bytes, err := encode(ws)
+	if err != nil {
+		t.Fatalf("encode(WeightedSum) error: %v", err)
+	}
+	wsUnmarshalled := new(WeightedSum)
+	if err := decode(wsUnmarshalled, bytes); err != nil {
+		t.Fatalf("decode(WeightedSum) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(wsUnchanged, wsUnmarshalled, cmp.AllowUnexported(WeightedSum{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", wsUnmarshalled, wsUnchanged)
+	}
+	// Check that the original WeightedSum has its resultReturned set to true after serialization.
+	if !ws.resultReturned {
+		t.Errorf("WeightedSum %v should have its resultReturned set to true after being serialized", ws)
+	}
+}
+
+func TestBoundWeights(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		weights [][]float64
+		want    [][]float64
+	}{
+		{"within bounds", [][]float64{{1, 1}, {2}}, [][]float64{{1, 1}, {2}}},
+		{"partition bound exceeded", [][]float64{{3, 5}, {1}}, [][]float64{{1.5, 2.5}, {1}}},
+		{"total bound exceeded", [][]float64{{4}, {4}, {4}}, [][]float64{{2}, {2}, {2}}},
+		{"invalid weights", [][]float64{{-1, math.NaN(), 1}}, [][]float64{{0, 0, 1}}},
+	} {
+		if err := BoundWeights(tc.weights, 4, 6); err != nil {
+			t.Fatalf("BoundWeights: when %s got error %v", tc.desc, err)
+		}
+		if !cmp.Equal(tc.weights, tc.want) {
+			t.Errorf("BoundWeights: when %s got %v, want %v", tc.desc, tc.weights, tc.want)
+		}
+	}
+	if err := BoundWeights(nil, 0, 1); err == nil {
+		t.Errorf("BoundWeights: with maxWeightPerPartition 0 got no error")
+	}
+}
@@ -0,0 +1,337 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package dpml contains differentially private machine learning estimators.
+package dpml
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// LinearRegression fits a differentially private ridge regression model
+// y ≈ β·x on rows (x, y), where x has NumFeatures features.
+//
+// It computes the sufficient statistics XᵀX and Xᵀy of the rows, and releases
+// them with Gaussian noise. Each row x is scaled down to have an L2 norm of at
+// most MaxFeatureNorm, and each label y is clamped to [-MaxLabel, MaxLabel],
+// which bounds the L2 sensitivity of XᵀX (resp. Xᵀy) by MaxFeatureNorm²
+// (resp. MaxFeatureNorm·MaxLabel) per row. Each of XᵀX and Xᵀy uses half of
+// the privacy budget.
+//
+// The coefficients are then obtained in a post-processing step by solving
+// (A + λI)β = b, where b is the noisy Xᵀy, λ is the Regularization and A is
+// the noisy XᵀX projected onto the positive semidefinite matrices (i.e., with
+// its negative eigenvalues set to 0). This system is positive definite
+// regardless of the noise.
+//
+// To fit an intercept, add a constant feature to every row.
+//
+// LinearRegression supports users contributing multiple rows (via the
+// MaxRowsContributed parameter). The estimator itself doesn't ensure this
+// limit is respected.
+//
+// The memory used is quadratic in NumFeatures; LinearRegression is intended
+// for models with a small number of features (e.g., fewer than 50).
+//
+// Not thread-safe.
+type LinearRegression struct {
+	// Parameters
+	epsilon            float64
+	delta              float64
+	numFeatures        int
+	maxRowsContributed int64
+	maxFeatureNorm     float64
+	maxLabel           float64
+	regularization     float64
+	noise              noise.Noise
+
+	// State variables
+	xtx            []float64 // upper triangle of XᵀX, row by row
+	xty            []float64
+	resultReturned bool // whether the result has already been returned
+}
+
+func lrEquallyInitialized(lr1, lr2 *LinearRegression) bool {
+	return lr1.epsilon == lr2.epsilon &&
+		lr1.delta == lr2.delta &&
+		lr1.numFeatures == lr2.numFeatures &&
+		lr1.maxRowsContributed == lr2.maxRowsContributed &&
+		lr1.maxFeatureNorm == lr2.maxFeatureNorm &&
+		lr1.maxLabel == lr2.maxLabel &&
+		lr1.regularization == lr2.regularization
+}
+
+// LinearRegressionOptions contains the options necessary to initialize a
+// LinearRegression.
+type LinearRegressionOptions struct {
+	Epsilon            float64 // Privacy parameter ε. Required.
+	Delta              float64 // Privacy parameter δ. Required.
+	NumFeatures        int     // Number of features of each row. Required.
+	MaxRowsContributed int64   // How many rows may a single user contribute? Defaults to 1.
+	// Maximum L2 norm of the features of a row. Rows with a larger norm are
+	// scaled down to this norm. Required.
+	MaxFeatureNorm float64
+	// Labels are clamped to [-MaxLabel, MaxLabel]. Required.
+	MaxLabel float64
+	// Ridge regularization parameter λ, added to the diagonal of XᵀX. It should
+	// be of the order of the noise added to XᵀX or larger. Required.
+	Regularization float64
+}
+
+// NewLinearRegression returns a new LinearRegression.
+func NewLinearRegression(opt *LinearRegressionOptions) *LinearRegression {
+	if opt == nil {
+		opt = &LinearRegressionOptions{}
+	}
+	if opt.NumFeatures <= 0 {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewLinearRegression: NumFeatures is %d, should be strictly positive", opt.NumFeatures)
+	}
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{
+		{"MaxFeatureNorm", opt.MaxFeatureNorm},
+		{"MaxLabel", opt.MaxLabel},
+		{"Regularization", opt.Regularization},
+	} {
+		if p.value <= 0 || math.IsInf(p.value, 0) || math.IsNaN(p.value) {
+			// TODO: do not exit the program from within library code
+			log.Fatalf("NewLinearRegression: %s is %f, should be finite and strictly positive", p.name, p.value)
+		}
+	}
+
+	// Set defaults.
+	maxRowsContributed := opt.MaxRowsContributed
+	if maxRowsContributed == 0 {
+		maxRowsContributed = 1
+	}
+	if err := checks.CheckL0Sensitivity("NewLinearRegression (MaxRowsContributed)", maxRowsContributed); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("NewLinearRegression: %v", err)
+	}
+
+	n := noise.Gaussian()
+	// Check that the parameters are compatible with the noise by calling the
+	// noise on some dummy value.
+	eps, del := opt.Epsilon, opt.Delta
+	n.AddNoiseFloat64(0, 1, 1, eps/2, del/2)
+
+	d := opt.NumFeatures
+	return &LinearRegression{
+		epsilon:            eps,
+		delta:              del,
+		numFeatures:        d,
+		maxRowsContributed: maxRowsContributed,
+		maxFeatureNorm:     opt.MaxFeatureNorm,
+		maxLabel:           opt.MaxLabel,
+		regularization:     opt.Regularization,
+		noise:              n,
+		xtx:                make([]float64, d*(d+1)/2),
+		xty:                make([]float64, d),
+		resultReturned:     false,
+	}
+}
+
+// Add adds a row with features x and label y to the LinearRegression. x must
+// have NumFeatures elements. Rows containing a NaN are skipped, because
+// introducing even a single NaN would result in NaN coefficients regardless of
+// the other rows, which would break the indistinguishability property required
+// for differential privacy.
+func (lr *LinearRegression) Add(x []float64, y float64) {
+	if lr.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The linear regression has already been calculated and returned. It cannot be amended.")
+	}
+	if len(x) != lr.numFeatures {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("LinearRegression.Add: got %d features, want %d", len(x), lr.numFeatures)
+	}
+	if math.IsNaN(y) {
+		return
+	}
+	var norm float64
+	for _, v := range x {
+		if math.IsNaN(v) {
+			return
+		}
+		norm = math.Hypot(norm, v)
+	}
+	scale := 1.0
+	if norm > lr.maxFeatureNorm {
+		scale = lr.maxFeatureNorm / norm
+	}
+	y = math.Min(math.Max(y, -lr.maxLabel), lr.maxLabel)
+
+	k := 0
+	for i, xi := range x {
+		xi *= scale
+		for _, xj := range x[i:] {
+			lr.xtx[k] += xi * xj * scale
+			k++
+		}
+		lr.xty[i] += xi * y
+	}
+}
+
+// Merge merges lr2 into lr (i.e., adds to lr all rows that were added to lr2).
+// lr2 is consumed by this operation: lr2 may not be used after it is merged
+// into lr.
+func (lr *LinearRegression) Merge(lr2 *LinearRegression) {
+	if err := checkMergeLinearRegression(lr, lr2); err != nil {
+		// TODO: do not exit the program from within library code
+		log.Exit(err)
+	}
+	for i, v := range lr2.xtx {
+		lr.xtx[i] += v
+	}
+	for i, v := range lr2.xty {
+		lr.xty[i] += v
+	}
+	lr2.resultReturned = true
+}
+
+func checkMergeLinearRegression(lr1, lr2 *LinearRegression) error {
+	if lr1.resultReturned {
+		return fmt.Errorf("checkMergeLinearRegression: lr1 already returned the result, cannot be merged with another LinearRegression instance")
+	}
+	if lr2.resultReturned {
+		return fmt.Errorf("checkMergeLinearRegression: lr2 already returned the result, cannot be merged with another LinearRegression instance")
+	}
+	if !lrEquallyInitialized(lr1, lr2) {
+		return fmt.Errorf("checkMergeLinearRegression: lr1 and lr2 are not compatible")
+	}
+	return nil
+}
+
+// Result returns the differentially private coefficients β of the model
+// y ≈ β·x, fitted on the rows added so far. It can be called only once, after
+// which no further operation can be done on the LinearRegression.
+func (lr *LinearRegression) Result() []float64 {
+	if lr.resultReturned {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("The linear regression has already been calculated and returned. It can only be returned once.")
+	}
+	lr.resultReturned = true
+	d := lr.numFeatures
+	eps, del := lr.epsilon/2, lr.delta/2
+	// The Gaussian noise only depends on the L2 sensitivity of the released
+	// vector, which is passed as an L_∞ sensitivity with an L_0 sensitivity of 1.
+	xtxSensitivity := float64(lr.maxRowsContributed) * lr.maxFeatureNorm * lr.maxFeatureNorm
+	xtySensitivity := float64(lr.maxRowsContributed) * lr.maxFeatureNorm * lr.maxLabel
+
+	a := mat.NewSymDense(d, nil)
+	k := 0
+	for i := 0; i < d; i++ {
+		for j := i; j < d; j++ {
+			a.SetSym(i, j, lr.noise.AddNoiseFloat64(lr.xtx[k], 1, xtxSensitivity, eps, del))
+			k++
+		}
+	}
+	b := make([]float64, d)
+	for i, v := range lr.xty {
+		b[i] = lr.noise.AddNoiseFloat64(v, 1, xtySensitivity, eps, del)
+	}
+	return solveRegularized(a, b, lr.regularization)
+}
+
+// solveRegularized returns the solution β of (A⁺ + λI)β = b, where A⁺ is the
+// projection of a onto the positive semidefinite matrices. With a = VΛVᵀ, it
+// computes β = V (max(Λ, 0) + λI)⁻¹ Vᵀb.
+func solveRegularized(a *mat.SymDense, b []float64, lambda float64) []float64 {
+	var eig mat.EigenSym
+	if ok := eig.Factorize(a, true); !ok {
+		// TODO: do not exit the program from within library code
+		log.Fatalf("solveRegularized: eigendecomposition of XᵀX failed")
+	}
+	values := eig.Values(nil)
+	var vectors mat.Dense
+	eig.VectorsTo(&vectors)
+
+	var vtb mat.VecDense
+	vtb.MulVec(vectors.T(), mat.NewVecDense(len(b), b))
+	for i, v := range values {
+		vtb.SetVec(i, vtb.AtVec(i)/(math.Max(v, 0)+lambda))
+	}
+	var beta mat.VecDense
+	beta.MulVec(&vectors, &vtb)
+	return mat.Col(nil, 0, &beta)
+}
+
+// encodableLinearRegression can be encoded by the gob package.
+type encodableLinearRegression struct {
+	Epsilon            float64
+	Delta              float64
+	NumFeatures        int
+	MaxRowsContributed int64
+	MaxFeatureNorm     float64
+	MaxLabel           float64
+	Regularization     float64
+	XTX                []float64
+	XTY                []float64
+	ResultReturned     bool
+}
+
+// GobEncode encodes LinearRegression.
+func (lr *LinearRegression) GobEncode() ([]byte, error) {
+	enc := encodableLinearRegression{
+		Epsilon:            lr.epsilon,
+		Delta:              lr.delta,
+		NumFeatures:        lr.numFeatures,
+		MaxRowsContributed: lr.maxRowsContributed,
+		MaxFeatureNorm:     lr.maxFeatureNorm,
+		MaxLabel:           lr.maxLabel,
+		Regularization:     lr.regularization,
+		XTX:                lr.xtx,
+		XTY:                lr.xty,
+		ResultReturned:     lr.resultReturned,
+	}
+	lr.resultReturned = true
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(enc)
+	return buf.Bytes(), err
+}
+
+// GobDecode decodes LinearRegression.
+func (lr *LinearRegression) GobDecode(data []byte) error {
+	var enc encodableLinearRegression
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&enc); err != nil {
+		log.Fatalf("GobDecode: couldn't decode LinearRegression from bytes")
+		return err
+	}
+	*lr = LinearRegression{
+		epsilon:            enc.Epsilon,
+		delta:              enc.Delta,
+		numFeatures:        enc.NumFeatures,
+		maxRowsContributed: enc.MaxRowsContributed,
+		maxFeatureNorm:     enc.MaxFeatureNorm,
+		maxLabel:           enc.MaxLabel,
+		regularization:     enc.Regularization,
+		noise:              noise.Gaussian(),
+		xtx:                enc.XTX,
+		xty:                enc.XTY,
+		resultReturned:     enc.ResultReturned,
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dpml
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"testing"
+
+	"github.com/google/differential-privacy/go/noise"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gonum.org/v1/gonum/mat"
+)
+
+var ln3 = math.Log(3)
+
+// noNoise is a Noise instance that doesn't add noise to the data.
+type noNoise struct {
+	noise.Noise
+}
+
+func (noNoise) AddNoiseFloat64(x float64, _ int64, _, _, _ float64) float64 {
+	return x
+}
+
+// offsetNoise adds a large value to every noised value, which makes the
+// noisy XᵀX indefinite.
+type offsetNoise struct {
+	noise.Noise
+	offset float64
+}
+
+func (n offsetNoise) AddNoiseFloat64(x float64, _ int64, _, _, _ float64) float64 {
+	return x + n.offset
+}
+
+func getNoiselessLinearRegression(numFeatures int, regularization float64) *LinearRegression {
+	lr := NewLinearRegression(&LinearRegressionOptions{
+		Epsilon:        ln3,
+		Delta:          1e-5,
+		NumFeatures:    numFeatures,
+		MaxFeatureNorm: 10,
+		MaxLabel:       100,
+		Regularization: regularization,
+	})
+	lr.noise = noNoise{}
+	return lr
+}
+
+func TestLinearRegressionResult(t *testing.T) {
+	lr := getNoiselessLinearRegression(2, 1e-9)
+	// y = 2*x1 - x2
+	for _, x := range [][]float64{{1, 0}, {0, 1}, {1, 1}, {2, -1}, {-1, 3}} {
+		lr.Add(x, 2*x[0]-x[1])
+	}
+	lr.Add([]float64{math.NaN(), 1}, 1000) // skipped
+	got := lr.Result()
+	want := []float64{2, -1}
+	if !cmp.Equal(got, want, cmpopts.EquateApprox(0, 1e-6)) {
+		t.Errorf("Result: got %v, want %v", got, want)
+	}
+}
+
+func TestLinearRegressionClipsRows(t *testing.T) {
+	lr := NewLinearRegression(&LinearRegressionOptions{
+		Epsilon:        ln3,
+		Delta:          1e-5,
+		NumFeatures:    2,
+		MaxFeatureNorm: 1,
+		MaxLabel:       2,
+		Regularization: 1,
+	})
+	lr.noise = noNoise{}
+	// x is scaled down to (0.6, 0.8) and y is clamped to 2.
+	lr.Add([]float64{3, 4}, 10)
+	wantXTX := []float64{0.36, 0.48, 0.64}
+	wantXTY := []float64{1.2, 1.6}
+	if !cmp.Equal(lr.xtx, wantXTX, cmpopts.EquateApprox(0, 1e-10)) {
+		t.Errorf("Add: got XᵀX %v, want %v", lr.xtx, wantXTX)
+	}
+	if !cmp.Equal(lr.xty, wantXTY, cmpopts.EquateApprox(0, 1e-10)) {
+		t.Errorf("Add: got Xᵀy %v, want %v", lr.xty, wantXTY)
+	}
+}
+
+func TestSolveRegularizedIsPositiveDefinite(t *testing.T) {
+	lr := getNoiselessLinearRegression(2, 1)
+	lr.noise = offsetNoise{offset: -100}
+	lr.Add([]float64{1, 0}, 1)
+	for _, c := range lr.Result() {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			t.Fatalf("Result: got non-finite coefficient %f with an indefinite noisy XᵀX", c)
+		}
+	}
+	// With A = [[-1, 0], [0, -2]], the projection of A is 0, so β = b/λ.
+	a := mat.NewSymDense(2, []float64{-1, 0, 0, -2})
+	got := solveRegularized(a, []float64{3, 4}, 2)
+	want := []float64{1.5, 2}
+	if !cmp.Equal(got, want, cmpopts.EquateApprox(0, 1e-10)) {
+		t.Errorf("solveRegularized: got %v, want %v", got, want)
+	}
+}
+
+func TestLinearRegressionMerge(t *testing.T) {
+	lr1 := getNoiselessLinearRegression(1, 1e-9)
+	lr2 := getNoiselessLinearRegression(1, 1e-9)
+	lr1.Add([]float64{1}, 3)
+	lr2.Add([]float64{2}, 6)
+	lr1.Merge(lr2)
+	got := lr1.Result()
+	if !cmp.Equal(got, []float64{3}, cmpopts.EquateApprox(0, 1e-6)) {
+		t.Errorf("Merge: got coefficients %v, want [3]", got)
+	}
+	if !lr2.resultReturned {
+		t.Errorf("Merge: lr2 should be marked as consumed")
+	}
+}
+
+func TestCheckMergeLinearRegression(t *testing.T) {
+	opt := LinearRegressionOptions{
+		Epsilon:        ln3,
+		Delta:          1e-5,
+		NumFeatures:    3,
+		MaxFeatureNorm: 1,
+		MaxLabel:       1,
+		Regularization: 1,
+	}
+	for _, tc := range []struct {
+		desc    string
+		modify  func(*LinearRegressionOptions)
+		state1  bool
+		state2  bool
+		wantErr bool
+	}{
+		{"same options", func(*LinearRegressionOptions) {}, false, false, false},
+		{"lr1 returned its result", func(*LinearRegressionOptions) {}, true, false, true},
+		{"lr2 returned its result", func(*LinearRegressionOptions) {}, false, true, true},
+		{"different number of features", func(o *LinearRegressionOptions) { o.NumFeatures = 2 }, false, false, true},
+		{"different clipping", func(o *LinearRegressionOptions) { o.MaxFeatureNorm = 2 }, false, false, true},
+		{"different regularization", func(o *LinearRegressionOptions) { o.Regularization = 2 }, false, false, true},
+	} {
+		opt2 := opt
+		tc.modify(&opt2)
+		lr1, lr2 := NewLinearRegression(&opt), NewLinearRegression(&opt2)
+		lr1.resultReturned = tc.state1
+		lr2.resultReturned = tc.state2
+		if err := checkMergeLinearRegression(lr1, lr2); (err != nil) != tc.wantErr {
+			t.Errorf("CheckMerge: when %s for err got %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLinearRegressionSerialization(t *testing.T) {
+	opt := &LinearRegressionOptions{
+		Epsilon:            ln3,
+		Delta:              1e-5,
+		NumFeatures:        2,
+		MaxRowsContributed: 3,
+		MaxFeatureNorm:     1,
+		MaxLabel:           5,
+		Regularization:     0.5,
+	}
+	lr, lrUnchanged := NewLinearRegression(opt), NewLinearRegression(opt)
+	lr.Add([]float64{0.1, 0.2}, 1)
+	lrUnchanged.Add([]float64{0.1, 0.2}, 1)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(lr); err != nil {
+		t.Fatalf("encode(LinearRegression) error: %v", err)
+	}
+	lrUnmarshalled := new(LinearRegression)
+	if err := gob.NewDecoder(&buf).Decode(lrUnmarshalled); err != nil {
+		t.Fatalf("decode(LinearRegression) error: %v", err)
+	}
+	// Check that encoding -> decoding is the identity function.
+	if !cmp.Equal(lrUnchanged, lrUnmarshalled, cmp.AllowUnexported(LinearRegression{})) {
+		t.Errorf("decode(encode(_)): got %+v, want %+v", lrUnmarshalled, lrUnchanged)
+	}
+	// Check that the original LinearRegression has its resultReturned set to true after serialization.
+	if !lr.resultReturned {
+		t.Errorf("LinearRegression %v should have its resultReturned set to true after being serialized", lr)
+	}
+}
@@ -17,6 +17,7 @@
 package noise
 
 import (
+	"fmt"
 	"math"
 
 	log "github.com/golang/glog"
@@ -113,7 +114,55 @@ func (gaussian) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon,
 	return 1 - math.Pow(noiseDist.CDF(threshold-lInfSensitivity), float64(l0Sensitivity))
 }
 
+// ComputeConfidenceIntervalInt64 computes a confidence interval that contains the raw integer value x from which int64 noisedX
+// is computed with a probability greater or equal to 1 - alpha based on the specified gaussian noise parameters.
+func (gaussian) ComputeConfidenceIntervalInt64(noisedX, l0Sensitivity, lInfSensitivity int64, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsConfidenceIntervalGaussian("ComputeConfidenceIntervalInt64 (Gaussian)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta, alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	sigma := sigmaForGaussian(l0Sensitivity, float64(lInfSensitivity), epsilon, delta)
+	return computeConfidenceIntervalGaussian(float64(noisedX), sigma, alpha).roundToInt64(), nil
+}
+
+// ComputeConfidenceIntervalFloat64 computes a confidence interval that contains the raw value x from which float64
+// noisedX is computed with a probability equal to 1 - alpha based on the specified gaussian noise parameters.
+func (gaussian) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsConfidenceIntervalGaussian("ComputeConfidenceIntervalFloat64 (Gaussian)", l0Sensitivity, lInfSensitivity, epsilon, delta, alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	sigma := sigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta)
+	return computeConfidenceIntervalGaussian(noisedX, sigma, alpha), nil
+}
+
+// computeConfidenceIntervalGaussian returns the symmetric confidence interval
+// around noisedX for Gaussian noise of standard deviation σ, i.e.,
+// noisedX ± σ·Φ⁻¹(1 - α/2) where Φ is the standard normal CDF.
+func computeConfidenceIntervalGaussian(noisedX, sigma, alpha float64) ConfidenceInterval {
+	z := sigma * distuv.UnitNormal.Quantile(1-alpha/2)
+	return ConfidenceInterval{
+		LowerBound:      noisedX - z,
+		UpperBound:      noisedX + z,
+		ConfidenceLevel: 1 - alpha,
+	}
+}
+
+func checkArgsConfidenceIntervalGaussian(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) error {
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return err
+	}
+	return checkArgsGaussian(label, l0Sensitivity, lInfSensitivity, epsilon, delta)
+}
+
+// checkArgsGaussian returns an error if the parameters of Gaussian noise are
+// invalid. The error wraps the *checks.ParameterError of the invalid parameter.
 func checkArgsGaussian(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
+	if err := checkGaussianParameters(label, l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return fmt.Errorf("invalid Gaussian noise parameters: %w", err)
+	}
+	return nil
+}
+
+func checkGaussianParameters(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
 	if err := checks.CheckL0Sensitivity(label, l0Sensitivity); err != nil {
 		return err
 	}
@@ -215,6 +264,15 @@ func deltaForGaussian(sigma float64, l0Sensitivity int64, lInfSensitivity, epsil
 	return distuv.UnitNormal.CDF(a-b) - c*distuv.UnitNormal.CDF(-a-b)
 }
 
+// SigmaForGaussian returns the standard deviation σ of the noise added by
+// Gaussian() with the given parameters, or an error if they are invalid.
+func SigmaForGaussian(l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) (float64, error) {
+	if err := checkArgsGaussian("SigmaForGaussian", l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return 0, err
+	}
+	return sigmaForGaussian(l0Sensitivity, lInfSensitivity, epsilon, delta), nil
+}
+
 // sigmaForGaussian calculates the standard deviation σ of Gaussian noise
 // needed to achieve (ε,δ)-approximate differential privacy.
 //
@@ -351,6 +351,20 @@ func TestSigmaForGaussianWithDeltaOf1(t *testing.T) {
 	}
 }
 
+func TestExportedSigmaForGaussian(t *testing.T) {
+	// This delta gives a sigma of 1 for these parameters.
+	got, err := SigmaForGaussian(1, 1, ln3, 0.10985556344445052)
+	if err != nil {
+		t.Fatalf("SigmaForGaussian: got error %v", err)
+	}
+	if math.Abs(got-1) > 1e-3 {
+		t.Errorf("SigmaForGaussian: got %f, want 1", got)
+	}
+	if _, err := SigmaForGaussian(1, 1, ln3, 0); err == nil {
+		t.Errorf("SigmaForGaussian: with delta 0 got no error")
+	}
+}
+
 var thresholdGaussianTestCases = []struct {
 	desc            string
 	l0Sensitivity   int64
@@ -437,3 +451,39 @@ func TestDeltaForThresholdGaussian(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeConfidenceIntervalGaussian(t *testing.T) {
+	// 0.045500263896 is the 2-sided tail probability of landing more than 2
+	// standard deviations from the mean of a Gaussian distribution.
+	const alpha = 0.045500263896
+	for _, tc := range thresholdGaussianTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			sigma := sigmaForGaussian(tc.l0Sensitivity, tc.lInfSensitivity, tc.epsilon, tc.deltaNoise)
+			got, err := gaussCI.ComputeConfidenceIntervalFloat64(5, tc.l0Sensitivity, tc.lInfSensitivity, tc.epsilon, tc.deltaNoise, alpha)
+			if err != nil {
+				t.Fatalf("ComputeConfidenceIntervalFloat64: got err %v", err)
+			}
+			if !nearEqual(got.LowerBound, 5-2*sigma, 1e-6) || !nearEqual(got.UpperBound, 5+2*sigma, 1e-6) {
+				t.Errorf("ComputeConfidenceIntervalFloat64: got %+v, want [%f, %f]", got, 5-2*sigma, 5+2*sigma)
+			}
+			if tc.lInfSensitivity != math.Trunc(tc.lInfSensitivity) {
+				return
+			}
+			gotInt, err := gaussCI.ComputeConfidenceIntervalInt64(5, tc.l0Sensitivity, int64(tc.lInfSensitivity), tc.epsilon, tc.deltaNoise, alpha)
+			if err != nil {
+				t.Fatalf("ComputeConfidenceIntervalInt64: got err %v", err)
+			}
+			if gotInt.LowerBound != math.Floor(got.LowerBound) || gotInt.UpperBound != math.Ceil(got.UpperBound) {
+				t.Errorf("ComputeConfidenceIntervalInt64: got %+v, want [%f, %f]", gotInt, math.Floor(got.LowerBound), math.Ceil(got.UpperBound))
+			}
+		})
+	}
+}
+
+func TestComputeConfidenceIntervalGaussianInvalidAlpha(t *testing.T) {
+	for _, alpha := range []float64{-1, 0, 1, 2, math.NaN()} {
+		if _, err := gaussCI.ComputeConfidenceIntervalFloat64(0, 1, 1, ln3, 1e-5, alpha); err == nil {
+			t.Errorf("ComputeConfidenceIntervalFloat64: with alpha %f got no error", alpha)
+		}
+	}
+}
@@ -17,6 +17,7 @@
 package noise
 
 import (
+	"fmt"
 	"math"
 
 	log "github.com/golang/glog"
@@ -157,7 +158,55 @@ func (laplace) DeltaForThreshold(l0Sensitivity int64, lInfSensitivity, epsilon,
 	return 1 - math.Pow(1-partitionDelta, float64(l0Sensitivity))
 }
 
+// ComputeConfidenceIntervalInt64 computes a confidence interval that contains the raw integer value x from which int64 noisedX
+// is computed with a probability greater or equal to 1 - alpha based on the specified laplace noise parameters.
+func (laplace) ComputeConfidenceIntervalInt64(noisedX, l0Sensitivity, lInfSensitivity int64, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsConfidenceIntervalLaplace("ComputeConfidenceIntervalInt64 (Laplace)", l0Sensitivity, float64(lInfSensitivity), epsilon, delta, alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	lambda := laplaceLambda(l0Sensitivity, float64(lInfSensitivity), epsilon)
+	return computeConfidenceIntervalLaplace(float64(noisedX), lambda, alpha).roundToInt64(), nil
+}
+
+// ComputeConfidenceIntervalFloat64 computes a confidence interval that contains the raw value x from which float64
+// noisedX is computed with a probability equal to 1 - alpha based on the specified laplace noise parameters.
+func (laplace) ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error) {
+	if err := checkArgsConfidenceIntervalLaplace("ComputeConfidenceIntervalFloat64 (Laplace)", l0Sensitivity, lInfSensitivity, epsilon, delta, alpha); err != nil {
+		return ConfidenceInterval{}, err
+	}
+	lambda := laplaceLambda(l0Sensitivity, lInfSensitivity, epsilon)
+	return computeConfidenceIntervalLaplace(noisedX, lambda, alpha), nil
+}
+
+// computeConfidenceIntervalLaplace returns the symmetric confidence interval
+// around noisedX for Laplace noise of scale λ. Since the Laplace distribution
+// satisfies Pr[|X| > z] = exp(-z/λ), the interval half-width is z = -λ·log(α).
+func computeConfidenceIntervalLaplace(noisedX, lambda, alpha float64) ConfidenceInterval {
+	z := -lambda * math.Log(alpha)
+	return ConfidenceInterval{
+		LowerBound:      noisedX - z,
+		UpperBound:      noisedX + z,
+		ConfidenceLevel: 1 - alpha,
+	}
+}
+
+func checkArgsConfidenceIntervalLaplace(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) error {
+	if err := checks.CheckAlpha(label, alpha); err != nil {
+		return err
+	}
+	return checkArgsLaplace(label, l0Sensitivity, lInfSensitivity, epsilon, delta)
+}
+
+// checkArgsLaplace returns an error if the parameters of Laplace noise are
+// invalid. The error wraps the *checks.ParameterError of the invalid parameter.
 func checkArgsLaplace(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
+	if err := checkLaplaceParameters(label, l0Sensitivity, lInfSensitivity, epsilon, delta); err != nil {
+		return fmt.Errorf("invalid Laplace noise parameters: %w", err)
+	}
+	return nil
+}
+
+func checkLaplaceParameters(label string, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
 	if err := checks.CheckL0Sensitivity(label, l0Sensitivity); err != nil {
 		return err
 	}
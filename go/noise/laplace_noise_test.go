@@ -214,3 +214,61 @@ func TestGeometricStatistics(t *testing.T) {
 		}
 	}
 }
+
+func TestComputeConfidenceIntervalLaplace(t *testing.T) {
+	for _, tc := range []struct {
+		desc                       string
+		noisedX                    float64
+		l0Sensitivity              int64
+		lInfSensitivity, eps       float64
+		alpha                      float64
+		wantLower, wantUpper       float64
+		wantLowerInt, wantUpperInt float64
+	}{
+		// The half-width of the interval is -log(alpha)*l0Sensitivity*lInfSensitivity/epsilon.
+		{"base case", 0, 1, 1, ln3, 1.0 / 3.0, -1, 1, -1, 1},
+		{"shifted", 10, 1, 1, ln3, 1.0 / 3.0, 9, 11, 9, 11},
+		{"small alpha", 0, 1, 1, ln3, 1.0 / 9.0, -2, 2, -2, 2},
+		{"scale l0Sensitivity", 0, 2, 1, ln3, 1.0 / 3.0, -2, 2, -2, 2},
+		{"scale lInfSensitivity", 0, 1, 3, ln3, 1.0 / 3.0, -3, 3, -3, 3},
+		{"scale epsilon", 0, 1, 1, 2 * ln3, 1.0 / 3.0, -0.5, 0.5, -1, 1},
+	} {
+		got, err := lapCI.ComputeConfidenceIntervalFloat64(tc.noisedX, tc.l0Sensitivity, tc.lInfSensitivity, tc.eps, 0, tc.alpha)
+		if err != nil {
+			t.Fatalf("ComputeConfidenceIntervalFloat64: when %s got err %v", tc.desc, err)
+		}
+		if !nearEqual(got.LowerBound, tc.wantLower, 1e-10) || !nearEqual(got.UpperBound, tc.wantUpper, 1e-10) {
+			t.Errorf("ComputeConfidenceIntervalFloat64: when %s got %+v, want [%f, %f]", tc.desc, got, tc.wantLower, tc.wantUpper)
+		}
+		if !nearEqual(got.ConfidenceLevel, 1-tc.alpha, 1e-10) {
+			t.Errorf("ComputeConfidenceIntervalFloat64: when %s got confidence level %f, want %f", tc.desc, got.ConfidenceLevel, 1-tc.alpha)
+		}
+		if tc.lInfSensitivity != math.Trunc(tc.lInfSensitivity) {
+			continue
+		}
+		gotInt, err := lapCI.ComputeConfidenceIntervalInt64(int64(tc.noisedX), tc.l0Sensitivity, int64(tc.lInfSensitivity), tc.eps, 0, tc.alpha)
+		if err != nil {
+			t.Fatalf("ComputeConfidenceIntervalInt64: when %s got err %v", tc.desc, err)
+		}
+		// Integer intervals are rounded outwards, so a small slack is needed
+		// for bounds that are integers up to floating-point errors.
+		if !nearEqual(gotInt.LowerBound, tc.wantLowerInt, 1+1e-10) || gotInt.LowerBound > tc.wantLowerInt ||
+			!nearEqual(gotInt.UpperBound, tc.wantUpperInt, 1+1e-10) || gotInt.UpperBound < tc.wantUpperInt {
+			t.Errorf("ComputeConfidenceIntervalInt64: when %s got %+v, want [%f, %f]", tc.desc, gotInt, tc.wantLowerInt, tc.wantUpperInt)
+		}
+		if gotInt.LowerBound != math.Trunc(gotInt.LowerBound) || gotInt.UpperBound != math.Trunc(gotInt.UpperBound) {
+			t.Errorf("ComputeConfidenceIntervalInt64: when %s got non-integer bounds %+v", tc.desc, gotInt)
+		}
+	}
+}
+
+func TestComputeConfidenceIntervalLaplaceInvalidAlpha(t *testing.T) {
+	for _, alpha := range []float64{-1, 0, 1, 2, math.NaN()} {
+		if _, err := lapCI.ComputeConfidenceIntervalFloat64(0, 1, 1, ln3, 0, alpha); err == nil {
+			t.Errorf("ComputeConfidenceIntervalFloat64: with alpha %f got no error", alpha)
+		}
+		if _, err := lapCI.ComputeConfidenceIntervalInt64(0, 1, 1, ln3, 0, alpha); err == nil {
+			t.Errorf("ComputeConfidenceIntervalInt64: with alpha %f got no error", alpha)
+		}
+	}
+}
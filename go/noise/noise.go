@@ -18,6 +18,8 @@
 package noise
 
 import (
+	"math"
+
 	log "github.com/golang/glog"
 )
 
@@ -57,6 +59,20 @@ func ToKind(n Noise) Kind {
 	return GaussianNoise
 }
 
+// CheckParameters returns an error if adding noise of type n with the given
+// parameters would fail, without adding any noise. It returns nil for noise
+// types other than Gaussian() and Laplace(), whose parameters it cannot check:
+// for custom noise, a nil error doesn't guarantee that adding noise succeeds.
+func CheckParameters(label string, n Noise, l0Sensitivity int64, lInfSensitivity, epsilon, delta float64) error {
+	switch n {
+	case Gaussian():
+		return checkArgsGaussian(label, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	case Laplace():
+		return checkArgsLaplace(label, l0Sensitivity, lInfSensitivity, epsilon, delta)
+	}
+	return nil
+}
+
 // Noise is an interface for primitives that add noise to data to make it differentially private.
 type Noise interface {
 	// AddNoiseInt64 noise to the specified int64 x so that the output is ε-differentially
@@ -101,3 +117,39 @@ type Noise interface {
 	// given assumptions of L_0 and L_∞ sensitivities.
 	Threshold(l0Sensitivity int64, lInfSensitivity, epsilon, deltaNoise, deltaThreshold float64) float64
 }
+
+// ConfidenceIntervalComputer is implemented by the Noise instances that can
+// compute confidence intervals for the values they noised, like the Laplace
+// and Gaussian noise of this package. Custom Noise implementations don't need
+// to implement it, but aggregations can't return confidence intervals for them.
+type ConfidenceIntervalComputer interface {
+	// ComputeConfidenceIntervalInt64 computes a confidence interval that contains the raw integer
+	// value x from which int64 noisedX is computed with a probability greater or equal to 1 - alpha,
+	// based on the specified noise parameters.
+	ComputeConfidenceIntervalInt64(noisedX, l0Sensitivity, lInfSensitivity int64, epsilon, delta, alpha float64) (ConfidenceInterval, error)
+
+	// ComputeConfidenceIntervalFloat64 computes a confidence interval that contains the raw value x
+	// from which float64 noisedX is computed with a probability equal to 1 - alpha, based on the
+	// specified noise parameters.
+	ComputeConfidenceIntervalFloat64(noisedX float64, l0Sensitivity int64, lInfSensitivity, epsilon, delta, alpha float64) (ConfidenceInterval, error)
+}
+
+// ConfidenceInterval holds lower and upper bounds as float64 for the confidence
+// interval, as well as the confidence level 1 - alpha it was computed for. It
+// mirrors the ConfidenceInterval message in proto/confidence-interval.proto.
+type ConfidenceInterval struct {
+	LowerBound, UpperBound float64
+	// The confidence level of the interval. For a 95% confidence interval,
+	// this value is 0.95.
+	ConfidenceLevel float64
+}
+
+// roundToInt64 rounds the bounds of a confidence interval outwards to the
+// nearest integers, so that the rounded interval contains the original one.
+func (ci ConfidenceInterval) roundToInt64() ConfidenceInterval {
+	return ConfidenceInterval{
+		LowerBound:      math.Floor(ci.LowerBound),
+		UpperBound:      math.Ceil(ci.UpperBound),
+		ConfidenceLevel: ci.ConfidenceLevel,
+	}
+}
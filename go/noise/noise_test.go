@@ -17,8 +17,11 @@
 package noise
 
 import (
+	"errors"
 	"math"
 	"testing"
+
+	"github.com/google/differential-privacy/go/checks"
 )
 
 var (
@@ -27,6 +30,9 @@ var (
 
 	lap   = Laplace()
 	gauss = Gaussian()
+
+	lapCI   = lap.(ConfidenceIntervalComputer)
+	gaussCI = gauss.(ConfidenceIntervalComputer)
 )
 
 func nearEqual(a, b, maxError float64) bool {
@@ -42,3 +48,46 @@ func BenchmarkLaplaceFloat64(b *testing.B) {
 	}
 	benchResultFloat64 = r
 }
+
+func TestCheckParameters(t *testing.T) {
+	for _, tc := range []struct {
+		desc             string
+		n                Noise
+		l0               int64
+		lInf, eps, delta float64
+		wantErr          bool
+	}{
+		{"valid Laplace", lap, 1, 1, ln3, 0, false},
+		{"Laplace with delta", lap, 1, 1, ln3, 1e-5, true},
+		{"Laplace with zero epsilon", lap, 1, 1, 0, 0, true},
+		{"valid Gaussian", gauss, 1, 1, ln3, 1e-5, false},
+		{"Gaussian without delta", gauss, 1, 1, ln3, 0, true},
+		{"zero l0 sensitivity", gauss, 0, 1, ln3, 1e-5, true},
+		{"infinite lInf sensitivity", lap, 1, math.Inf(1), ln3, 0, true},
+	} {
+		if err := CheckParameters("test", tc.n, tc.l0, tc.lInf, tc.eps, tc.delta); (err != nil) != tc.wantErr {
+			t.Errorf("CheckParameters: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNoiseErrorsWrapParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		err           error
+		wantParameter string
+	}{
+		{"CheckParameters with Laplace noise", CheckParameters("test", lap, 1, 1, ln3, 1e-5), "Delta"},
+		{"CheckParameters with Gaussian noise", CheckParameters("test", gauss, 0, 1, ln3, 1e-5), "L0Sensitivity"},
+		{"SigmaForGaussian", func() error { _, err := SigmaForGaussian(1, 1, -1, 1e-5); return err }(), "Epsilon"},
+	} {
+		var pe *checks.ParameterError
+		if !errors.As(tc.err, &pe) {
+			t.Errorf("%s: got err %v, want a *checks.ParameterError", tc.desc, tc.err)
+			continue
+		}
+		if pe.Parameter != tc.wantParameter {
+			t.Errorf("%s: got Parameter %q, want %q", tc.desc, pe.Parameter, tc.wantParameter)
+		}
+	}
+}
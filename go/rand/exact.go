@@ -0,0 +1,221 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rand
+
+import (
+	"math"
+	"math/bits"
+
+	log "github.com/golang/glog"
+)
+
+// The samplers of this file are exact: they only consume uniformly random
+// bits, and use integer arithmetic or exact floating-point operations, so that
+// their output distributions are exactly the ones documented, without
+// rounding errors. Most of them follow Canonne, Kamath and Steinke, "The
+// Discrete Gaussian for Differential Privacy".
+//
+// Rational parameters are given as a numerator and a denominator; the
+// probabilities are never computed explicitly.
+
+// BernoulliRational returns true with probability num/den. It requires
+// 0 ≤ num ≤ den and den > 0.
+func BernoulliRational(num, den int64) bool {
+	if den <= 0 || num < 0 || num > den {
+		log.Fatalf("BernoulliRational(%d, %d): the probability should be in [0, 1] with a strictly positive denominator", num, den)
+	}
+	return I63n(den) < num
+}
+
+// BernoulliFloat64 returns true with probability p, which must be in [0, 1].
+//
+// It compares the binary expansion of p with that of a uniformly random real
+// number in [0, 1), generated one bit at a time, so it consumes 2 random bits
+// in expectation.
+func BernoulliFloat64(p float64) bool {
+	if math.IsNaN(p) || p < 0 || p > 1 {
+		log.Fatalf("BernoulliFloat64(%f): the probability should be in [0, 1]", p)
+	}
+	if p == 1 {
+		return true
+	}
+	if p == 0 {
+		return false
+	}
+	// p = mantissa · 2^(exp-53), with mantissa < 2^53 and exp ≤ 0, so the
+	// binary expansion of p starts with -exp zeros followed by the 53 bits of
+	// the mantissa.
+	frac, exp := math.Frexp(p)
+	mantissa := uint64(math.Ldexp(frac, 53))
+	for i := 0; i < -exp; i++ {
+		if Boolean() {
+			// The uniform number has a 1 where p has a 0, so it is larger.
+			return false
+		}
+	}
+	for i := 52; i >= 0; i-- {
+		pBit := mantissa>>uint(i)&1 == 1
+		if uBit := Boolean(); uBit != pBit {
+			// The first differing bit decides whether the uniform number is smaller.
+			return pBit
+		}
+	}
+	// The uniform number is larger than or equal to p.
+	return false
+}
+
+// BernoulliExp returns true with probability exp(-num/den). It requires
+// num ≥ 0 and den > 0.
+//
+// Its running time is linear in num/den.
+func BernoulliExp(num, den int64) bool {
+	if den <= 0 || num < 0 {
+		log.Fatalf("BernoulliExp(%d, %d): the exponent should be nonnegative with a strictly positive denominator", num, den)
+	}
+	// exp(-γ) = exp(-1)^⌊γ⌋ · exp(-(γ-⌊γ⌋)).
+	for i := int64(0); i < num/den; i++ {
+		if !bernoulliExpAtMostOne(1, 1) {
+			return false
+		}
+	}
+	return bernoulliExpAtMostOne(num%den, den)
+}
+
+// bernoulliExpAtMostOne returns true with probability exp(-γ), where
+// γ = num/den is in [0, 1]. It draws Bernoulli(γ/k) samples for k = 1, 2, …
+// until one of them is false, and returns whether it happened at an odd k. The
+// first false sample is at k with probability γ^(k-1)/(k-1)! - γ^k/k!, and
+// summing over odd k gives exp(-γ).
+func bernoulliExpAtMostOne(num, den int64) bool {
+	k := int64(1)
+	// Bernoulli(γ/k) is the conjunction of Bernoulli(γ) and Bernoulli(1/k),
+	// which avoids overflowing den·k.
+	for BernoulliRational(num, den) && BernoulliRational(1, k) {
+		k++
+	}
+	return k%2 == 1
+}
+
+// DiscreteLaplace returns an integer x with probability proportional to
+// exp(-|x|/t), where the scale t = scaleNum/scaleDen must be strictly
+// positive.
+//
+// Its expected running time is constant.
+func DiscreteLaplace(scaleNum, scaleDen int64) int64 {
+	if scaleNum <= 0 || scaleDen <= 0 {
+		log.Fatalf("DiscreteLaplace(%d, %d): the scale should be strictly positive", scaleNum, scaleDen)
+	}
+	t, s := scaleNum, scaleDen
+	for {
+		// Sample the magnitude of a geometric of parameter exp(-1/t), as
+		// U + t·V, where U is uniform in {0, …, t-1} accepted with probability
+		// exp(-U/t), and V is a geometric of parameter exp(-1).
+		u := I63n(t)
+		if !BernoulliExp(u, t) {
+			continue
+		}
+		v := int64(0)
+		for BernoulliExp(1, 1) {
+			v++
+		}
+		// Dividing by s turns it into a geometric of parameter exp(-s/t).
+		y := (u + t*v) / s
+		negative := Boolean()
+		if negative && y == 0 {
+			// Reject -0, so that 0 is not sampled twice as often.
+			continue
+		}
+		if negative {
+			return -y
+		}
+		return y
+	}
+}
+
+// Binomial returns the number of successes among n independent Bernoulli
+// trials with success probability p. It requires n ≥ 0 and p in [0, 1].
+//
+// Its running time is linear in n.
+func Binomial(n int64, p float64) int64 {
+	if n < 0 {
+		log.Fatalf("Binomial(%d, %f): the number of trials should be nonnegative", n, p)
+	}
+	var successes int64
+	if p == 0.5 {
+		// Each random bit is a trial.
+		for ; n >= 64; n -= 64 {
+			successes += int64(bits.OnesCount64(U64()))
+		}
+		if n > 0 {
+			successes += int64(bits.OnesCount64(U64() >> uint(64-n)))
+		}
+		return successes
+	}
+	for i := int64(0); i < n; i++ {
+		if BernoulliFloat64(p) {
+			successes++
+		}
+	}
+	return successes
+}
+
+// Poisson returns a Poisson random variable with mean λ = num/den. It
+// requires num ≥ 0 and den > 0.
+//
+// Its expected running time is linear in λ.
+func Poisson(num, den int64) int64 {
+	if den <= 0 || num < 0 {
+		log.Fatalf("Poisson(%d, %d): the mean should be nonnegative with a strictly positive denominator", num, den)
+	}
+	// The sum of independent Poisson variables is a Poisson variable whose mean
+	// is the sum of their means, and keeping each of the points of a
+	// Poisson(1) variable with probability r gives a Poisson(r) variable.
+	var k int64
+	for i := int64(0); i < num/den; i++ {
+		k += poissonOne()
+	}
+	if r := num % den; r > 0 {
+		for n := poissonOne(); n > 0; n-- {
+			if BernoulliRational(r, den) {
+				k++
+			}
+		}
+	}
+	return k
+}
+
+// poissonOne returns a Poisson random variable with mean 1, i.e., k with
+// probability exp(-1)/k!.
+//
+// It proposes k with probability 2^-(k+1), and accepts it with probability
+// 2^(k-1)/k!, which is at most 1: 1/2 for k = 0, and Π_{j=2..k} 2/j
+// otherwise. The acceptance probability of each proposal is e/4.
+func poissonOne() int64 {
+	for {
+		k := int64(0)
+		for Boolean() {
+			k++
+		}
+		accepted := k > 0 || Boolean()
+		for j := int64(2); j <= k && accepted; j++ {
+			accepted = BernoulliRational(2, j)
+		}
+		if accepted {
+			return k
+		}
+	}
+}
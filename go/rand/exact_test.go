@@ -0,0 +1,157 @@
+//
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package rand
+
+import (
+	"math"
+	"testing"
+)
+
+// The tests of this file compare empirical frequencies and means with their
+// expected values. With numSamples samples, the tolerances are at least 5
+// standard deviations, so the tests are flaky with negligible probability.
+const numSamples = 20000
+
+func frequency(sample func() bool) float64 {
+	var n int
+	for i := 0; i < numSamples; i++ {
+		if sample() {
+			n++
+		}
+	}
+	return float64(n) / numSamples
+}
+
+func TestBernoulli(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		sample func() bool
+		want   float64
+	}{
+		{"BernoulliRational(0, 3)", func() bool { return BernoulliRational(0, 3) }, 0},
+		{"BernoulliRational(1, 3)", func() bool { return BernoulliRational(1, 3) }, 1.0 / 3},
+		{"BernoulliRational(3, 3)", func() bool { return BernoulliRational(3, 3) }, 1},
+		{"BernoulliFloat64(0)", func() bool { return BernoulliFloat64(0) }, 0},
+		{"BernoulliFloat64(0.3)", func() bool { return BernoulliFloat64(0.3) }, 0.3},
+		{"BernoulliFloat64(0.01)", func() bool { return BernoulliFloat64(0.01) }, 0.01},
+		{"BernoulliFloat64(1)", func() bool { return BernoulliFloat64(1) }, 1},
+		{"BernoulliExp(0, 1)", func() bool { return BernoulliExp(0, 1) }, 1},
+		{"BernoulliExp(1, 2)", func() bool { return BernoulliExp(1, 2) }, math.Exp(-0.5)},
+		{"BernoulliExp(1, 1)", func() bool { return BernoulliExp(1, 1) }, math.Exp(-1)},
+		{"BernoulliExp(5, 2)", func() bool { return BernoulliExp(5, 2) }, math.Exp(-2.5)},
+	} {
+		// The tolerance is 5 standard deviations of the frequency, and 0 for
+		// deterministic samplers.
+		tolerance := 5 * math.Sqrt(tc.want*(1-tc.want)/numSamples)
+		if got := frequency(tc.sample); math.Abs(got-tc.want) > tolerance {
+			t.Errorf("%s: got frequency %f, want %f ± %f", tc.desc, got, tc.want, tolerance)
+		}
+	}
+}
+
+func TestDiscreteLaplace(t *testing.T) {
+	for _, tc := range []struct {
+		scaleNum, scaleDen int64
+	}{
+		{1, 1},
+		{3, 2},
+		{1, 3},
+	} {
+		scale := float64(tc.scaleNum) / float64(tc.scaleDen)
+		q := math.Exp(-1 / scale)
+		// P(0) = (1-q)/(1+q) and P(|x| = 1) = 2q(1-q)/(1+q).
+		wantZero, wantOne := (1-q)/(1+q), 2*q*(1-q)/(1+q)
+		var zero, one, sum int
+		for i := 0; i < numSamples; i++ {
+			x := DiscreteLaplace(tc.scaleNum, tc.scaleDen)
+			switch x {
+			case 0:
+				zero++
+			case 1, -1:
+				one++
+			}
+			sum += int(x)
+		}
+		if got := float64(zero) / numSamples; math.Abs(got-wantZero) > 0.02 {
+			t.Errorf("DiscreteLaplace(%d, %d): got frequency %f for 0, want %f", tc.scaleNum, tc.scaleDen, got, wantZero)
+		}
+		if got := float64(one) / numSamples; math.Abs(got-wantOne) > 0.02 {
+			t.Errorf("DiscreteLaplace(%d, %d): got frequency %f for ±1, want %f", tc.scaleNum, tc.scaleDen, got, wantOne)
+		}
+		// The standard deviation of the mean is at most 0.02 for these scales.
+		if got := float64(sum) / numSamples; math.Abs(got) > 0.1 {
+			t.Errorf("DiscreteLaplace(%d, %d): got mean %f, want 0", tc.scaleNum, tc.scaleDen, got)
+		}
+	}
+}
+
+func TestBinomial(t *testing.T) {
+	for _, tc := range []struct {
+		n int64
+		p float64
+	}{
+		{0, 0.3},
+		{10, 0},
+		{10, 1},
+		{10, 0.3},
+		{100, 0.5},
+		{70, 0.5},
+	} {
+		var sum int64
+		for i := 0; i < numSamples/10; i++ {
+			x := Binomial(tc.n, tc.p)
+			if x < 0 || x > tc.n {
+				t.Fatalf("Binomial(%d, %f): got %d, want a value in [0, %d]", tc.n, tc.p, x, tc.n)
+			}
+			sum += x
+		}
+		// The standard deviation of the mean is at most 0.23.
+		want := float64(tc.n) * tc.p
+		if got := float64(sum) / (numSamples / 10); math.Abs(got-want) > 1.2 {
+			t.Errorf("Binomial(%d, %f): got mean %f, want %f", tc.n, tc.p, got, want)
+		}
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	for _, tc := range []struct {
+		num, den int64
+	}{
+		{0, 1},
+		{1, 3},
+		{1, 1},
+		{7, 2},
+	} {
+		var sum, sumOfSquares float64
+		for i := 0; i < numSamples; i++ {
+			x := float64(Poisson(tc.num, tc.den))
+			sum += x
+			sumOfSquares += x * x
+		}
+		lambda := float64(tc.num) / float64(tc.den)
+		mean := sum / numSamples
+		variance := sumOfSquares/numSamples - mean*mean
+		// The standard deviation of the mean is at most 0.014, and that of the
+		// variance at most 0.04.
+		if math.Abs(mean-lambda) > 0.07 {
+			t.Errorf("Poisson(%d, %d): got mean %f, want %f", tc.num, tc.den, mean, lambda)
+		}
+		if math.Abs(variance-lambda) > 0.2 {
+			t.Errorf("Poisson(%d, %d): got variance %f, want %f", tc.num, tc.den, variance, lambda)
+		}
+	}
+}
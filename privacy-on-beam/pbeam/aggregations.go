@@ -19,6 +19,7 @@ package pbeam
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 
@@ -37,6 +38,9 @@ func init() {
 	beam.RegisterType(reflect.TypeOf((*boundedSumFloat64Fn)(nil)))
 	beam.RegisterType(reflect.TypeOf((*decodePairInt64Fn)(nil)))
 	beam.RegisterType(reflect.TypeOf((*decodePairFloat64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*roundInt64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*roundFloat64Fn)(nil)))
+	beam.RegisterType(reflect.TypeOf((*clampFloat64Fn)(nil)))
 	beam.RegisterFunction(randBool)
 	beam.RegisterFunction(clampNegativePartitionsInt64Fn)
 	beam.RegisterFunction(clampNegativePartitionsFloat64Fn)
@@ -447,6 +451,61 @@ func clampNegativePartitionsFloat64Fn(v beam.V, r float64) (beam.V, float64) {
 	return v, r
 }
 
+// checkRounding returns an error if rounding is not a valid dpagg.Rounding.
+func checkRounding(label string, rounding dpagg.Rounding) error {
+	if err := rounding.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid Rounding: %w", label, err)
+	}
+	return nil
+}
+
+// roundPartitions rounds the values of a PCollection<K,V>, where V is of the
+// given kind, as specified by rounding. It returns col unchanged if rounding
+// is the zero value.
+func roundPartitions(s beam.Scope, col beam.PCollection, rounding dpagg.Rounding, kind reflect.Kind) beam.PCollection {
+	if rounding == (dpagg.Rounding{}) {
+		return col
+	}
+	switch kind {
+	case reflect.Int64:
+		return beam.ParDo(s, &roundInt64Fn{Rounding: rounding}, col)
+	case reflect.Float64:
+		return beam.ParDo(s, &roundFloat64Fn{Rounding: rounding}, col)
+	default:
+		log.Exitf("pbeam.roundPartitions: kind(%v) should be int64 or float64", kind)
+	}
+	return col
+}
+
+// roundInt64Fn rounds int64 partitions, e.g., as a post aggregation step after
+// thresholding and clamping.
+type roundInt64Fn struct {
+	Rounding dpagg.Rounding
+}
+
+func (fn *roundInt64Fn) ProcessElement(v beam.V, r int64) (beam.V, int64) {
+	return v, fn.Rounding.RoundInt64(r)
+}
+
+// roundFloat64Fn rounds float64 partitions.
+type roundFloat64Fn struct {
+	Rounding dpagg.Rounding
+}
+
+func (fn *roundFloat64Fn) ProcessElement(v beam.V, r float64) (beam.V, float64) {
+	return v, fn.Rounding.RoundFloat64(r)
+}
+
+// clampFloat64Fn clamps float64 partitions to [Lower, Upper], e.g., to keep
+// rounded means within the bounds of the aggregation.
+type clampFloat64Fn struct {
+	Lower, Upper float64
+}
+
+func (fn *clampFloat64Fn) ProcessElement(v beam.V, r float64) (beam.V, float64) {
+	return v, math.Min(math.Max(r, fn.Lower), fn.Upper)
+}
+
 func convertFloat32ToFloat64Fn(z beam.Z, f float32) (beam.Z, float64) {
 	return z, float64(f)
 }
@@ -20,6 +20,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/google/differential-privacy/go/dpagg"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -251,3 +252,30 @@ func TestBoundedSumFloat64FnExtractOutputReturnsNilForSmallPartitions(t *testing
 		}
 	}
 }
+
+func TestRoundFns(t *testing.T) {
+	intFn := &roundInt64Fn{Rounding: dpagg.Rounding{Granularity: 10}}
+	if _, got := intFn.ProcessElement("a", 15); got != 20 {
+		t.Errorf("roundInt64Fn.ProcessElement(15) with Granularity 10: got %d, want 20", got)
+	}
+	floatFn := &roundFloat64Fn{Rounding: dpagg.Rounding{SignificantDigits: 2}}
+	if _, got := floatFn.ProcessElement("a", 1.234); got != 1.2 {
+		t.Errorf("roundFloat64Fn.ProcessElement(1.234) with SignificantDigits 2: got %f, want 1.2", got)
+	}
+}
+
+func TestClampFloat64Fn(t *testing.T) {
+	// A mean with bounds [0.5, 0.9] rounded with Granularity 1.
+	fn := &clampFloat64Fn{Lower: 0.5, Upper: 0.9}
+	for _, tc := range []struct {
+		value, want float64
+	}{
+		{0, 0.5},
+		{0.7, 0.7},
+		{1, 0.9},
+	} {
+		if _, got := fn.ProcessElement("a", tc.value); got != tc.want {
+			t.Errorf("clampFloat64Fn.ProcessElement(%f) with bounds [0.5, 0.9]: got %f, want %f", tc.value, got, tc.want)
+		}
+	}
+}
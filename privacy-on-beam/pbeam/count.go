@@ -17,7 +17,10 @@
 package pbeam
 
 import (
+	"reflect"
+
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/dpagg"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
 	"github.com/apache/beam/sdks/go/pkg/beam"
@@ -54,6 +57,29 @@ type CountParams struct {
 	//
 	// Required.
 	MaxValue int64
+	// Rounding applied to the released values, after thresholding and
+	// clamping, e.g., to release them rounded to the nearest 10. It is a
+	// post-processing step that does not consume privacy budget.
+	//
+	// Defaults to no rounding.
+	Rounding dpagg.Rounding
+}
+
+// Validate returns an error if Count would fail with these parameters,
+// without building any pipeline. The checks that depend on the budget of the
+// PrivacySpec are only done when the aggregation is added to the pipeline.
+func (params CountParams) Validate() error {
+	const label = "pbeam.CountParams"
+	if err := checkBudget(label, params.Epsilon, params.Delta); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxPartitionsContributed", params.MaxPartitionsContributed); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxValue", params.MaxValue); err != nil {
+		return err
+	}
+	return checkRounding(label, params.Rounding)
 }
 
 // Count counts the number of times a value appears in a PrivatePCollection,
@@ -67,6 +93,9 @@ type CountParams struct {
 // Count transforms a PrivatePCollection<V> into a PCollection<V, int64>.
 func Count(s beam.Scope, pcol PrivatePCollection, params CountParams) beam.PCollection {
 	s = s.Scope("pbeam.Count")
+	if err := params.Validate(); err != nil {
+		log.Exitf("invalid parameters: %v", err)
+	}
 	// Obtain type information from the underlying PCollection<K,V>.
 	idT, partitionT := beam.ValidateKVType(pcol.col)
 	// Get privacy parameters.
@@ -103,6 +132,8 @@ func Count(s beam.Scope, pcol PrivatePCollection, params CountParams) beam.PColl
 		countsKV)
 	// Drop thresholded partitions.
 	counts := beam.ParDo(s, dropThresholdedPartitionsInt64Fn, sums)
-	// Clamp negative counts to zero and return.
-	return beam.ParDo(s, clampNegativePartitionsInt64Fn, counts)
+	// Clamp negative counts to zero.
+	counts = beam.ParDo(s, clampNegativePartitionsInt64Fn, counts)
+	// Round the counts and return.
+	return roundPartitions(s, counts, params.Rounding, reflect.Int64)
 }
@@ -54,6 +54,27 @@ type DistinctPrivacyIDParams struct {
 	//
 	// Required.
 	MaxPartitionsContributed int64
+	// Rounding applied to the released values, after thresholding and
+	// clamping, e.g., to release them rounded to the nearest 10. It is a
+	// post-processing step that does not consume privacy budget.
+	//
+	// Defaults to no rounding.
+	Rounding dpagg.Rounding
+}
+
+// Validate returns an error if DistinctPrivacyID would fail with these
+// parameters, without building any pipeline. The checks that depend on the
+// budget of the PrivacySpec are only done when the aggregation is added to the
+// pipeline.
+func (params DistinctPrivacyIDParams) Validate() error {
+	const label = "pbeam.DistinctPrivacyIDParams"
+	if err := checkBudget(label, params.Epsilon, params.Delta); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxPartitionsContributed", params.MaxPartitionsContributed); err != nil {
+		return err
+	}
+	return checkRounding(label, params.Rounding)
 }
 
 // DistinctPrivacyID counts the number of distinct privacy identifiers
@@ -69,6 +90,9 @@ type DistinctPrivacyIDParams struct {
 // PCollection<V,int64>.
 func DistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPrivacyIDParams) beam.PCollection {
 	s = s.Scope("pbeam.DistinctPrivacyID")
+	if err := params.Validate(); err != nil {
+		log.Exitf("invalid parameters: %v", err)
+	}
 	// Obtain type information from the underlying PCollection<K,V>.
 	idT, partitionT := beam.ValidateKVType(pcol.col)
 	// Get privacy parameters.
@@ -102,8 +126,9 @@ func DistinctPrivacyID(s beam.Scope, pcol PrivatePCollection, params DistinctPri
 	noisedCounts := beam.CombinePerKey(s,
 		newCountFn(epsilon, delta, maxPartitionsContributed, noiseKind),
 		dummyCounts)
-	// Finally, drop thresholded partitions and return the result
-	return beam.ParDo(s, dropThresholdedPartitionsInt64Fn, noisedCounts)
+	// Finally, drop thresholded partitions, round the counts and return the result
+	counts := beam.ParDo(s, dropThresholdedPartitionsInt64Fn, noisedCounts)
+	return roundPartitions(s, counts, params.Rounding, reflect.Int64)
 }
 
 func addOneValueFn(v beam.V) (beam.V, int64) {
@@ -22,6 +22,7 @@ import (
 	"reflect"
 
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/dpagg"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
@@ -77,6 +78,36 @@ type MeanParams struct {
 	//
 	// Required.
 	MinValue, MaxValue float64
+	// Rounding applied to the released values, after thresholding, e.g., to
+	// release them rounded to the nearest 10. The rounded means are clamped to
+	// [MinValue, MaxValue]. It is a post-processing step that does not consume
+	// privacy budget.
+	//
+	// Defaults to no rounding.
+	Rounding dpagg.Rounding
+}
+
+// Validate returns an error if MeanPerKey would fail with these parameters,
+// without building any pipeline. The checks that depend on the budget of the
+// PrivacySpec are only done when the aggregation is added to the pipeline.
+func (params MeanParams) Validate() error {
+	const label = "pbeam.MeanParams"
+	if err := checkBudget(label, params.Epsilon, params.Delta); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxPartitionsContributed", params.MaxPartitionsContributed); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxContributionsPerPartition", params.MaxContributionsPerPartition); err != nil {
+		return err
+	}
+	if err := checkMinMaxValues(label, params.MinValue, params.MaxValue); err != nil {
+		return err
+	}
+	if params.MinValue == params.MaxValue {
+		return &checks.ParameterError{Label: label, Parameter: "MaxValue", Value: params.MaxValue, Constraint: checks.InRange, AllowedRange: fmt.Sprintf("(%f, +∞)", params.MinValue)}
+	}
+	return checkRounding(label, params.Rounding)
 }
 
 // MeanPerKey obtains the mean of the values associated with each key in a
@@ -90,6 +121,9 @@ type MeanParams struct {
 // MeanPerKey transforms a PrivatePCollection<K,V> into a PCollection<K,float64>.
 func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.PCollection {
 	s = s.Scope("pbeam.MeanPerKey")
+	if err := params.Validate(); err != nil {
+		log.Exitf("invalid parameters: %v", err)
+	}
 	// Obtain & validate type information from the underlying PCollection<K,V>.
 	idT, kvT := beam.ValidateKVType(pcol.col)
 	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
@@ -156,8 +190,15 @@ func MeanPerKey(s beam.Scope, pcol PrivatePCollection, params MeanParams) beam.P
 	means := beam.CombinePerKey(s,
 		newBoundedMeanFloat64Fn(epsilon, delta, maxPartitionsContributed, params.MaxContributionsPerPartition, params.MinValue, params.MaxValue, noiseKind),
 		partialKV)
-	// Finally, drop thresholded partitions.
-	return beam.ParDo(s, dropThresholdedPartitionsFloat64Fn, means)
+	// Finally, drop thresholded partitions and round the means. Rounding can
+	// move a mean out of [MinValue, MaxValue] when the bounds aren't multiples
+	// of the granularity, so the rounded means are clamped again.
+	means = beam.ParDo(s, dropThresholdedPartitionsFloat64Fn, means)
+	if params.Rounding == (dpagg.Rounding{}) {
+		return means
+	}
+	means = roundPartitions(s, means, params.Rounding, reflect.Float64)
+	return beam.ParDo(s, &clampFloat64Fn{Lower: params.MinValue, Upper: params.MaxValue}, means)
 }
 
 // decodePairArrayFloat64Fn transforms a PCollection<pairArrayFloat64<codedX,[]float64>> into a
@@ -385,9 +426,7 @@ func (fn *boundedMeanFloat64Fn) AddInput(a boundedMeanAccumFloat64, values []flo
 	// We can have multiple values for each (privacy_key, partition_key) pair.
 	// We need to add each value to BoundedMean as input but we need to add a single input
 	// for each privacy_key to SelectPartition.
-	for _, v := range values {
-		a.BM.Add(v)
-	}
+	a.BM.AddBatch(values)
 	a.SP.Add()
 	return a
 }
@@ -109,11 +109,13 @@ package pbeam
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 	"sync"
 
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
 	"github.com/apache/beam/sdks/go/pkg/beam"
@@ -174,8 +176,13 @@ func (ps *PrivacySpec) consumePartialBudget(epsilon, delta float64) (eps, del fl
 		log.Infof("corrected rounding error for delta budget allocation (requested: %e, available: %e, difference: %e)", epsilon, ps.epsilon, epsilon-ps.epsilon)
 		delta = ps.delta
 	}
-	if ps.epsilon < epsilon || ps.delta < delta {
-		return 0, 0, fmt.Errorf("not enough budget left for PrivacySpec: trying to consume epsilon=%f and delta=%e out of %+v", epsilon, delta, ps)
+	if ps.epsilon < epsilon {
+		err := &checks.ParameterError{Label: "PrivacySpec", Parameter: "Epsilon", Value: epsilon, Constraint: checks.InRange, AllowedRange: fmt.Sprintf("[0, %f]", ps.epsilon)}
+		return 0, 0, fmt.Errorf("not enough budget left for PrivacySpec: %w", err)
+	}
+	if ps.delta < delta {
+		err := &checks.ParameterError{Label: "PrivacySpec", Parameter: "Delta", Value: delta, Constraint: checks.InRange, AllowedRange: fmt.Sprintf("[0, %e]", ps.delta)}
+		return 0, 0, fmt.Errorf("not enough budget left for PrivacySpec: %w", err)
 	}
 	ps.epsilon -= epsilon
 	ps.delta -= delta
@@ -201,6 +208,52 @@ type PrivacySpecOption interface {
 	updatePrivacySpec(ps *PrivacySpec)
 }
 
+// checkBudget returns an error if epsilon and delta are not a valid budget for
+// an aggregation: they must either be both 0, to consume the entire budget of
+// the PrivacySpec, or both strictly positive.
+func checkBudget(label string, epsilon, delta float64) error {
+	if epsilon == 0 && delta == 0 {
+		return nil
+	}
+	if err := checks.CheckEpsilonStrict(label, epsilon); err != nil {
+		return err
+	}
+	return checks.CheckDelta(label, delta)
+}
+
+// checkStrictlyPositive returns an error if the given required parameter is
+// not strictly positive.
+func checkStrictlyPositive(label, parameter string, value int64) error {
+	if value <= 0 {
+		return &checks.ParameterError{Label: label, Parameter: parameter, Value: value, Constraint: checks.StrictlyPositive, AllowedRange: "(0, MaxInt64]"}
+	}
+	return nil
+}
+
+// checkMinMaxValues returns an error if minValue and maxValue are not valid
+// bounds, i.e., if either of them is NaN or infinite, or if minValue is larger
+// than maxValue. Unlike checks.CheckBoundsFloat64, the errors name the
+// MinValue and MaxValue parameters of pbeam.
+func checkMinMaxValues(label string, minValue, maxValue float64) error {
+	const allowed = "(-∞, +∞)"
+	if math.IsNaN(minValue) {
+		return &checks.ParameterError{Label: label, Parameter: "MinValue", Value: minValue, Constraint: checks.NotNaN, AllowedRange: allowed}
+	}
+	if math.IsNaN(maxValue) {
+		return &checks.ParameterError{Label: label, Parameter: "MaxValue", Value: maxValue, Constraint: checks.NotNaN, AllowedRange: allowed}
+	}
+	if math.IsInf(minValue, 0) {
+		return &checks.ParameterError{Label: label, Parameter: "MinValue", Value: minValue, Constraint: checks.Finite, AllowedRange: allowed}
+	}
+	if math.IsInf(maxValue, 0) {
+		return &checks.ParameterError{Label: label, Parameter: "MaxValue", Value: maxValue, Constraint: checks.Finite, AllowedRange: allowed}
+	}
+	if minValue > maxValue {
+		return &checks.ParameterError{Label: label, Parameter: "MaxValue", Value: maxValue, Constraint: checks.InRange, AllowedRange: fmt.Sprintf("[%f, +∞)", minValue)}
+	}
+	return nil
+}
+
 // getMaxPartitionsContributed returns a maxPartitionsContributed parameter
 // if it greater than zero, otherwise it fails.
 func getMaxPartitionsContributed(spec *PrivacySpec, maxPartitionsContributed int64) int64 {
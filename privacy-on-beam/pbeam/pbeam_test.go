@@ -16,8 +16,12 @@
 package pbeam
 
 import (
+	"errors"
+	"math"
 	"testing"
 
+	"github.com/google/differential-privacy/go/checks"
+	"github.com/google/differential-privacy/go/dpagg"
 	testpb "github.com/google/differential-privacy/privacy-on-beam/testdata"
 	"github.com/apache/beam/sdks/go/pkg/beam"
 	"github.com/apache/beam/sdks/go/pkg/beam/testing/passert"
@@ -352,3 +356,61 @@ func TestBudgetRounding(t *testing.T) {
 		t.Errorf("expected spec to be out of budget, but could consume (%f,%e) without any error", eps, del)
 	}
 }
+
+func TestParamsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		params  interface{ Validate() error }
+		wantErr bool
+	}{
+		{"valid CountParams", CountParams{MaxPartitionsContributed: 1, MaxValue: 1}, false},
+		{"CountParams with partial budget", CountParams{Epsilon: 1, Delta: 1e-5, MaxPartitionsContributed: 1, MaxValue: 1}, false},
+		{"CountParams with epsilon but no delta", CountParams{Epsilon: 1, MaxPartitionsContributed: 1, MaxValue: 1}, true},
+		{"CountParams without MaxPartitionsContributed", CountParams{MaxValue: 1}, true},
+		{"CountParams without MaxValue", CountParams{MaxPartitionsContributed: 1}, true},
+		{"valid DistinctPrivacyIDParams", DistinctPrivacyIDParams{MaxPartitionsContributed: 1}, false},
+		{"DistinctPrivacyIDParams with negative delta", DistinctPrivacyIDParams{Epsilon: 1, Delta: -1, MaxPartitionsContributed: 1}, true},
+		{"valid SumParams", SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 5}, false},
+		{"SumParams without bounds", SumParams{MaxPartitionsContributed: 1}, true},
+		{"SumParams with MinValue larger than MaxValue", SumParams{MaxPartitionsContributed: 1, MinValue: 5, MaxValue: 0}, true},
+		{"valid MeanParams", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5}, false},
+		{"MeanParams without MaxContributionsPerPartition", MeanParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 5}, true},
+		{"MeanParams with equal bounds", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 5, MaxValue: 5}, true},
+		{"CountParams with Rounding", CountParams{MaxPartitionsContributed: 1, MaxValue: 1, Rounding: dpagg.Rounding{Granularity: 10}}, false},
+		{"CountParams with invalid Rounding", CountParams{MaxPartitionsContributed: 1, MaxValue: 1, Rounding: dpagg.Rounding{Granularity: -10}}, true},
+		{"DistinctPrivacyIDParams with invalid Rounding", DistinctPrivacyIDParams{MaxPartitionsContributed: 1, Rounding: dpagg.Rounding{SignificantDigits: -1}}, true},
+		{"SumParams with invalid Rounding", SumParams{MaxPartitionsContributed: 1, MinValue: 0, MaxValue: 5, Rounding: dpagg.Rounding{Granularity: 10, SignificantDigits: 2}}, true},
+		{"MeanParams with Rounding", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5, Rounding: dpagg.Rounding{SignificantDigits: 2}}, false},
+	} {
+		if err := tc.params.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate: for %s got err %v, wantErr %t", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestParamsValidateReturnsParameterError(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		params        interface{ Validate() error }
+		wantParameter string
+	}{
+		{"CountParams without MaxValue", CountParams{MaxPartitionsContributed: 1}, "MaxValue"},
+		{"DistinctPrivacyIDParams with negative delta", DistinctPrivacyIDParams{Epsilon: 1, Delta: -1, MaxPartitionsContributed: 1}, "Delta"},
+		{"SumParams with MinValue larger than MaxValue", SumParams{MaxPartitionsContributed: 1, MinValue: 5, MaxValue: 0}, "MaxValue"},
+		{"SumParams with NaN MinValue", SumParams{MaxPartitionsContributed: 1, MinValue: math.NaN(), MaxValue: 5}, "MinValue"},
+		{"SumParams without bounds", SumParams{MaxPartitionsContributed: 1}, "MaxValue"},
+		{"MeanParams with infinite MinValue", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: math.Inf(-1), MaxValue: 5}, "MinValue"},
+		{"MeanParams with equal bounds", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 5, MaxValue: 5}, "MaxValue"},
+		{"MeanParams with invalid Rounding", MeanParams{MaxPartitionsContributed: 1, MaxContributionsPerPartition: 1, MinValue: 0, MaxValue: 5, Rounding: dpagg.Rounding{Granularity: -1}}, "Rounding.Granularity"},
+	} {
+		err := tc.params.Validate()
+		var perr *checks.ParameterError
+		if !errors.As(err, &perr) {
+			t.Errorf("Validate: for %s got err %v, want a *checks.ParameterError", tc.desc, err)
+			continue
+		}
+		if perr.Parameter != tc.wantParameter {
+			t.Errorf("Validate: for %s got an error on %s, want %s", tc.desc, perr.Parameter, tc.wantParameter)
+		}
+	}
+}
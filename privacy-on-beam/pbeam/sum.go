@@ -22,6 +22,7 @@ import (
 	"reflect"
 
 	log "github.com/golang/glog"
+	"github.com/google/differential-privacy/go/checks"
 	"github.com/google/differential-privacy/go/noise"
 	"github.com/google/differential-privacy/privacy-on-beam/internal/kv"
 	"github.com/apache/beam/sdks/go/pkg/beam"
@@ -66,6 +67,35 @@ type SumParams struct {
 	//
 	// Required.
 	MinValue, MaxValue float64
+	// Rounding applied to the released values, after thresholding and
+	// clamping, e.g., to release them rounded to the nearest 10. It is a
+	// post-processing step that does not consume privacy budget.
+	//
+	// Defaults to no rounding.
+	Rounding dpagg.Rounding
+}
+
+// Validate returns an error if SumPerKey would fail with these parameters,
+// without building any pipeline. The checks that depend on the budget of the
+// PrivacySpec or on the type of the values are only done when the aggregation
+// is added to the pipeline.
+func (params SumParams) Validate() error {
+	const label = "pbeam.SumParams"
+	if err := checkBudget(label, params.Epsilon, params.Delta); err != nil {
+		return err
+	}
+	if err := checkStrictlyPositive(label, "MaxPartitionsContributed", params.MaxPartitionsContributed); err != nil {
+		return err
+	}
+	if err := checkMinMaxValues(label, params.MinValue, params.MaxValue); err != nil {
+		return err
+	}
+	if params.MinValue == 0 && params.MaxValue == 0 {
+		// At least one of the bounds must be set, i.e., MaxValue must be strictly
+		// larger than MinValue = 0.
+		return &checks.ParameterError{Label: label, Parameter: "MaxValue", Value: params.MaxValue, Constraint: checks.StrictlyPositive, AllowedRange: "(0, +∞)"}
+	}
+	return checkRounding(label, params.Rounding)
 }
 
 // SumPerKey sums the values associated with each key in a
@@ -81,6 +111,9 @@ type SumParams struct {
 // input is an integer type or a float type.
 func SumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) beam.PCollection {
 	s = s.Scope("pbeam.SumPerKey")
+	if err := params.Validate(); err != nil {
+		log.Exitf("invalid parameters: %v", err)
+	}
 	// Obtain & validate type information from the underlying PCollection<K,V>.
 	idT, kvT := beam.ValidateKVType(pcol.col)
 	if kvT.Type() != reflect.TypeOf(kv.Pair{}) {
@@ -141,7 +174,7 @@ func SumPerKey(s beam.Scope, pcol PrivatePCollection, params SumParams) beam.PCo
 	if params.MinValue >= 0 {
 		sums = beam.ParDo(s, findClampNegativePartitionsFn(vKind), sums)
 	}
-	return sums
+	return roundPartitions(s, sums, params.Rounding, vKind)
 }
 
 // prepareSumFn takes a PCollection<ID,kv.Pair{K,V}> as input, and returns a